@@ -19,6 +19,7 @@ package softwarecomposition
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/containers/common/pkg/seccomp"
@@ -277,17 +278,29 @@ type OpenCalls struct {
 	Flags []string
 }
 
+// String is the canonical form of an open: the path followed by the flags
+// in sorted order, so opens that differ only in flag order have the same
+// key in DeflateStringer.
 func (e OpenCalls) String() string {
 	s := strings.Builder{}
 	s.WriteString(e.Path)
-	// FIXME should we sort the flags?
-	for _, flag := range e.Flags {
+	flags := e.Flags
+	if !slices.IsSorted(flags) {
+		flags = slices.Sorted(slices.Values(flags))
+	}
+	for _, flag := range flags {
 		s.WriteString(sep)
 		s.WriteString(flag)
 	}
 	return s.String()
 }
 
+// Equal reports whether e and other have the same path and the same flags,
+// in any order.
+func (e OpenCalls) Equal(other OpenCalls) bool {
+	return e.Path == other.Path && e.String() == other.String()
+}
+
 type CallID string
 
 type IdentifiedCallStack struct {
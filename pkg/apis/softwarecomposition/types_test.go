@@ -340,6 +340,14 @@ func TestOpenCalls_String(t *testing.T) {
 			},
 			want: "/etc/passwd␟O_RDONLY",
 		},
+		{
+			name: "Flags are sorted",
+			o: OpenCalls{
+				Path:  "/etc/passwd",
+				Flags: []string{"O_RDWR", "O_CLOEXEC", "O_CREAT"},
+			},
+			want: "/etc/passwd␟O_CLOEXEC␟O_CREAT␟O_RDWR",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -348,6 +356,53 @@ func TestOpenCalls_String(t *testing.T) {
 	}
 }
 
+func TestOpenCalls_StringDoesNotModifyFlags(t *testing.T) {
+	o := OpenCalls{Path: "/etc/passwd", Flags: []string{"O_RDWR", "O_CLOEXEC"}}
+	_ = o.String()
+	assert.Equal(t, []string{"O_RDWR", "O_CLOEXEC"}, o.Flags)
+}
+
+func TestOpenCalls_Equal(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b OpenCalls
+		want bool
+	}{
+		{
+			name: "empty",
+			want: true,
+		},
+		{
+			name: "flag order is ignored",
+			a:    OpenCalls{Path: "/etc/passwd", Flags: []string{"O_RDONLY", "O_CLOEXEC"}},
+			b:    OpenCalls{Path: "/etc/passwd", Flags: []string{"O_CLOEXEC", "O_RDONLY"}},
+			want: true,
+		},
+		{
+			name: "different path",
+			a:    OpenCalls{Path: "/etc/passwd", Flags: []string{"O_RDONLY"}},
+			b:    OpenCalls{Path: "/etc/shadow", Flags: []string{"O_RDONLY"}},
+		},
+		{
+			name: "different flags",
+			a:    OpenCalls{Path: "/etc/passwd", Flags: []string{"O_RDONLY"}},
+			b:    OpenCalls{Path: "/etc/passwd", Flags: []string{"O_RDWR"}},
+		},
+		{
+			name: "nil and empty flags",
+			a:    OpenCalls{Path: "/etc/passwd"},
+			b:    OpenCalls{Path: "/etc/passwd", Flags: []string{}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.a.Equal(tt.b))
+			assert.Equal(t, tt.want, tt.b.Equal(tt.a))
+		})
+	}
+}
+
 func TestHTTPEndpoint_String(t *testing.T) {
 	headers := map[string][]string{
 		"Content-Type":  {"application/json"},
@@ -20,9 +20,14 @@ type Config struct {
 	HostType                      armotypes.HostType `mapstructure:"hostType"`
 	DisableVirtualCRDs            bool               `mapstructure:"disableVirtualCRDs"`
 	DisableSeccompProfileEndpoint bool               `mapstructure:"disableSeccompProfileEndpoint"`
+	DynamicStyle                  string             `mapstructure:"dynamicStyle"`
 	ExcludeJsonPaths              []string           `mapstructure:"excludeJsonPaths"`
 	MaxApplicationProfileSize     int                `mapstructure:"maxApplicationProfileSize"`
+	MaxEndpointsThreshold         int                `mapstructure:"maxEndpointsThreshold"`
+	MaxExecsThreshold             int                `mapstructure:"maxExecsThreshold"`
+	MaxExecsPerContainer          int                `mapstructure:"maxExecsPerContainer"`
 	MaxNetworkNeighborhoodSize    int                `mapstructure:"maxNetworkNeighborhoodSize"`
+	MaxOpensThreshold             int                `mapstructure:"maxOpensThreshold"`
 	MaxSniffingTime               time.Duration      `mapstructure:"maxSniffingTimePerContainer"`
 	RateLimitPerClient            float64            `mapstructure:"rateLimitPerClient"`
 	RateLimitTotal                int                `mapstructure:"rateLimitTotal"`
@@ -0,0 +1,101 @@
+package file
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kubescape/k8s-interface/instanceidhandler/v1/helpers"
+	"github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/utils"
+)
+
+// MergeApplicationProfiles combines two profiles of the same workload, e.g.
+// from different recording windows. Containers are matched by name within
+// Containers, InitContainers and EphemeralContainers; matched containers
+// get the union of their execs, opens, endpoints and the rest, deflated
+// again so the merged entries collapse like a single recording would
+// (without an SBOM, so no open is kept literal for being listed in one).
+// Containers found in only one profile are carried over as they are.
+// Architectures are unioned and the metadata is a's, with b's labels and
+// annotations added where a has none; the size annotation is dropped, as
+// PreSave computes it again. The inputs are not modified.
+//
+// An error is returned if either profile is nil or a container of the
+// same name was recorded from different images.
+func MergeApplicationProfiles(a, b *softwarecomposition.ApplicationProfile) (*softwarecomposition.ApplicationProfile, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("cannot merge a nil application profile")
+	}
+	merged := a.DeepCopy()
+	b = b.DeepCopy()
+
+	merged.Labels = utils.MergeMaps(merged.Labels, b.Labels)
+	merged.Annotations = utils.MergeMaps(merged.Annotations, b.Annotations)
+	delete(merged.Annotations, helpers.ResourceSizeMetadataKey)
+
+	var err error
+	if merged.Spec.Containers, err = mergeApplicationProfileContainers(merged.Spec.Containers, b.Spec.Containers); err != nil {
+		return nil, err
+	}
+	if merged.Spec.InitContainers, err = mergeApplicationProfileContainers(merged.Spec.InitContainers, b.Spec.InitContainers); err != nil {
+		return nil, err
+	}
+	if merged.Spec.EphemeralContainers, err = mergeApplicationProfileContainers(merged.Spec.EphemeralContainers, b.Spec.EphemeralContainers); err != nil {
+		return nil, err
+	}
+	if merged.Spec.Architectures != nil || b.Spec.Architectures != nil {
+		merged.Spec.Architectures = utils.DeflateStringSlice(slices.Concat(merged.Spec.Architectures, b.Spec.Architectures))
+	}
+	return merged, nil
+}
+
+// mergeApplicationProfileContainers merges the containers of b into a by
+// name, keeping a's order and appending the containers only b has.
+func mergeApplicationProfileContainers(a, b []softwarecomposition.ApplicationProfileContainer) ([]softwarecomposition.ApplicationProfileContainer, error) {
+	for _, container := range b {
+		i := slices.IndexFunc(a, func(c softwarecomposition.ApplicationProfileContainer) bool {
+			return c.Name == container.Name
+		})
+		if i < 0 {
+			a = append(a, container)
+			continue
+		}
+		merged, err := mergeApplicationProfileContainer(a[i], container)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = merged
+	}
+	return a, nil
+}
+
+// mergeApplicationProfileContainer returns the deflated union of two
+// recordings of the same container.
+func mergeApplicationProfileContainer(a, b softwarecomposition.ApplicationProfileContainer) (softwarecomposition.ApplicationProfileContainer, error) {
+	if a.ImageID != "" && b.ImageID != "" && a.ImageID != b.ImageID {
+		return softwarecomposition.ApplicationProfileContainer{}, fmt.Errorf("container %q was recorded from different images %q and %q", a.Name, a.ImageID, b.ImageID)
+	}
+	union := softwarecomposition.ApplicationProfileContainer{
+		Name:                 a.Name,
+		Capabilities:         slices.Concat(a.Capabilities, b.Capabilities),
+		Execs:                slices.Concat(a.Execs, b.Execs),
+		Opens:                slices.Concat(a.Opens, b.Opens),
+		Syscalls:             slices.Concat(a.Syscalls, b.Syscalls),
+		SeccompProfile:       a.SeccompProfile,
+		Endpoints:            slices.Concat(a.Endpoints, b.Endpoints),
+		ImageID:              a.ImageID,
+		ImageTag:             a.ImageTag,
+		PolicyByRuleId:       MergeRulePolicies(a.PolicyByRuleId, b.PolicyByRuleId),
+		IdentifiedCallStacks: slices.Concat(a.IdentifiedCallStacks, b.IdentifiedCallStacks),
+	}
+	if union.SeccompProfile.Name == "" {
+		union.SeccompProfile = b.SeccompProfile
+	}
+	if union.ImageID == "" {
+		union.ImageID = b.ImageID
+	}
+	if union.ImageTag == "" {
+		union.ImageTag = b.ImageTag
+	}
+	return deflateApplicationProfileContainer(union, nil), nil
+}
@@ -0,0 +1,105 @@
+package file
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/k8s-interface/instanceidhandler/v1/helpers"
+	"github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeApplicationProfiles(t *testing.T) {
+	a := &softwarecomposition.ApplicationProfile{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "replicaset-nginx",
+			Annotations: map[string]string{helpers.ResourceSizeMetadataKey: "3", "window": "a"},
+		},
+		Spec: softwarecomposition.ApplicationProfileSpec{
+			Architectures: []string{"amd64"},
+			Containers: []softwarecomposition.ApplicationProfileContainer{
+				{
+					Name:     "nginx",
+					ImageID:  "sha256:1",
+					Execs:    []softwarecomposition.ExecCalls{{Path: "/usr/sbin/nginx"}},
+					Opens:    []softwarecomposition.OpenCalls{{Path: "/etc/nginx/nginx.conf", Flags: []string{"O_RDONLY"}}},
+					Syscalls: []string{"read", "open"},
+				},
+				{Name: "sidecar", Syscalls: []string{"write"}},
+			},
+		},
+	}
+	b := &softwarecomposition.ApplicationProfile{
+		ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{"window": "b", "extra": "b"}},
+		Spec: softwarecomposition.ApplicationProfileSpec{
+			Architectures: []string{"arm64", "amd64"},
+			Containers: []softwarecomposition.ApplicationProfileContainer{
+				{
+					Name:     "nginx",
+					ImageID:  "sha256:1",
+					Execs:    []softwarecomposition.ExecCalls{{Path: "/usr/sbin/nginx"}, {Path: "/bin/sh"}},
+					Opens:    []softwarecomposition.OpenCalls{{Path: "/etc/nginx/nginx.conf", Flags: []string{"O_WRONLY"}}},
+					Syscalls: []string{"open", "close"},
+				},
+			},
+			InitContainers: []softwarecomposition.ApplicationProfileContainer{{Name: "init", Syscalls: []string{"exit"}}},
+		},
+	}
+	aBefore, bBefore := a.DeepCopy(), b.DeepCopy()
+
+	merged, err := MergeApplicationProfiles(a, b)
+	require.NoError(t, err)
+
+	assert.Equal(t, aBefore, a, "a must not be modified")
+	assert.Equal(t, bBefore, b, "b must not be modified")
+	assert.Equal(t, "replicaset-nginx", merged.Name)
+	assert.Equal(t, map[string]string{"window": "a", "extra": "b"}, merged.Annotations)
+	assert.Equal(t, []string{"amd64", "arm64"}, merged.Spec.Architectures)
+
+	require.Len(t, merged.Spec.Containers, 2)
+	nginx := merged.Spec.Containers[0]
+	assert.Equal(t, "nginx", nginx.Name)
+	assert.ElementsMatch(t, []softwarecomposition.ExecCalls{{Path: "/usr/sbin/nginx"}, {Path: "/bin/sh"}}, nginx.Execs)
+	assert.Equal(t, []softwarecomposition.OpenCalls{{Path: "/etc/nginx/nginx.conf", Flags: []string{"O_RDONLY", "O_WRONLY"}}}, nginx.Opens)
+	assert.Equal(t, []string{"close", "open", "read"}, nginx.Syscalls)
+	assert.Equal(t, a.Spec.Containers[1], merged.Spec.Containers[1], "containers only in a are carried over")
+	assert.Equal(t, b.Spec.InitContainers, merged.Spec.InitContainers, "containers only in b are carried over")
+}
+
+func TestMergeApplicationProfiles_CollapsesAfterMerge(t *testing.T) {
+	window := func(from, to int) *softwarecomposition.ApplicationProfile {
+		var opens []softwarecomposition.OpenCalls
+		for i := from; i < to; i++ {
+			opens = append(opens, softwarecomposition.OpenCalls{Path: fmt.Sprintf("/data/file%d", i), Flags: []string{"O_RDONLY"}})
+		}
+		return &softwarecomposition.ApplicationProfile{
+			Spec: softwarecomposition.ApplicationProfileSpec{
+				Containers: []softwarecomposition.ApplicationProfileContainer{{Name: "app", Opens: opens}},
+			},
+		}
+	}
+	// neither window alone has enough files below /data to collapse
+	half := openThreshold()/2 + 1
+	a, b := window(0, half), window(half, 2*half)
+
+	merged, err := MergeApplicationProfiles(a, b)
+	require.NoError(t, err)
+
+	assert.Equal(t, []softwarecomposition.OpenCalls{{Path: "/data/⋯", Flags: []string{"O_RDONLY"}}}, merged.Spec.Containers[0].Opens)
+}
+
+func TestMergeApplicationProfiles_Errors(t *testing.T) {
+	_, err := MergeApplicationProfiles(nil, &softwarecomposition.ApplicationProfile{})
+	assert.Error(t, err)
+
+	a := &softwarecomposition.ApplicationProfile{Spec: softwarecomposition.ApplicationProfileSpec{
+		Containers: []softwarecomposition.ApplicationProfileContainer{{Name: "app", ImageID: "sha256:1"}},
+	}}
+	b := &softwarecomposition.ApplicationProfile{Spec: softwarecomposition.ApplicationProfileSpec{
+		Containers: []softwarecomposition.ApplicationProfileContainer{{Name: "app", ImageID: "sha256:2"}},
+	}}
+	_, err = MergeApplicationProfiles(a, b)
+	assert.ErrorContains(t, err, `container "app" was recorded from different images`)
+}
@@ -3,6 +3,7 @@ package file
 import (
 	"context"
 	"fmt"
+	"math"
 	"strconv"
 
 	mapset "github.com/deckarep/golang-set/v2"
@@ -14,22 +15,113 @@ import (
 	"github.com/kubescape/storage/pkg/config"
 	"github.com/kubescape/storage/pkg/registry/file/callstack"
 	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/kubescape/storage/pkg/utils"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
-// Thresholds are defined in dynamicpathdetector.OpenDynamicThreshold and
-// dynamicpathdetector.EndpointDynamicThreshold (single source of truth).
-
 type ApplicationProfileProcessor struct {
 	defaultNamespace          string
+	dynamicStyle              dynamicpathdetector.DynamicStyle
 	maxApplicationProfileSize int
 	storageImpl               ContainerProfileStorage
+	thresholds                collapseThresholds
 }
 
 func NewApplicationProfileProcessor(cfg config.Config) *ApplicationProfileProcessor {
 	return &ApplicationProfileProcessor{
 		defaultNamespace:          cfg.DefaultNamespace,
+		dynamicStyle:              parseDynamicStyle(cfg.DynamicStyle),
 		maxApplicationProfileSize: cfg.MaxApplicationProfileSize,
+		thresholds: collapseThresholds{
+			opens:     cfg.MaxOpensThreshold,
+			endpoints: cfg.MaxEndpointsThreshold,
+			execs:     cfg.MaxExecsThreshold,
+			maxExecs:  cfg.MaxExecsPerContainer,
+		},
+	}
+}
+
+// collapseThresholds are the configured analyzer thresholds for a
+// container's opens, endpoints and execs. A zero (or negative) field
+// means the default: dynamicpathdetector.OpenDynamicThreshold for opens,
+// dynamicpathdetector.EndpointDynamicThreshold for endpoints and
+// dynamicpathdetector.ExecDynamicThreshold for execs. The per-prefix
+// defaults of DefaultCollapseConfigs still apply to opens under their
+// prefixes.
+//
+// maxExecs caps the execs of a container: when collapsing leaves more,
+// their args are collapsed further (see capExecs). Zero means no cap.
+type collapseThresholds struct {
+	opens     int
+	endpoints int
+	execs     int
+	maxExecs  int
+}
+
+func (t collapseThresholds) opensThreshold() int {
+	if t.opens > 0 {
+		return t.opens
+	}
+	return dynamicpathdetector.OpenDynamicThreshold
+}
+
+func (t collapseThresholds) endpointsThreshold() int {
+	if t.endpoints > 0 {
+		return t.endpoints
+	}
+	return dynamicpathdetector.EndpointDynamicThreshold
+}
+
+func (t collapseThresholds) execsThreshold() int {
+	if t.execs > 0 {
+		return t.execs
+	}
+	return dynamicpathdetector.ExecDynamicThreshold
+}
+
+// parseDynamicStyle returns the configured output style for collapsed
+// paths, falling back to the default ⋯ style on an unknown name. It also
+// falls back from DynamicStyleSingleStar: PreSave analyzes stored paths
+// again on every update, and that style cannot tell a stored ⋯ from a *.
+func parseDynamicStyle(name string) dynamicpathdetector.DynamicStyle {
+	style, err := dynamicpathdetector.ParseDynamicStyle(name)
+	if err != nil {
+		logger.L().Warning("ignoring invalid dynamicStyle, using the default", loggerhelpers.Error(err))
+	}
+	if style == dynamicpathdetector.DynamicStyleSingleStar {
+		logger.L().Warning("ignoring dynamicStyle that stored profiles cannot be read back from, using the default", loggerhelpers.String("dynamicStyle", name))
+		return dynamicpathdetector.DynamicStyleEllipsis
+	}
+	return style
+}
+
+// styleOpens and styleEndpoints rewrite collapsed paths into style in place.
+func styleOpens(opens []softwarecomposition.OpenCalls, style dynamicpathdetector.DynamicStyle) {
+	for i := range opens {
+		opens[i].Path = dynamicpathdetector.FormatDynamicPath(opens[i].Path, style)
+	}
+}
+
+func styleEndpoints(endpoints []softwarecomposition.HTTPEndpoint, style dynamicpathdetector.DynamicStyle) {
+	for i := range endpoints {
+		endpoints[i].Endpoint = dynamicpathdetector.FormatDynamicPath(endpoints[i].Endpoint, style)
+	}
+}
+
+// unstyleOpens and unstyleEndpoints undo styleOpens and styleEndpoints in
+// place. PreSave runs again on every update of a stored profile, whose
+// paths must be read back as ⋯ and * before they are deflated. Paths sent
+// in with the update are read the same way, so globs in them are written
+// in the configured style too.
+func unstyleOpens(opens []softwarecomposition.OpenCalls, style dynamicpathdetector.DynamicStyle) {
+	for i := range opens {
+		opens[i].Path = dynamicpathdetector.ParseDynamicPath(opens[i].Path, style)
+	}
+}
+
+func unstyleEndpoints(endpoints []softwarecomposition.HTTPEndpoint, style dynamicpathdetector.DynamicStyle) {
+	for i := range endpoints {
+		endpoints[i].Endpoint = dynamicpathdetector.ParseDynamicPath(endpoints[i].Endpoint, style)
 	}
 }
 
@@ -48,8 +140,11 @@ func (a *ApplicationProfileProcessor) PreSave(ctx context.Context, object runtim
 	// set schema version
 	profile.SchemaVersion = SchemaVersion
 
-	// size is the sum of all fields in all containers
+	// size is the sum of all fields in all containers, counted after they
+	// are deflated (and shrunk when over budget): it is the number of
+	// entries actually stored, and what ResourceSizeMetadataKey records
 	var size int
+	var deflated []*deflatedContainer
 
 	// Define a function to process a slice of containers
 	processContainers := func(containers []softwarecomposition.ApplicationProfileContainer) []softwarecomposition.ApplicationProfileContainer {
@@ -71,13 +166,12 @@ func (a *ApplicationProfileProcessor) PreSave(ctx context.Context, object runtim
 			} else {
 				logger.L().Debug("failed to get sbom name", loggerhelpers.Error(err), loggerhelpers.String("imageTag", container.ImageTag), loggerhelpers.String("imageID", container.ImageID))
 			}
-			containers[i] = deflateApplicationProfileContainer(container, sbomSet)
-			size += len(containers[i].Execs)
-			size += len(containers[i].Opens)
-			size += len(containers[i].Syscalls)
-			size += len(containers[i].Capabilities)
-			size += len(containers[i].Endpoints)
-			size += len(containers[i].IdentifiedCallStacks)
+			unstyleOpens(container.Opens, a.dynamicStyle)
+			unstyleEndpoints(container.Endpoints, a.dynamicStyle)
+			containers[i] = deflateApplicationProfileContainerAtLevel(container, sbomSet, a.thresholds, 0)
+			containerSize := applicationProfileContainerSize(&containers[i])
+			size += containerSize
+			deflated = append(deflated, &deflatedContainer{container: &containers[i], sbomSet: sbomSet, size: containerSize})
 		}
 		return containers
 	}
@@ -87,11 +181,25 @@ func (a *ApplicationProfileProcessor) PreSave(ctx context.Context, object runtim
 	profile.Spec.InitContainers = processContainers(profile.Spec.InitContainers)
 	profile.Spec.Containers = processContainers(profile.Spec.Containers)
 
-	profile.Spec.Architectures = DeflateSortString(profile.Spec.Architectures)
+	profile.Spec.Architectures = utils.DeflateStringSlice(profile.Spec.Architectures)
+
+	// over budget: collapse the largest containers harder before giving up
+	if size > a.maxApplicationProfileSize {
+		size = shrinkLargestContainers(deflated, size, a.maxApplicationProfileSize, a.thresholds)
+	}
+
+	for _, d := range deflated {
+		styleOpens(d.container.Opens, a.dynamicStyle)
+		styleEndpoints(d.container.Endpoints, a.dynamicStyle)
+	}
 
 	// check the size of the profile
 	if size > a.maxApplicationProfileSize {
-		return fmt.Errorf("application profile size exceeds the limit of %d: %w", a.maxApplicationProfileSize, ObjectTooLargeError)
+		return &ProfileTooLargeError{
+			Size:             size,
+			Limit:            a.maxApplicationProfileSize,
+			LargestContainer: largestContainerName(deflated),
+		}
 	}
 
 	// make sure annotations are initialized
@@ -106,19 +214,130 @@ func (a *ApplicationProfileProcessor) SetStorage(containerProfileStorage Contain
 	a.storageImpl = containerProfileStorage
 }
 
+// maxCollapseLevel bounds how far shrinkLargestContainers lowers the
+// collapse thresholds of a single container: each level halves them, so
+// the last level collapses at 1/8 of the default thresholds.
+const maxCollapseLevel = 3
+
+// deflatedContainer tracks a container PreSave has deflated, so it can be
+// deflated again at a more aggressive collapse level.
+type deflatedContainer struct {
+	container *softwarecomposition.ApplicationProfileContainer
+	sbomSet   mapset.Set[string]
+	level     int
+	size      int
+}
+
+// shrinkLargestContainers re-deflates the largest container at the next
+// collapse level until the total size fits in limit or no container can
+// be collapsed further, and returns the new total size. This keeps a
+// single runaway container from failing the save of the whole profile.
+func shrinkLargestContainers(deflated []*deflatedContainer, size, limit int, thresholds collapseThresholds) int {
+	for size > limit {
+		var largest *deflatedContainer
+		for _, d := range deflated {
+			if d.level < maxCollapseLevel && (largest == nil || d.size > largest.size) {
+				largest = d
+			}
+		}
+		if largest == nil {
+			break
+		}
+		largest.level++
+		*largest.container = deflateApplicationProfileContainerAtLevel(*largest.container, largest.sbomSet, thresholds, largest.level)
+		newSize := applicationProfileContainerSize(largest.container)
+		logger.L().Debug("collapsed oversized container",
+			loggerhelpers.String("container", largest.container.Name),
+			loggerhelpers.Int("level", largest.level),
+			loggerhelpers.Int("sizeBefore", largest.size),
+			loggerhelpers.Int("sizeAfter", newSize))
+		size += newSize - largest.size
+		largest.size = newSize
+	}
+	return size
+}
+
+func largestContainerName(deflated []*deflatedContainer) string {
+	var largest *deflatedContainer
+	for _, d := range deflated {
+		if largest == nil || d.size > largest.size {
+			largest = d
+		}
+	}
+	if largest == nil {
+		return ""
+	}
+	return largest.container.Name
+}
+
+// EstimateProfileSize returns the size PreSave checks against
+// MaxApplicationProfileSize and records under ResourceSizeMetadataKey:
+// the number of execs, opens, syscalls, capabilities, endpoints and call
+// stacks over all containers. Nothing is deflated or serialized, so it
+// is cheap enough to pre-check a profile before saving it. For a profile
+// PreSave has already processed it is exactly the recorded size; for a
+// raw one it is an upper bound, since deflation only ever merges entries.
+func EstimateProfileSize(profile *softwarecomposition.ApplicationProfile) int {
+	var size int
+	for _, containers := range [][]softwarecomposition.ApplicationProfileContainer{
+		profile.Spec.EphemeralContainers,
+		profile.Spec.InitContainers,
+		profile.Spec.Containers,
+	} {
+		for i := range containers {
+			size += applicationProfileContainerSize(&containers[i])
+		}
+	}
+	return size
+}
+
+func applicationProfileContainerSize(container *softwarecomposition.ApplicationProfileContainer) int {
+	return len(container.Execs) +
+		len(container.Opens) +
+		len(container.Syscalls) +
+		len(container.Capabilities) +
+		len(container.Endpoints) +
+		len(container.IdentifiedCallStacks)
+}
+
+// scaleThreshold divides threshold by 2^level, but never below 2 so that
+// aggressive collapse produces ⋯ rather than the threshold-1 wildcard. A
+// threshold already at 1 is left as is.
+func scaleThreshold(threshold, level int) int {
+	if threshold <= 1 {
+		return threshold
+	}
+	return max(threshold>>level, 2)
+}
+
+func scaledCollapseConfigs(level int) []dynamicpathdetector.CollapseConfig {
+	configs := dynamicpathdetector.DefaultCollapseConfigs()
+	for i := range configs {
+		configs[i].Threshold = scaleThreshold(configs[i].Threshold, level)
+	}
+	return configs
+}
+
 func deflateApplicationProfileContainer(container softwarecomposition.ApplicationProfileContainer, sbomSet mapset.Set[string]) softwarecomposition.ApplicationProfileContainer {
-	opens, err := dynamicpathdetector.AnalyzeOpens(container.Opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs()), sbomSet)
+	return deflateApplicationProfileContainerAtLevel(container, sbomSet, collapseThresholds{}, 0)
+}
+
+// deflateApplicationProfileContainerAtLevel is deflateApplicationProfileContainer
+// with the given thresholds, scaled down by level (see scaleThreshold).
+// Level 0 uses the thresholds as they are.
+func deflateApplicationProfileContainerAtLevel(container softwarecomposition.ApplicationProfileContainer, sbomSet mapset.Set[string], thresholds collapseThresholds, level int) softwarecomposition.ApplicationProfileContainer {
+	opens, err := dynamicpathdetector.AnalyzeOpens(container.Opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(scaleThreshold(thresholds.opensThreshold(), level), scaledCollapseConfigs(level)), sbomSet)
 	if err != nil {
 		logger.L().Debug("falling back to DeflateStringer for opens", loggerhelpers.Error(err))
 		opens = DeflateStringer(container.Opens)
 	}
-	endpoints := dynamicpathdetector.AnalyzeEndpoints(&container.Endpoints, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil))
+	endpoints := dynamicpathdetector.AnalyzeEndpoints(&container.Endpoints, dynamicpathdetector.NewPathAnalyzerWithConfigs(scaleThreshold(thresholds.endpointsThreshold(), level), nil))
 	identifiedCallStacks := callstack.UnifyIdentifiedCallStacks(container.IdentifiedCallStacks)
 
 	return softwarecomposition.ApplicationProfileContainer{
 		Name:                 container.Name,
 		Capabilities:         DeflateSortString(container.Capabilities),
-		Execs:                DeflateStringer(container.Execs),
+		Execs:                capExecs(deflateExecs(container.Execs, thresholds.execsThreshold(), level), thresholds.maxExecs),
 		Opens:                opens,
 		Syscalls:             DeflateSortString(container.Syscalls),
 		SeccompProfile:       container.SeccompProfile,
@@ -129,3 +348,48 @@ func deflateApplicationProfileContainer(container softwarecomposition.Applicatio
 		IdentifiedCallStacks: identifiedCallStacks,
 	}
 }
+
+// deflateExecs collapses execs with an analyzer at threshold scaled by
+// level, falling back to deduplicating them when that fails.
+func deflateExecs(execs []softwarecomposition.ExecCalls, threshold, level int) []softwarecomposition.ExecCalls {
+	analyzed, err := dynamicpathdetector.AnalyzeExecs(execs, dynamicpathdetector.NewPathAnalyzer(scaleThreshold(threshold, level)))
+	if err != nil {
+		logger.L().Debug("falling back to DeflateStringer for execs", loggerhelpers.Error(err))
+		return DeflateStringer(execs)
+	}
+	return analyzed
+}
+
+// capExecs collapses the args of execs until at most maxExecs entries are
+// left, using the highest arg threshold that fits: positions with more
+// distinct values than it become ⋯ and arg vectors extending a shared
+// prefix become prefix *, per exec path (see
+// dynamicpathdetector.AnalyzeExecsWithTailCollapse). Paths are left as
+// they are. When even a threshold of 1 does not fit, which only happens
+// with more distinct paths than maxExecs, that most collapsed result is
+// returned. A non-positive maxExecs disables the cap.
+func capExecs(execs []softwarecomposition.ExecCalls, maxExecs int) []softwarecomposition.ExecCalls {
+	if maxExecs <= 0 || len(execs) <= maxExecs {
+		return execs
+	}
+	collapse := func(threshold int) []softwarecomposition.ExecCalls {
+		collapsed, err := dynamicpathdetector.AnalyzeExecsWithTailCollapse(execs, dynamicpathdetector.NewPathAnalyzer(math.MaxInt), threshold, threshold)
+		if err != nil {
+			logger.L().Debug("leaving execs uncapped", loggerhelpers.Error(err))
+			return execs
+		}
+		return collapsed
+	}
+	// a lower threshold only ever merges more entries, so search for the
+	// highest one that fits; at len(execs) nothing collapses
+	lo, hi := 1, len(execs)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if len(collapse(mid)) <= maxExecs {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return collapse(lo)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/kubescape/storage/pkg/config"
 	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -118,8 +120,8 @@ func TestApplicationProfileProcessor_PreSave(t *testing.T) {
 						{
 							Name: "container1",
 							Execs: []softwarecomposition.ExecCalls{
-								{Path: "/usr/bin/ls", Args: []string{"-l", "/tmp"}},
 								{Path: "/usr/bin/ls", Args: []string{"-l", "/home"}},
+								{Path: "/usr/bin/ls", Args: []string{"-l", "/tmp"}},
 							},
 						},
 						{
@@ -163,6 +165,100 @@ func TestApplicationProfileProcessor_PreSave(t *testing.T) {
 	}
 }
 
+func TestApplicationProfileProcessor_PreSaveTooLargeError(t *testing.T) {
+	a := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 5})
+	err := a.PreSave(context.TODO(), ap.DeepCopy())
+
+	var tooLarge *ProfileTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, &ProfileTooLargeError{Size: 7, Limit: 5, LargestContainer: "container2"}, tooLarge)
+	assert.ErrorIs(t, err, ObjectTooLargeError, "callers matching ObjectTooLargeError must keep working")
+}
+
+func TestApplicationProfileProcessor_PreSaveSizeIsPostCollapse(t *testing.T) {
+	var opens []softwarecomposition.OpenCalls
+	for i := 0; i <= openThreshold(); i++ {
+		opens = append(opens, softwarecomposition.OpenCalls{Path: fmt.Sprintf("/data/file%d", i), Flags: []string{"O_RDONLY"}})
+	}
+	profile := &softwarecomposition.ApplicationProfile{
+		Spec: softwarecomposition.ApplicationProfileSpec{
+			Containers: []softwarecomposition.ApplicationProfileContainer{
+				{Name: "app", Execs: []softwarecomposition.ExecCalls{{Path: "/bin/sh"}}, Opens: opens},
+			},
+		},
+	}
+	require.Greater(t, EstimateProfileSize(profile), 2)
+
+	a := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 40000})
+	require.NoError(t, a.PreSave(context.TODO(), profile))
+
+	assert.Equal(t, []softwarecomposition.OpenCalls{{Path: "/data/⋯", Flags: []string{"O_RDONLY"}}}, profile.Spec.Containers[0].Opens)
+	assert.Equal(t, "2", profile.Annotations[helpers.ResourceSizeMetadataKey], "one exec and the collapsed open")
+}
+
+func TestApplicationProfileProcessor_PreSaveCapsExecs(t *testing.T) {
+	execs := []softwarecomposition.ExecCalls{{Path: "/bin/sh", Args: []string{"-c", "date"}}}
+	for i := 0; i < 100; i++ {
+		execs = append(execs, softwarecomposition.ExecCalls{Path: "/usr/bin/curl", Args: []string{"-s", fmt.Sprintf("https://example.com/items/%d", i)}})
+	}
+	newProfile := func() *softwarecomposition.ApplicationProfile {
+		return &softwarecomposition.ApplicationProfile{
+			Spec: softwarecomposition.ApplicationProfileSpec{
+				Containers: []softwarecomposition.ApplicationProfileContainer{{Name: "main", Execs: slices.Clone(execs)}},
+			},
+		}
+	}
+
+	profile := newProfile()
+	processor := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 100000})
+	require.NoError(t, processor.PreSave(context.TODO(), profile))
+	assert.Len(t, profile.Spec.Containers[0].Execs, 101, "execs are not capped by default")
+
+	profile = newProfile()
+	processor = NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 100000, MaxExecsPerContainer: 5})
+	require.NoError(t, processor.PreSave(context.TODO(), profile))
+	assert.Equal(t, []softwarecomposition.ExecCalls{
+		{Path: "/bin/sh", Args: []string{"-c", "date"}},
+		{Path: "/usr/bin/curl", Args: []string{"-s", "⋯"}},
+	}, profile.Spec.Containers[0].Execs)
+}
+
+func TestCapExecs(t *testing.T) {
+	var execs []softwarecomposition.ExecCalls
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 10; j++ {
+			execs = append(execs, softwarecomposition.ExecCalls{Path: "/usr/bin/git", Args: []string{fmt.Sprintf("sub%d", i), fmt.Sprintf("arg%d", j)}})
+		}
+	}
+
+	assert.Equal(t, execs, capExecs(execs, 0), "a zero cap disables it")
+	assert.Equal(t, execs, capExecs(execs, len(execs)), "execs within the cap are left alone")
+
+	capped := capExecs(execs, 4)
+	assert.Len(t, capped, 4, "the second arg collapses, the four subcommands stay")
+	for _, exec := range capped {
+		assert.Equal(t, "⋯", exec.Args[1])
+	}
+	assert.Len(t, capExecs(execs, 1), 1, "with a tighter cap the subcommands collapse too")
+
+	distinct := []softwarecomposition.ExecCalls{{Path: "/bin/a"}, {Path: "/bin/b"}, {Path: "/bin/c"}}
+	assert.Equal(t, distinct, capExecs(distinct, 2), "paths are never collapsed to fit")
+}
+
+func TestEstimateProfileSize(t *testing.T) {
+	profile := ap.DeepCopy()
+	raw := EstimateProfileSize(profile)
+
+	a := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 40000})
+	require.NoError(t, a.PreSave(context.TODO(), profile))
+	saved := EstimateProfileSize(profile)
+	assert.Equal(t, profile.Annotations[helpers.ResourceSizeMetadataKey], strconv.Itoa(saved))
+	assert.Equal(t, 7, saved)
+	assert.GreaterOrEqual(t, raw, saved, "a raw profile's estimate is an upper bound")
+
+	assert.Zero(t, EstimateProfileSize(&softwarecomposition.ApplicationProfile{}))
+}
+
 func TestDeflateRulePolicies(t *testing.T) {
 	tests := []struct {
 		name string
@@ -456,3 +552,166 @@ func TestDeflateApplicationProfileContainer_PreSaveEndToEnd(t *testing.T) {
 	}
 	assert.True(t, hasCollapsed, "at least one path should contain a dynamic/wildcard segment after PreSave")
 }
+
+// TestApplicationProfileProcessor_PreSaveShrinksOversizedContainer checks
+// that one container whose opens stay below the default collapse threshold,
+// but blow the profile budget, is collapsed harder instead of failing the
+// whole save, while the small containers next to it are left alone.
+func TestApplicationProfileProcessor_PreSaveShrinksOversizedContainer(t *testing.T) {
+	var bigOpens []softwarecomposition.OpenCalls
+	for i := 0; i < openThreshold()-10; i++ {
+		bigOpens = append(bigOpens, softwarecomposition.OpenCalls{Path: fmt.Sprintf("/data/file%d", i), Flags: []string{"O_RDONLY"}})
+	}
+	small := func(name string) softwarecomposition.ApplicationProfileContainer {
+		return softwarecomposition.ApplicationProfileContainer{
+			Name: name,
+			Opens: []softwarecomposition.OpenCalls{
+				{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+				{Path: "/etc/resolv.conf", Flags: []string{"O_RDONLY"}},
+			},
+		}
+	}
+	profile := &softwarecomposition.ApplicationProfile{
+		Spec: softwarecomposition.ApplicationProfileSpec{
+			Containers: []softwarecomposition.ApplicationProfileContainer{
+				small("small1"),
+				{Name: "big", Opens: bigOpens},
+				small("small2"),
+				small("small3"),
+			},
+		},
+	}
+
+	processor := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 20})
+	assert.NoError(t, processor.PreSave(context.TODO(), profile))
+
+	assert.Equal(t, []softwarecomposition.OpenCalls{{Path: "/data/⋯", Flags: []string{"O_RDONLY"}}}, profile.Spec.Containers[1].Opens)
+	for _, i := range []int{0, 2, 3} {
+		assert.Len(t, profile.Spec.Containers[i].Opens, 2, "small container %s must not be collapsed", profile.Spec.Containers[i].Name)
+	}
+	assert.Equal(t, "7", profile.Annotations[helpers.ResourceSizeMetadataKey])
+}
+
+func TestApplicationProfileProcessor_PreSaveFailsWhenCollapseCannotHelp(t *testing.T) {
+	var syscalls []string
+	for i := 0; i < 30; i++ {
+		syscalls = append(syscalls, fmt.Sprintf("syscall%d", i))
+	}
+	profile := &softwarecomposition.ApplicationProfile{
+		Spec: softwarecomposition.ApplicationProfileSpec{
+			Containers: []softwarecomposition.ApplicationProfileContainer{
+				{Name: "big", Syscalls: syscalls},
+			},
+		},
+	}
+
+	processor := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 20})
+	assert.ErrorIs(t, processor.PreSave(context.TODO(), profile), ObjectTooLargeError)
+}
+
+func TestApplicationProfileProcessor_PreSaveDynamicStyle(t *testing.T) {
+	for style, want := range map[string]string{
+		"":                     "/data/⋯/log",
+		"ellipsis":             "/data/⋯/log",
+		"singleStarPerSegment": "/data/⋯/log",
+		"doubleStar":           "/data/*/log",
+		"bogus":                "/data/⋯/log",
+	} {
+		t.Run(style, func(t *testing.T) {
+			var opens []softwarecomposition.OpenCalls
+			for i := 0; i < openThreshold()+1; i++ {
+				opens = append(opens, softwarecomposition.OpenCalls{Path: fmt.Sprintf("/data/job%d/log", i), Flags: []string{"O_RDONLY"}})
+			}
+			profile := &softwarecomposition.ApplicationProfile{
+				Spec: softwarecomposition.ApplicationProfileSpec{
+					Containers: []softwarecomposition.ApplicationProfileContainer{{Name: "main", Opens: opens}},
+				},
+			}
+			processor := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 100000, DynamicStyle: style})
+			require.NoError(t, processor.PreSave(context.TODO(), profile))
+			assert.Equal(t, []softwarecomposition.OpenCalls{{Path: want, Flags: []string{"O_RDONLY"}}}, profile.Spec.Containers[0].Opens)
+		})
+	}
+}
+
+// TestApplicationProfileProcessor_PreSaveDynamicStyleTwice saves a styled
+// profile again, as every update does, with a new file next to a stored
+// wildcard: the stored paths must read back as the ⋯ and * they were
+// written from.
+func TestApplicationProfileProcessor_PreSaveDynamicStyleTwice(t *testing.T) {
+	for style, want := range map[string][]string{
+		"ellipsis":   {"/data/⋯/log", "/etc/ssl/*"},
+		"doubleStar": {"/data/*/log", "/etc/ssl/**"},
+	} {
+		t.Run(style, func(t *testing.T) {
+			// Globs sent in are written in the style, like stored paths.
+			glob := dynamicpathdetector.FormatDynamicPath("/etc/ssl/*", dynamicpathdetector.DynamicStyle(style))
+			opens := []softwarecomposition.OpenCalls{{Path: glob, Flags: []string{"O_RDONLY"}}}
+			for i := 0; i < openThreshold()+1; i++ {
+				opens = append(opens, softwarecomposition.OpenCalls{Path: fmt.Sprintf("/data/job%d/log", i), Flags: []string{"O_RDONLY"}})
+			}
+			profile := &softwarecomposition.ApplicationProfile{
+				Spec: softwarecomposition.ApplicationProfileSpec{
+					Containers: []softwarecomposition.ApplicationProfileContainer{{Name: "main", Opens: opens}},
+				},
+			}
+			processor := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 100000, DynamicStyle: style})
+			paths := func() []string {
+				var paths []string
+				for _, open := range profile.Spec.Containers[0].Opens {
+					paths = append(paths, open.Path)
+				}
+				return paths
+			}
+
+			require.NoError(t, processor.PreSave(context.TODO(), profile))
+			assert.ElementsMatch(t, want, paths())
+
+			profile.Spec.Containers[0].Opens = append(profile.Spec.Containers[0].Opens, softwarecomposition.OpenCalls{Path: "/etc/ssl/ca.pem", Flags: []string{"O_RDONLY"}})
+			require.NoError(t, processor.PreSave(context.TODO(), profile))
+			assert.ElementsMatch(t, want, paths())
+		})
+	}
+}
+
+func TestApplicationProfileProcessor_PreSaveCustomThresholds(t *testing.T) {
+	newProfile := func() *softwarecomposition.ApplicationProfile {
+		var opens []softwarecomposition.OpenCalls
+		var execs []softwarecomposition.ExecCalls
+		var endpoints []softwarecomposition.HTTPEndpoint
+		for i := 0; i < 6; i++ {
+			opens = append(opens, softwarecomposition.OpenCalls{Path: fmt.Sprintf("/data/job%d/log", i), Flags: []string{"O_RDONLY"}})
+			execs = append(execs, softwarecomposition.ExecCalls{Path: fmt.Sprintf("/tmp/run%d/python", i)})
+			endpoints = append(endpoints, softwarecomposition.HTTPEndpoint{Endpoint: fmt.Sprintf(":443/users/%d", i), Methods: []string{"GET"}})
+		}
+		return &softwarecomposition.ApplicationProfile{
+			Spec: softwarecomposition.ApplicationProfileSpec{
+				Containers: []softwarecomposition.ApplicationProfileContainer{{Name: "main", Opens: opens, Execs: execs, Endpoints: endpoints}},
+			},
+		}
+	}
+
+	profile := newProfile()
+	processor := NewApplicationProfileProcessor(config.Config{DefaultNamespace: "kubescape", MaxApplicationProfileSize: 100000})
+	require.NoError(t, processor.PreSave(context.TODO(), profile))
+	container := profile.Spec.Containers[0]
+	assert.Len(t, container.Opens, 6, "default threshold keeps opens literal")
+	assert.Len(t, container.Execs, 6, "default threshold keeps execs literal")
+	assert.Len(t, container.Endpoints, 6, "default threshold keeps endpoints literal")
+
+	profile = newProfile()
+	processor = NewApplicationProfileProcessor(config.Config{
+		DefaultNamespace:          "kubescape",
+		MaxApplicationProfileSize: 100000,
+		MaxOpensThreshold:         5,
+		MaxEndpointsThreshold:     5,
+		MaxExecsThreshold:         5,
+	})
+	require.NoError(t, processor.PreSave(context.TODO(), profile))
+	container = profile.Spec.Containers[0]
+	assert.Equal(t, []softwarecomposition.OpenCalls{{Path: "/data/⋯/log", Flags: []string{"O_RDONLY"}}}, container.Opens)
+	require.Len(t, container.Execs, 1)
+	assert.Equal(t, "/tmp/⋯/python", container.Execs[0].Path)
+	require.Len(t, container.Endpoints, 1)
+	assert.Equal(t, ":443/users/⋯", container.Endpoints[0].Endpoint)
+}
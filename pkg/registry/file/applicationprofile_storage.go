@@ -9,6 +9,7 @@ import (
 	loggerhelpers "github.com/kubescape/go-logger/helpers"
 	helpersv1 "github.com/kubescape/k8s-interface/instanceidhandler/v1/helpers"
 	"github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/utils"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/apiserver/pkg/storage"
@@ -103,7 +104,7 @@ func (a ApplicationProfileStorage) Get(ctx context.Context, key string, opts sto
 				return fmt.Errorf("unknown container type: %s", cp.Annotations[helpersv1.ContainerTypeMetadataKey])
 			}
 		}
-		ap.Spec.Architectures = DeflateSortString(architectures)
+		ap.Spec.Architectures = utils.DeflateStringSlice(architectures)
 		ap.Annotations[helpersv1.ResourceSizeMetadataKey] = strconv.Itoa(size)
 	}
 	return nil
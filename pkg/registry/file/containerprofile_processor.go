@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -39,6 +40,7 @@ type ContainerProfileProcessor struct {
 	CleanupInterval         time.Duration
 	DefaultNamespace        string
 	DeleteThreshold         time.Duration
+	DynamicStyle            dynamicpathdetector.DynamicStyle
 	HostType                armotypes.HostType
 	Interval                time.Duration
 	LastCleanup             time.Time
@@ -57,6 +59,7 @@ func NewContainerProfileProcessor(cfg config.Config, cleanupHandler *ResourcesCl
 		CleanupInterval:         cfg.CleanupInterval,
 		DefaultNamespace:        cfg.DefaultNamespace,
 		DeleteThreshold:         2 * cfg.MaxSniffingTime,
+		DynamicStyle:            parseDynamicStyle(cfg.DynamicStyle),
 		HostType:                hostType,
 		Interval:                30 * time.Second,
 		MaxContainerProfileSize: cfg.MaxApplicationProfileSize,
@@ -146,7 +149,9 @@ func (a *ContainerProfileProcessor) PreSave(ctx context.Context, object runtime.
 
 	}
 
-	// size is the sum of all fields in all containers
+	// size is the sum of all fields, counted after deflation: it is the
+	// number of entries actually stored, and what ResourceSizeMetadataKey
+	// records
 	var size int
 
 	var sbomSet mapset.Set[string]
@@ -178,7 +183,11 @@ func (a *ContainerProfileProcessor) PreSave(ctx context.Context, object runtime.
 	} else {
 		logger.L().Debug("ContainerProfileProcessor.PreSave - failed to get sbom name", loggerhelpers.Error(err), loggerhelpers.String("imageTag", profile.Spec.ImageTag), loggerhelpers.String("imageID", profile.Spec.ImageID))
 	}
+	unstyleOpens(profile.Spec.Opens, a.DynamicStyle)
+	unstyleEndpoints(profile.Spec.Endpoints, a.DynamicStyle)
 	profile.Spec = DeflateContainerProfileSpec(profile.Spec, sbomSet)
+	styleOpens(profile.Spec.Opens, a.DynamicStyle)
+	styleEndpoints(profile.Spec.Endpoints, a.DynamicStyle)
 	size += len(profile.Spec.Execs)
 	size += len(profile.Spec.Opens)
 	size += len(profile.Spec.Syscalls)
@@ -188,15 +197,14 @@ func (a *ContainerProfileProcessor) PreSave(ctx context.Context, object runtime.
 	size += len(profile.Spec.Ingress)
 	size += len(profile.Spec.Egress)
 
-	if size > a.MaxContainerProfileSize {
-		// set annotation but don't return an error as we want to save the profile anyway
-		profile.Annotations[helpers.StatusMetadataKey] = helpers.TooLarge
-	}
-
 	// make sure annotations are initialized
 	if profile.Annotations == nil {
 		profile.Annotations = make(map[string]string)
 	}
+	if size > a.MaxContainerProfileSize {
+		// set annotation but don't return an error as we want to save the profile anyway
+		profile.Annotations[helpers.StatusMetadataKey] = helpers.TooLarge
+	}
 	profile.Annotations[helpers.ResourceSizeMetadataKey] = strconv.Itoa(size)
 
 	return nil
@@ -752,9 +760,9 @@ func DeflateContainerProfileSpec(container softwarecomposition.ContainerProfileS
 	identifiedCallStacks := callstack.UnifyIdentifiedCallStacks(container.IdentifiedCallStacks)
 
 	return softwarecomposition.ContainerProfileSpec{
-		Architectures:        DeflateSortString(container.Architectures),
+		Architectures:        utils.DeflateStringSlice(container.Architectures),
 		Capabilities:         DeflateSortString(container.Capabilities),
-		Execs:                DeflateStringer(container.Execs),
+		Execs:                deflateExecs(container.Execs, dynamicpathdetector.ExecDynamicThreshold, 0),
 		Opens:                opens,
 		Syscalls:             DeflateSortString(container.Syscalls),
 		SeccompProfile:       container.SeccompProfile,
@@ -818,20 +826,10 @@ func mergeContainerProfileTS(profile, tsProfile *softwarecomposition.ContainerPr
 
 // mergePolicies is copied from node-agent but works on the softwarecomposition internal type
 func mergePolicies(primary, secondary softwarecomposition.RulePolicy) softwarecomposition.RulePolicy {
-	mergedPolicy := softwarecomposition.RulePolicy{
+	return softwarecomposition.RulePolicy{
 		AllowedContainer: primary.AllowedContainer || secondary.AllowedContainer,
+		AllowedProcesses: utils.DeflateStringSlice(slices.Concat(primary.AllowedProcesses, secondary.AllowedProcesses)),
 	}
-	processes := mapset.NewSet[string]()
-	for _, process := range primary.AllowedProcesses {
-		processes.Add(process)
-	}
-	for _, process := range secondary.AllowedProcesses {
-		processes.Add(process)
-	}
-	for process := range processes.Iter() {
-		mergedPolicy.AllowedProcesses = append(mergedPolicy.AllowedProcesses, process)
-	}
-	return mergedPolicy
 }
 
 func SplitProfileName(profileName string) (name string, tsSuffix string) {
@@ -3,6 +3,7 @@ package file
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -11,6 +12,7 @@ import (
 	helpersv1 "github.com/kubescape/k8s-interface/instanceidhandler/v1/helpers"
 	"github.com/kubescape/storage/pkg/apis/softwarecomposition"
 	"github.com/kubescape/storage/pkg/generated/clientset/versioned/scheme"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
 	"github.com/kubescape/storage/pkg/utils"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -148,6 +150,17 @@ func Test_isZeroTime(t *testing.T) {
 	}
 }
 
+func TestDeflateContainerProfileSpecCollapsesExecs(t *testing.T) {
+	var spec softwarecomposition.ContainerProfileSpec
+	for i := 0; i < 2*dynamicpathdetector.ExecDynamicThreshold; i++ {
+		spec.Execs = append(spec.Execs, softwarecomposition.ExecCalls{Path: fmt.Sprintf("/tmp/run%d/python", i), Args: []string{"main.py"}})
+	}
+	spec.Execs = append(spec.Execs, spec.Execs[0])
+
+	deflated := DeflateContainerProfileSpec(spec, nil)
+	assert.Equal(t, []softwarecomposition.ExecCalls{{Path: "/tmp/⋯/python", Args: []string{"main.py"}}}, deflated.Execs)
+}
+
 func TestSendConsolidatedSlugToChannel(t *testing.T) {
 	t.Skip("Skipping send consolidated slug to channel test")
 	tests := []struct {
@@ -299,3 +312,14 @@ func TestSendConsolidatedSlugToChannel(t *testing.T) {
 		})
 	}
 }
+
+func TestMergePolicies(t *testing.T) {
+	merged := mergePolicies(
+		softwarecomposition.RulePolicy{AllowedProcesses: []string{"ls", "cat", "ls"}},
+		softwarecomposition.RulePolicy{AllowedContainer: true, AllowedProcesses: []string{"bash", "cat"}},
+	)
+	assert.True(t, merged.AllowedContainer)
+	assert.Equal(t, []string{"bash", "cat", "ls"}, merged.AllowedProcesses, "sorted and deduplicated like DeflateRulePolicies")
+
+	assert.Nil(t, mergePolicies(softwarecomposition.RulePolicy{}, softwarecomposition.RulePolicy{}).AllowedProcesses)
+}
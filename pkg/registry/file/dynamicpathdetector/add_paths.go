@@ -0,0 +1,32 @@
+package dynamicpathdetector
+
+// AddPaths inserts paths into the trie for identifier, with the same
+// effect as calling AnalyzePath for each of them in order and discarding
+// the results. It is the cheaper way to train an analyzer: the lock is
+// taken once, no result strings are built, and CollapseConfig lookups
+// for the directories a path shares with the one before it are reused
+// rather than resolved again, which pays off when paths arrive grouped
+// by directory. Order is kept, since collapse decisions depend on it.
+func (ua *PathAnalyzer) AddPaths(paths []string, identifier string) {
+	if len(paths) == 0 {
+		return
+	}
+	ua.mu.Lock()
+	defer ua.mu.Unlock()
+	node, exists := ua.RootNodes[identifier]
+	if !exists {
+		node = newSegmentNode(identifier)
+		ua.RootNodes[identifier] = node
+	}
+
+	bufPtr := bufPool.Get().(*[]byte)
+	buf := (*bufPtr)[:0]
+	configs := &configResolver{configs: ua.configsFor(identifier), total: ua.totals[identifier], batch: true}
+	for _, p := range paths {
+		p, dir := ua.cleanPath(p)
+		configs.next(p)
+		buf = ua.walkPath(node, configs, p, dir, walkCount, buf[:0])
+	}
+	*bufPtr = buf[:0]
+	bufPool.Put(bufPtr)
+}
@@ -0,0 +1,95 @@
+package dynamicpathdetector
+
+import "strings"
+
+// A dynamic run is a single trie node standing for several consecutive ⋯
+// levels. With CollapseAdjacent set, processSegments merges a ⋯ node into
+// its ⋯ parent, so a profile full of /a/⋯/⋯/⋯/b paths keeps one node and
+// one Children map for the whole run instead of one per level. The run is
+// stored under the ⋯ key like a plain ⋯ node, so every lookup by
+// DynamicIdentifier finds it; its SegmentName spells out the levels it
+// covers ("⋯/⋯/⋯"), which keeps GetStoredPaths and AnalyzePath output the
+// same as for the unmerged chain. Only the last level of a run carries
+// real Count, Terminal and children: the levels before it each had exactly
+// one child, the next ⋯, and no path ending at them.
+
+// dynamicSpan returns how many ⋯ levels a node named name stands for: 1
+// for a plain ⋯, the run length for a merged run and 0 for anything else.
+// Does not allocate; it runs for every segment walked.
+func (ua *PathAnalyzer) dynamicSpan(name string) int {
+	dyn := ua.dynamicIdentifier
+	if name == dyn {
+		return 1
+	}
+	span := 0
+	for i := 0; ; i += len(dyn) + 1 {
+		if len(name) < i+len(dyn) || name[i:i+len(dyn)] != dyn {
+			return 0
+		}
+		span++
+		switch {
+		case len(name) == i+len(dyn):
+			return span
+		case name[i+len(dyn)] != '/':
+			return 0
+		}
+	}
+}
+
+// dynamicRunName is the SegmentName of a run covering span ⋯ levels.
+func (ua *PathAnalyzer) dynamicRunName(span int) string {
+	return strings.Repeat(ua.dynamicIdentifier+"/", span-1) + ua.dynamicIdentifier
+}
+
+// canMergeDynamicRun reports whether child, just reached from parent, can
+// be folded into it: both must be ⋯ nodes or runs, and parent must have no
+// other child and no path ending at it, so that nothing but its last level
+// is lost. root is never merged; its SegmentName is the identifier.
+func (ua *PathAnalyzer) canMergeDynamicRun(root, parent, child *SegmentNode) bool {
+	return parent != root && !parent.Terminal && !parent.Dir && len(parent.Children) == 1 &&
+		ua.dynamicSpan(parent.SegmentName) > 0 && ua.dynamicSpan(child.SegmentName) > 0
+}
+
+// mergeDynamicRun folds child into parent, which becomes a run covering
+// both. parent keeps its place in the trie, so the caller's pointers to it
+// stay valid.
+func (ua *PathAnalyzer) mergeDynamicRun(parent, child *SegmentNode) {
+	parent.SegmentName = ua.dynamicRunName(ua.dynamicSpan(parent.SegmentName) + ua.dynamicSpan(child.SegmentName))
+	parent.Count = child.Count
+	parent.Children = child.Children
+	parent.Terminal = child.Terminal
+	parent.Dir = child.Dir
+	parent.Hits = child.Hits
+}
+
+// splitDynamicRun cuts the run node after its first span levels, for a
+// walk that has to stop or branch inside it. node keeps its place and
+// becomes the head; a new node holding the remaining levels and node's
+// children becomes its only child.
+func (ua *PathAnalyzer) splitDynamicRun(node *SegmentNode, span int) {
+	tail := newSegmentNode(ua.dynamicRunName(ua.dynamicSpan(node.SegmentName) - span))
+	tail.Count = node.Count
+	tail.Children = node.Children
+	tail.Terminal = node.Terminal
+	tail.Dir = node.Dir
+	tail.Hits = node.Hits
+
+	node.SegmentName = ua.dynamicRunName(span)
+	node.Count = 1
+	node.Children = nil
+	node.Terminal = false
+	node.Dir = false
+	node.setChild(ua.dynamicIdentifier, tail)
+}
+
+// alignDynamicRuns splits whichever of a and b is the longer run so both
+// cover the same number of levels and can be merged level by level.
+func (ua *PathAnalyzer) alignDynamicRuns(a, b *SegmentNode) {
+	spanA, spanB := ua.dynamicSpan(a.SegmentName), ua.dynamicSpan(b.SegmentName)
+	switch {
+	case spanA > spanB && spanB > 0:
+		ua.splitDynamicRun(a, spanB)
+	case spanB > spanA && spanA > 0:
+		ua.splitDynamicRun(b, spanA)
+	}
+}
@@ -1,39 +1,219 @@
 package dynamicpathdetector
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"net/http"
+	"net/netip"
 	"net/url"
+	"path"
+	"slices"
 	"strings"
 
-	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/kubescape/go-logger"
 	loggerhelpers "github.com/kubescape/go-logger/helpers"
 	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/apis/softwarecomposition/consts"
+	"github.com/kubescape/storage/pkg/utils"
 )
 
 func isWildcardPort(port string) bool {
 	return port == "0"
 }
 
+// AnyHTTPPort is the port AnalyzeEndpointsWithMergedSchemes stores
+// endpoints seen on the well-known HTTP and HTTPS ports under, as in
+// ":http/api": served over either scheme, whichever port.
+const AnyHTTPPort = "http"
+
+// httpSchemePorts are the ports AnalyzeEndpointsWithMergedSchemes merges.
+var httpSchemePorts = []string{"80", "443"}
+
 func AnalyzeEndpoints(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer) []types.HTTPEndpoint {
+	return AnalyzeEndpointsWithQueryThreshold(endpoints, analyzer, 0)
+}
+
+// AnalyzeEndpointsWithQueryThreshold is AnalyzeEndpoints with query-string
+// handling. AnalyzeEndpoints drops the query entirely; here the query keys
+// are kept and appended to the endpoint as a normalized `?key=value&...`
+// (keys and values sorted). When a key is seen with more than
+// queryThreshold distinct values across all endpoints, its values collapse
+// to the analyzer's dynamic identifier, so `?token=<random>` traffic folds
+// into a single `?token=⋯` entry. A non-positive queryThreshold keeps the
+// AnalyzeEndpoints behavior.
+func AnalyzeEndpointsWithQueryThreshold(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer, queryThreshold int) []types.HTTPEndpoint {
+	return analyzeEndpoints(endpoints, analyzer, analyzeEndpointsOpts{queryThreshold: queryThreshold})
+}
+
+// AnalyzeEndpointsWithExamples is AnalyzeEndpoints that also returns one
+// concrete example for every collapsed endpoint, keyed by the collapsed
+// Endpoint string, so an alert on `:80/users/⋯` can show `:80/users/123`
+// next to it. The example is the lexically smallest original Endpoint
+// folded into the entry, so it does not depend on input order. Entries
+// whose Endpoint has no ⋯ or * get no example; endpoints sharing an
+// Endpoint across Direction or Internal share one example.
+func AnalyzeEndpointsWithExamples(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer) ([]types.HTTPEndpoint, map[string]string) {
+	examples := make(map[string]string)
+	result := analyzeEndpoints(endpoints, analyzer, analyzeEndpointsOpts{examples: examples})
+	return result, collapsedExamples(result, examples, analyzer)
+}
+
+// AnalyzeEndpointsWithStandardMethods is AnalyzeEndpoints that also drops
+// every method that is not a standard HTTP verb (GET, HEAD, POST, PUT,
+// PATCH, DELETE, CONNECT, OPTIONS, TRACE), after uppercasing. Endpoints
+// are kept even when none of their methods survive.
+func AnalyzeEndpointsWithStandardMethods(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer) []types.HTTPEndpoint {
+	return analyzeEndpoints(endpoints, analyzer, analyzeEndpointsOpts{standardMethodsOnly: true})
+}
+
+// AnalyzeEndpointsWithHeaderThreshold is AnalyzeEndpoints with a cap on
+// merged header values: when a header key of a resulting endpoint has
+// more than headerThreshold distinct values, they are replaced by the
+// analyzer's dynamic identifier alone, so a per-request header like
+// X-Request-Id is stored as ["⋯"] instead of growing with every request.
+// A key already holding ⋯ stays collapsed when new values merge in. A
+// non-positive headerThreshold keeps every value, as AnalyzeEndpoints
+// does.
+func AnalyzeEndpointsWithHeaderThreshold(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer, headerThreshold int) []types.HTTPEndpoint {
+	return analyzeEndpoints(endpoints, analyzer, analyzeEndpointsOpts{headerThreshold: headerThreshold})
+}
+
+// AnalyzeEndpointsWithHosts is AnalyzeEndpoints that keeps the host of
+// outbound endpoints recorded with an IPv4 address ("10.0.0.5:443/api"),
+// which AnalyzeEndpoints drops. When more than hostThreshold distinct
+// addresses of one /24 block are seen on the same port, each of them is
+// replaced by the block, so outbound calls to 10.0.0.1 … 10.0.0.9 on 443
+// fold into `10.0.0.0/24:443/...`. Paths collapse per port as in
+// AnalyzeEndpoints, whatever the host. Inbound endpoints and names or
+// IPv6 hosts are stored without a host, as AnalyzeEndpoints stores them.
+// A non-positive hostThreshold keeps the AnalyzeEndpoints behavior.
+func AnalyzeEndpointsWithHosts(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer, hostThreshold int) []types.HTTPEndpoint {
+	return analyzeEndpoints(endpoints, analyzer, analyzeEndpointsOpts{hostThreshold: hostThreshold})
+}
+
+// AnalyzeEndpointsWithMergedSchemes is AnalyzeEndpoints for apps serving
+// the same API over HTTP and HTTPS: endpoints on port 80 and 443 are
+// stored on the scheme-agnostic AnyHTTPPort, so `:80/api` and `:443/api`
+// become one `:http/api` entry with the methods and headers of both, and
+// their paths collapse together. Endpoints recorded with a host, and
+// every other port, are left on their port.
+func AnalyzeEndpointsWithMergedSchemes(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer) []types.HTTPEndpoint {
+	return analyzeEndpoints(endpoints, analyzer, analyzeEndpointsOpts{mergeSchemes: true})
+}
+
+// AnalyzeEndpointsWithTemplates is AnalyzeEndpoints for services with an
+// OpenAPI spec: templates are its path templates, such as `/users/{id}`,
+// and an endpoint whose path matches one is stored under the template
+// with every segment holding a parameter replaced by the dynamic
+// identifier, `/users/⋯`, on the first request rather than once the
+// threshold is crossed. A parameter matches any non-empty text, so
+// `/files/{name}.json` matches `/files/a.json` but not `/files/a.txt`.
+// When several templates match, the most specific by MoreSpecific wins,
+// and among equals the first listed, so a declared `/users/me` stays
+// literal next to `/users/{id}`. Matched endpoints are not fed to
+// analyzer; the rest collapse by threshold as in AnalyzeEndpoints.
+// Templates apply on every port.
+func AnalyzeEndpointsWithTemplates(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer, templates []string) []types.HTTPEndpoint {
+	return analyzeEndpoints(endpoints, analyzer, analyzeEndpointsOpts{templates: templates})
+}
+
+// AnalyzeEndpointsBatch is AnalyzeEndpoints for the containers of one
+// pod, keyed by container name, that share what they learn: every
+// container's endpoints are fed to analyzer before any is analyzed, so a
+// backend path whose cardinality is spread across containers collapses
+// in all of them, even when no single container exceeds the threshold.
+// Each container still gets its own result; containers without
+// endpoints map to nil, and nil slices are skipped.
+func AnalyzeEndpointsBatch(endpoints map[string]*[]types.HTTPEndpoint, analyzer *PathAnalyzer) map[string][]types.HTTPEndpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(endpoints))
+	for _, name := range names {
+		if endpoints[name] == nil {
+			continue
+		}
+		// AnalyzeEndpoints counts each endpoint in Hits below.
+		for _, endpoint := range *endpoints[name] {
+			_, _ = analyzeURL(endpoint.Endpoint, analyzer, nil, nil, walkRevisit)
+		}
+	}
+	result := make(map[string][]types.HTTPEndpoint, len(endpoints))
+	for _, name := range names {
+		if endpoints[name] == nil {
+			continue
+		}
+		result[name] = AnalyzeEndpoints(endpoints[name], analyzer)
+	}
+	return result
+}
+
+// analyzeEndpointsOpts carries the knobs of the exported AnalyzeEndpoints
+// variants; the zero value is AnalyzeEndpoints. When examples is non-nil,
+// analyzeEndpoints records in it, for every rewritten Endpoint, the
+// smallest original Endpoint rewritten to it.
+type analyzeEndpointsOpts struct {
+	queryThreshold      int
+	headerThreshold     int
+	hostThreshold       int
+	examples            map[string]string
+	templates           []string
+	standardMethodsOnly bool
+	mergeSchemes        bool
+}
+
+// schemePort returns endpoint moved to AnyHTTPPort when it is on one of
+// httpSchemePorts and opts.mergeSchemes is set, and endpoint otherwise.
+func (opts analyzeEndpointsOpts) schemePort(endpoint string) string {
+	if !opts.mergeSchemes {
+		return endpoint
+	}
+	for _, port := range httpSchemePorts {
+		if rest, ok := strings.CutPrefix(endpoint, ":"+port); ok && (rest == "" || rest[0] == '/' || rest[0] == '?') {
+			return ":" + AnyHTTPPort + rest
+		}
+	}
+	return endpoint
+}
+
+func analyzeEndpoints(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer, opts analyzeEndpointsOpts) []types.HTTPEndpoint {
 	if len(*endpoints) == 0 {
 		return nil
 	}
 
+	// Process in canonical order so the merge winners, and therefore the
+	// stored profile, do not depend on the order endpoints were reported.
+	sorted := slices.Clone(*endpoints)
+	slices.SortStableFunc(sorted, compareEndpoints)
+	endpoints = &sorted
+
+	templates := newEndpointTemplates(opts.templates, analyzer.DynamicIdentifier())
+
 	// First pass: build the analyzer trie from each endpoint's true (port,
 	// path) tuple. Each port keys a separate sub-tree, so :0/foo and
 	// :443/foo are analyzed independently — :443/foo is NOT rewritten to
 	// :0/foo just because some unrelated endpoint also uses :0.
 	for _, endpoint := range *endpoints {
-		_, _ = AnalyzeURL(endpoint.Endpoint, analyzer)
+		_, _ = analyzeURL(opts.schemePort(endpoint.Endpoint), analyzer, nil, templates, walkCount)
 	}
+	queries := newQueryCollapse(*endpoints, opts.queryThreshold, analyzer.DynamicIdentifier())
+	hosts := newHostCollapse(*endpoints, opts.hostThreshold)
 
 	// Second pass: process endpoints with their original ports.
 	var newEndpoints []*types.HTTPEndpoint
 	for _, endpoint := range *endpoints {
 		ep := endpoint
-		processedEndpoint, err := ProcessEndpoint(&ep, analyzer, newEndpoints)
+		ep.Endpoint = opts.schemePort(ep.Endpoint)
+		processedEndpoint, err := processEndpoint(&ep, analyzer, newEndpoints, queries, hosts, templates, walkRevisit)
+		if err == nil && opts.examples != nil && ep.Endpoint != endpoint.Endpoint {
+			// processEndpoint leaves the rewritten Endpoint in ep even
+			// when it merges ep into an earlier entry.
+			if example, ok := opts.examples[ep.Endpoint]; !ok || endpoint.Endpoint < example {
+				opts.examples[ep.Endpoint] = endpoint.Endpoint
+			}
+		}
 		if processedEndpoint == nil && err == nil || err != nil {
 			continue
 		}
@@ -43,15 +223,121 @@ func AnalyzeEndpoints(endpoints *[]types.HTTPEndpoint, analyzer *PathAnalyzer) [
 	// Cross-port folding happens here: only same-(path, direction) siblings
 	// of an explicit :0 wildcard get absorbed into it.
 	newEndpoints = MergeDuplicateEndpoints(newEndpoints)
+	for _, endpoint := range newEndpoints {
+		endpoint.Methods = normalizeMethods(endpoint.Methods, opts.standardMethodsOnly)
+		if opts.headerThreshold > 0 {
+			capHeaders(endpoint, opts.headerThreshold, analyzer.DynamicIdentifier())
+		}
+	}
 
 	return convertPointerToValueSlice(newEndpoints)
 }
 
+// standardMethods are the request methods of RFC 9110 and RFC 5789.
+var standardMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodConnect: {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+}
+
+// normalizeMethods returns methods uppercased and deflated with
+// utils.DeflateStringSlice, so clients sending "get" and "GET" produce one
+// method. With standardOnly, methods not in standardMethods are dropped.
+// The result never shares a backing array with methods, which may still
+// be the caller's input; nil stays nil.
+func normalizeMethods(methods []string, standardOnly bool) []string {
+	if methods == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(methods))
+	for _, m := range methods {
+		m = strings.ToUpper(m)
+		if standardOnly {
+			if _, ok := standardMethods[m]; !ok {
+				continue
+			}
+		}
+		normalized = append(normalized, m)
+	}
+	return utils.DeflateStringSlice(normalized)
+}
+
+// collapsedExamples picks the example for every collapsed endpoint in
+// result from the rewrites analyzeEndpoints recorded. A :0 entry also
+// draws on rewrites to the same path on specific ports, since
+// MergeDuplicateEndpoints folds those into it.
+func collapsedExamples(result []types.HTTPEndpoint, rewrites map[string]string, analyzer *PathAnalyzer) map[string]string {
+	examples := make(map[string]string)
+	for _, endpoint := range result {
+		if !isCollapsedEndpoint(endpoint.Endpoint, analyzer) {
+			continue
+		}
+		candidates := []string{endpoint.Endpoint}
+		if port, pathPart := splitEndpointPortAndPath(endpoint.Endpoint); isWildcardPort(port) {
+			for rewritten := range rewrites {
+				if _, p := splitEndpointPortAndPath(rewritten); p == pathPart {
+					candidates = append(candidates, rewritten)
+				}
+			}
+		}
+		for _, candidate := range candidates {
+			example, ok := rewrites[candidate]
+			if !ok {
+				continue
+			}
+			if current, ok := examples[endpoint.Endpoint]; !ok || example < current {
+				examples[endpoint.Endpoint] = example
+			}
+		}
+	}
+	return examples
+}
+
+// isCollapsedEndpoint reports whether endpoint contains the analyzer's ⋯,
+// in its path or a collapsed query value, or a * path segment.
+func isCollapsedEndpoint(endpoint string, analyzer *PathAnalyzer) bool {
+	if strings.Contains(endpoint, analyzer.DynamicIdentifier()) {
+		return true
+	}
+	_, pathPart := splitEndpointPortAndPath(endpoint)
+	return slices.Contains(strings.Split(pathPart, "/"), analyzer.WildcardIdentifier())
+}
+
+// compareEndpoints orders endpoints by Endpoint, then Direction, then
+// Internal.
+func compareEndpoints(a, b types.HTTPEndpoint) int {
+	if c := strings.Compare(a.Endpoint, b.Endpoint); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Direction, b.Direction); c != 0 {
+		return c
+	}
+	switch {
+	case a.Internal == b.Internal:
+		return 0
+	case b.Internal:
+		return -1
+	default:
+		return 1
+	}
+}
+
 func ProcessEndpoint(endpoint *types.HTTPEndpoint, analyzer *PathAnalyzer, newEndpoints []*types.HTTPEndpoint) (*types.HTTPEndpoint, error) {
-	analyzeURL, err := AnalyzeURL(endpoint.Endpoint, analyzer)
+	return processEndpoint(endpoint, analyzer, newEndpoints, nil, nil, nil, walkCount)
+}
+
+func processEndpoint(endpoint *types.HTTPEndpoint, analyzer *PathAnalyzer, newEndpoints []*types.HTTPEndpoint, queries *queryCollapse, hosts *hostCollapse, templates *endpointTemplates, mode walkMode) (*types.HTTPEndpoint, error) {
+	analyzeURL, err := analyzeURL(endpoint.Endpoint, analyzer, queries, templates, mode)
 	if err != nil {
 		return nil, err
 	}
+	analyzeURL = hosts.render(endpoint) + analyzeURL
 
 	if analyzeURL != endpoint.Endpoint {
 		endpoint.Endpoint = analyzeURL
@@ -79,42 +365,408 @@ func ProcessEndpoint(endpoint *types.HTTPEndpoint, analyzer *PathAnalyzer, newEn
 }
 
 func AnalyzeURL(urlString string, analyzer *PathAnalyzer) (string, error) {
-	if !strings.HasPrefix(urlString, "http://") && !strings.HasPrefix(urlString, "https://") {
+	return analyzeURL(urlString, analyzer, nil, nil, walkCount)
+}
+
+func analyzeURL(urlString string, analyzer *PathAnalyzer, queries *queryCollapse, templates *endpointTemplates, mode walkMode) (string, error) {
+	parsedURL, err := parseEndpointURL(urlString)
+	if err != nil {
+		return "", err
+	}
+
+	port := endpointPort(parsedURL)
+
+	path, ok := templates.match(parsedURL.Path)
+	if !ok {
+		path = analyzer.analyzePath(parsedURL.Path, port, mode)
+	}
+	if path == "/." {
+		path = "/"
+	}
+	return ":" + port + path + queries.normalize(parsedURL.Query()), nil
+}
+
+// CountUniqueEndpoints returns the number of distinct (port, path,
+// Direction, Internal) tuples in endpoints before any collapse, the
+// endpoint counterpart of CountUniqueOpens. Paths are cleaned and the
+// query is ignored, as AnalyzeEndpoints does; endpoints that fail to
+// parse are skipped, since AnalyzeEndpoints drops them too.
+func CountUniqueEndpoints(endpoints []types.HTTPEndpoint) int {
+	unique := make(map[string]struct{}, len(endpoints))
+	for _, endpoint := range endpoints {
+		parsedURL, err := parseEndpointURL(endpoint.Endpoint)
+		if err != nil {
+			continue
+		}
+		p := path.Clean("/" + parsedURL.Path)
+		unique[getEndpointKey(&types.HTTPEndpoint{
+			Endpoint:  ":" + endpointPort(parsedURL) + p,
+			Direction: endpoint.Direction,
+			Internal:  endpoint.Internal,
+		})] = struct{}{}
+	}
+	return len(unique)
+}
+
+// parseEndpointURL parses an endpoint as recorded by the node agent, which
+// usually omits the scheme (":80/path") and may be scheme-relative
+// ("//host:80/path"). The fragment and userinfo are parsed but unused, so
+// they never reach the analyzed path. An empty path is returned as "/".
+//
+// An endpoint without a port ("/api/v1/users", or "api/v1/users") is
+// taken as a bare path: its first segment is neither a port nor a host,
+// and the port is left empty, so AnalyzeURL renders it as
+// ":/api/v1/users". The empty port is not the wildcard :0 and so never
+// absorbs endpoints on specific ports.
+//
+// The AnyHTTPPort of a merged endpoint (":http/api") is kept in the host,
+// where endpointPort finds it.
+func parseEndpointURL(urlString string) (*url.URL, error) {
+	if rest, ok := strings.CutPrefix(urlString, ":"+AnyHTTPPort); ok && (rest == "" || rest[0] == '/' || rest[0] == '?') {
+		// url.Parse only takes numeric ports; parse on a stand-in one.
+		parsedURL, err := parseEndpointURL(":80" + rest)
+		if err != nil {
+			return nil, err
+		}
+		parsedURL.Host = ":" + AnyHTTPPort
+		return parsedURL, nil
+	}
+	switch {
+	case strings.HasPrefix(urlString, "http://"), strings.HasPrefix(urlString, "https://"):
+	case strings.HasPrefix(urlString, "//"):
+		urlString = "http:" + urlString
+	case isPortlessPath(urlString):
+		urlString = "http:///" + strings.TrimPrefix(urlString, "/")
+	default:
 		urlString = "http://" + urlString
 	}
 
 	if err := isValidURL(urlString); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	parsedURL, err := url.Parse(urlString)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if parsedURL.Path == "" {
+		parsedURL.Path = "/"
 	}
+	return parsedURL, nil
+}
 
-	port := parsedURL.Port()
+// endpointPort returns the port of an endpoint parsed by parseEndpointURL,
+// which is AnyHTTPPort for a merged endpoint: url.URL.Port only returns
+// numeric ports.
+func endpointPort(parsedURL *url.URL) string {
+	if parsedURL.Host == ":"+AnyHTTPPort {
+		return AnyHTTPPort
+	}
+	return parsedURL.Port()
+}
 
-	path, _ := analyzer.AnalyzePath(parsedURL.Path, port)
-	if path == "/." {
-		path = "/"
+// isPortlessPath reports whether a scheme-less endpoint is a bare path:
+// its first segment carries no ":port", which every host or port form
+// the node agent records does.
+func isPortlessPath(endpoint string) bool {
+	first := endpoint
+	if i := strings.IndexAny(endpoint, "/?#"); i >= 0 {
+		first = endpoint[:i]
 	}
-	return ":" + port + path, nil
+	return !strings.Contains(first, ":")
+}
+
+// queryCollapse holds the query keys whose values collapse to the dynamic
+// identifier. A nil *queryCollapse drops the query, as AnalyzeURL does.
+type queryCollapse struct {
+	dynamic   string
+	collapsed map[string]struct{}
+}
+
+// newQueryCollapse returns the query handling for endpoints, or nil when
+// threshold is non-positive. Keys with more than threshold distinct values
+// across endpoints are collapsed.
+func newQueryCollapse(endpoints []types.HTTPEndpoint, threshold int, dynamic string) *queryCollapse {
+	if threshold <= 0 {
+		return nil
+	}
+	values := make(map[string]map[string]struct{})
+	for _, endpoint := range endpoints {
+		parsedURL, err := parseEndpointURL(endpoint.Endpoint)
+		if err != nil {
+			continue
+		}
+		for key, vs := range parsedURL.Query() {
+			if values[key] == nil {
+				values[key] = make(map[string]struct{})
+			}
+			for _, v := range vs {
+				values[key][v] = struct{}{}
+			}
+		}
+	}
+	q := &queryCollapse{dynamic: dynamic, collapsed: make(map[string]struct{})}
+	for key, vs := range values {
+		if len(vs) > threshold {
+			q.collapsed[key] = struct{}{}
+		}
+	}
+	return q
+}
+
+// normalize renders query as `?k1=v1&k2=v2...` with keys and values sorted
+// and deduplicated, and every collapsed key reduced to a single k=⋯.
+// Returns "" for a nil receiver or an empty query.
+func (q *queryCollapse) normalize(query url.Values) string {
+	if q == nil || len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		escapedKey := url.QueryEscape(key)
+		if _, ok := q.collapsed[key]; ok {
+			sb.WriteString("&" + escapedKey + "=" + q.dynamic)
+			continue
+		}
+		vs := slices.Clone(query[key])
+		slices.Sort(vs)
+		for _, v := range slices.Compact(vs) {
+			sb.WriteString("&" + escapedKey + "=" + url.QueryEscape(v))
+		}
+	}
+	return "?" + sb.String()[1:]
+}
+
+// endpointTemplates holds the path templates of
+// AnalyzeEndpointsWithTemplates, most specific first. A nil
+// *endpointTemplates matches nothing, as in AnalyzeURL.
+type endpointTemplates struct {
+	templates []endpointTemplate
+	dynamic   string
+}
+
+// endpointTemplate is one path template split into segments, each a list
+// of literal parts with a parameter between every two of them, and the
+// path it is stored under.
+type endpointTemplate struct {
+	segments [][]string
+	dynamic  string
+}
+
+// newEndpointTemplates compiles templates, or returns nil when there are
+// none. A `{` without a closing `}` is literal text.
+func newEndpointTemplates(templates []string, dynamic string) *endpointTemplates {
+	if len(templates) == 0 {
+		return nil
+	}
+	t := &endpointTemplates{templates: make([]endpointTemplate, 0, len(templates)), dynamic: dynamic}
+	for _, template := range templates {
+		var compiled endpointTemplate
+		rendered := make([]string, 0, strings.Count(template, "/")+1)
+		for _, segment := range splitPath(NormalizePath(template)) {
+			parts := templateParts(segment)
+			compiled.segments = append(compiled.segments, parts)
+			if len(parts) > 1 {
+				segment = dynamic
+			}
+			rendered = append(rendered, segment)
+		}
+		compiled.dynamic = strings.Join(rendered, "/")
+		t.templates = append(t.templates, compiled)
+	}
+	slices.SortStableFunc(t.templates, func(a, b endpointTemplate) int {
+		return MoreSpecificWithOptions(a.dynamic, b.dynamic, CompareDynamicOpts{DynamicIdentifier: dynamic})
+	})
+	return t
+}
+
+// templateParts splits a template segment around its `{...}`
+// parameters: "{name}.json" gives ["", ".json"] and a segment without
+// parameters gives itself alone.
+func templateParts(segment string) []string {
+	var parts []string
+	for {
+		open := strings.IndexByte(segment, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(segment[open:], '}')
+		if end < 0 {
+			break
+		}
+		parts = append(parts, segment[:open])
+		segment = segment[open+end+1:]
+	}
+	return append(parts, segment)
+}
+
+// match returns the stored path of the first template p matches. A
+// dynamic segment in p matches any parameter, so stored endpoints match
+// their template again.
+func (t *endpointTemplates) match(p string) (string, bool) {
+	if t == nil || p == "" {
+		return "", false
+	}
+	segments := splitPath(NormalizePath(p))
+	for _, template := range t.templates {
+		if len(template.segments) != len(segments) {
+			continue
+		}
+		matched := true
+		for i, parts := range template.segments {
+			if segments[i] == t.dynamic && len(parts) > 1 {
+				continue
+			}
+			if !matchTemplateSegment(parts, segments[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return template.dynamic, true
+		}
+	}
+	return "", false
+}
+
+// matchTemplateSegment reports whether segment is parts with a non-empty
+// string in place of every parameter between them. Taking the leftmost
+// occurrence of each middle part is enough, as with a * glob.
+func matchTemplateSegment(parts []string, segment string) bool {
+	if len(parts) == 1 {
+		return parts[0] == segment
+	}
+	first, last := parts[0], parts[len(parts)-1]
+	if !strings.HasPrefix(segment, first) || !strings.HasSuffix(segment, last) || len(segment) < len(first)+len(last) {
+		return false
+	}
+	rest := segment[len(first) : len(segment)-len(last)]
+	for _, part := range parts[1 : len(parts)-1] {
+		// Skip one byte for the parameter before part.
+		if rest == "" {
+			return false
+		}
+		i := strings.Index(rest[1:], part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[1+i+len(part):]
+	}
+	return rest != ""
+}
+
+// hostCollapse holds the outbound IPv4 hosts kept by
+// AnalyzeEndpointsWithHosts and the (block, port) pairs whose hosts are
+// replaced by their /24 block. A nil *hostCollapse drops every host, as
+// AnalyzeURL does.
+type hostCollapse struct {
+	collapsed map[hostBlock]struct{}
+}
+
+// hostBlock is a /24 block of addresses together with the port they were
+// contacted on.
+type hostBlock struct {
+	block netip.Prefix
+	port  string
+}
+
+// hostCIDRBits is the size of the blocks hostCollapse groups addresses
+// into.
+const hostCIDRBits = 24
+
+// newHostCollapse returns the host handling for endpoints, or nil when
+// threshold is non-positive. Blocks with more than threshold distinct
+// addresses on a port are collapsed.
+func newHostCollapse(endpoints []types.HTTPEndpoint, threshold int) *hostCollapse {
+	if threshold <= 0 {
+		return nil
+	}
+	addrs := make(map[hostBlock]map[netip.Addr]struct{})
+	for i := range endpoints {
+		addr, port, ok := outboundIPv4Host(&endpoints[i])
+		if !ok {
+			continue
+		}
+		key := hostBlock{block: netip.PrefixFrom(addr, hostCIDRBits).Masked(), port: port}
+		if addrs[key] == nil {
+			addrs[key] = make(map[netip.Addr]struct{})
+		}
+		addrs[key][addr] = struct{}{}
+	}
+	h := &hostCollapse{collapsed: make(map[hostBlock]struct{})}
+	for key, set := range addrs {
+		if len(set) > threshold {
+			h.collapsed[key] = struct{}{}
+		}
+	}
+	return h
+}
+
+// render returns the host endpoint is stored under, the address or its
+// collapsed block, or "" when the host is dropped.
+func (h *hostCollapse) render(endpoint *types.HTTPEndpoint) string {
+	if h == nil {
+		return ""
+	}
+	addr, port, ok := outboundIPv4Host(endpoint)
+	if !ok {
+		return ""
+	}
+	block := netip.PrefixFrom(addr, hostCIDRBits).Masked()
+	if _, ok := h.collapsed[hostBlock{block: block, port: port}]; ok {
+		return block.String()
+	}
+	return addr.String()
+}
+
+// outboundIPv4Host returns the IPv4 host and the port of an outbound
+// endpoint; ok is false for any other endpoint.
+func outboundIPv4Host(endpoint *types.HTTPEndpoint) (netip.Addr, string, bool) {
+	if endpoint.Direction != consts.Outbound {
+		return netip.Addr{}, "", false
+	}
+	parsedURL, err := parseEndpointURL(endpoint.Endpoint)
+	if err != nil {
+		return netip.Addr{}, "", false
+	}
+	addr, err := netip.ParseAddr(parsedURL.Hostname())
+	if err != nil || !addr.Is4() {
+		return netip.Addr{}, "", false
+	}
+	return addr, parsedURL.Port(), true
 }
 
 // splitEndpointPortAndPath splits the canonical `:<port><path>` form
 // produced by AnalyzeURL into its (port, path) parts.
 //
+// Endpoints that still carry a host are accepted too, so lookups with
+// DNS-resolved outbound forms key the same way as the canonical form:
+// bracketed IPv6 (`[2001:db8::1]:80/api`) and named or IPv4 hosts
+// (`host.internal:443/api`) both yield the port and path and drop the
+// host. A host is only recognised when it is followed by a numeric port.
+// The CIDR block hosts of AnalyzeEndpointsWithHosts
+// (`10.0.0.0/24:443/api`) are recognised as well.
+//
 // Defensive contract: AnalyzeURL guarantees a leading `:` and a port
 // segment, but callers and tests sometimes pass bare paths (e.g.
 // "/health") for ad-hoc lookups. To keep merge keys deterministic,
 // this helper returns empty port + leading-slash-normalised path for
-// any input that does not start with `:`. The empty string returns
+// any other input that does not start with `:`. The empty string returns
 // ("", "/") to match the original fall-through behavior.
 func splitEndpointPortAndPath(endpoint string) (string, string) {
 	if !strings.HasPrefix(endpoint, ":") {
 		if endpoint == "" {
 			return "", "/"
 		}
+		if port, pathPart, ok := splitHostPortPath(endpoint); ok {
+			return port, pathPart
+		}
 		if !strings.HasPrefix(endpoint, "/") {
 			endpoint = "/" + endpoint
 		}
@@ -128,6 +780,51 @@ func splitEndpointPortAndPath(endpoint string) (string, string) {
 	return s[:idx], s[idx:]
 }
 
+// cidrHostLen returns the length of the CIDR block endpoint starts with
+// when it is followed by a port, or 0.
+func cidrHostLen(endpoint string) int {
+	slash := strings.IndexByte(endpoint, '/')
+	if slash <= 0 {
+		return 0
+	}
+	colon := strings.IndexByte(endpoint[slash:], ':')
+	if colon < 0 {
+		return 0
+	}
+	if _, err := netip.ParsePrefix(endpoint[:slash+colon]); err != nil {
+		return 0
+	}
+	return slash + colon
+}
+
+// splitHostPortPath handles `host:port[/path]` and `[v6]:port[/path]`.
+// ok is false unless a host and a numeric port are both present.
+func splitHostPortPath(endpoint string) (string, string, bool) {
+	hostPort, pathPart := endpoint, "/"
+	start := cidrHostLen(endpoint)
+	if idx := strings.Index(endpoint[start:], "/"); idx != -1 {
+		hostPort, pathPart = endpoint[:start+idx], endpoint[start+idx:]
+	}
+	var port string
+	if strings.HasPrefix(hostPort, "[") {
+		end := strings.Index(hostPort, "]")
+		if end == -1 || !strings.HasPrefix(hostPort[end+1:], ":") {
+			return "", "", false
+		}
+		port = hostPort[end+2:]
+	} else {
+		idx := strings.LastIndex(hostPort, ":")
+		if idx <= 0 {
+			return "", "", false
+		}
+		port = hostPort[idx+1:]
+	}
+	if !isAllDigits(port) {
+		return "", "", false
+	}
+	return port, pathPart, true
+}
+
 // MergeDuplicateEndpoints folds duplicates and merges same-path specific-port
 // endpoints into a wildcard-port (:0) sibling. Folding is symmetric and is
 // keyed on the same triple HTTPEndpoint.Equal compares — (Endpoint,
@@ -182,10 +879,9 @@ func MergeDuplicateEndpoints(endpoints []*types.HTTPEndpoint) []*types.HTTPEndpo
 
 		// Specific port: if a wildcard sibling for the same
 		// (path, direction, Internal) is already in `seen`, fold this entry
-		// into it. The wildcardKey shape MUST match getEndpointKey exactly so
-		// the lookup hits the same map slot the wildcard was inserted under.
-		wildcardKey := fmt.Sprintf(":0%s|%s|%t", pathPart, endpoint.Direction, endpoint.Internal)
-		if existing, found := seen[wildcardKey]; found {
+		// into it. The key is built by getEndpointKey itself so the lookup
+		// always hits the slot the wildcard was inserted under.
+		if existing, found := seen[wildcardEndpointKey(pathPart, endpoint)]; found {
 			existing.Methods = MergeStrings(existing.Methods, endpoint.Methods)
 			mergeHeaders(existing, endpoint)
 			continue
@@ -212,12 +908,20 @@ func removeEndpoint(s []*types.HTTPEndpoint, target *types.HTTPEndpoint) []*type
 
 // getEndpointKey returns a key that uniquely identifies an HTTPEndpoint by
 // the same fields HTTPEndpoint.Equal compares: Endpoint, Direction, Internal.
-// Keep this in sync with the wildcardKey shape constructed in
-// MergeDuplicateEndpoints — the two MUST hash identical entries identically.
 func getEndpointKey(endpoint *types.HTTPEndpoint) string {
 	return fmt.Sprintf("%s|%s|%t", endpoint.Endpoint, endpoint.Direction, endpoint.Internal)
 }
 
+// wildcardEndpointKey returns the getEndpointKey of the :0 sibling of
+// endpoint at pathPart — same Direction and Internal, wildcard port.
+func wildcardEndpointKey(pathPart string, endpoint *types.HTTPEndpoint) string {
+	return getEndpointKey(&types.HTTPEndpoint{
+		Endpoint:  ":0" + pathPart,
+		Direction: endpoint.Direction,
+		Internal:  endpoint.Internal,
+	})
+}
+
 func mergeHeaders(existing, new *types.HTTPEndpoint) {
 	existingHeaders, err := existing.GetHeaders()
 	if err != nil {
@@ -229,13 +933,17 @@ func mergeHeaders(existing, new *types.HTTPEndpoint) {
 		return
 	}
 
+	// Every value slice is sorted and deduplicated, not only the ones
+	// both sides have, and json.Marshal sorts the keys, so the merged
+	// Headers bytes do not depend on which endpoint was merged into which.
+	if existingHeaders == nil {
+		existingHeaders = make(map[string][]string, len(newHeaders))
+	}
 	for k, v := range newHeaders {
-		if _, exists := existingHeaders[k]; exists {
-			set := mapset.NewSet[string](append(existingHeaders[k], v...)...)
-			existingHeaders[k] = set.ToSlice()
-		} else {
-			existingHeaders[k] = v
-		}
+		existingHeaders[k] = append(existingHeaders[k], v...)
+	}
+	for k, v := range existingHeaders {
+		existingHeaders[k] = utils.DeflateStringSlice(v)
 	}
 
 	rawJSON, err := json.Marshal(existingHeaders)
@@ -252,6 +960,38 @@ func mergeHeaders(existing, new *types.HTTPEndpoint) {
 	existing.Headers = rawJSON
 }
 
+// capHeaders replaces the values of every header key of endpoint that
+// has more than threshold distinct values, or that already mixes dynamic
+// with other values, by dynamic alone. Headers that fail to parse are
+// left untouched, as mergeHeaders does.
+func capHeaders(endpoint *types.HTTPEndpoint, threshold int, dynamic string) {
+	if len(endpoint.Headers) == 0 {
+		return
+	}
+	headers, err := endpoint.GetHeaders()
+	if err != nil {
+		return
+	}
+	changed := false
+	for k, v := range headers {
+		distinct := utils.DeflateStringSlice(v)
+		if len(distinct) > threshold || len(distinct) > 1 && slices.Contains(distinct, dynamic) {
+			headers[k] = []string{dynamic}
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	rawJSON, err := json.Marshal(headers)
+	if err != nil {
+		logger.L().Debug("capHeaders: failed to marshal capped headers, leaving them untouched",
+			loggerhelpers.Error(err))
+		return
+	}
+	endpoint.Headers = rawJSON
+}
+
 func convertPointerToValueSlice(m []*types.HTTPEndpoint) []types.HTTPEndpoint {
 	result := make([]types.HTTPEndpoint, 0, len(m))
 	for _, v := range m {
@@ -39,6 +39,19 @@ func TestSplitEndpointPortAndPath_DefensiveContract(t *testing.T) {
 		// to ("", "/foo").
 		{"opaque_token", "foo", "", "/foo"},
 		{"opaque_with_dot", "host.example.com", "", "/host.example.com"},
+
+		// Host-qualified forms from DNS-resolved outbound calls. The host
+		// is dropped so they key like the canonical `:port/path` form.
+		{"ipv6_with_port_and_path", "[2001:db8::1]:80/api", "80", "/api"},
+		{"ipv6_loopback_with_port", "[::1]:8080/path", "8080", "/path"},
+		{"ipv6_with_port_no_path", "[::1]:8080", "8080", "/"},
+		{"named_host_with_port", "host.internal:443/path", "443", "/path"},
+		{"ipv4_host_with_port", "10.0.0.1:9090/metrics", "9090", "/metrics"},
+
+		// Not host:port — stay bare paths.
+		{"ipv6_without_port", "[::1]/path", "", "/[::1]/path"},
+		{"non_numeric_port", "host:http/path", "", "/host:http/path"},
+		{"colon_inside_path", "api/v1:batch", "", "/api/v1:batch"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,449 @@
+package dynamicpathdetector
+
+import (
+	"slices"
+	"strings"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+)
+
+// execsIdentifier keys the exec-path trie inside a PathAnalyzer, keeping
+// it separate from the "opens" trie when one analyzer is shared.
+const execsIdentifier = "execs"
+
+// AnalyzeExecs collapses exec binary paths the same way AnalyzeOpens
+// collapses file paths: once a directory has more unique children than
+// the analyzer's threshold for that prefix, they collapse to ⋯, so
+// hundreds of transient binaries like /tmp/abc123/python become a single
+// /tmp/⋯/python entry. The name below a collapsed directory is kept, so
+// scripts run from random temp dirs stay apart by name: /tmp/⋯/setup.sh
+// and /tmp/⋯/run.sh are two entries. Args are carried over unchanged and
+// Envs are sorted and deduplicated; entries that become identical after
+// collapse, envs included, are merged.
+//
+// The result is sorted by Path, then by the full String() form, so it is
+// independent of input order.
+func AnalyzeExecs(execs []types.ExecCalls, analyzer *PathAnalyzer) ([]types.ExecCalls, error) {
+	return AnalyzeExecsWithEnvThreshold(execs, analyzer, 0)
+}
+
+// AnalyzeExecsWithEnvThreshold is AnalyzeExecs with environment value
+// collapse: when a variable name is seen with more than envThreshold
+// distinct values across all execs, every NAME=value for it becomes
+// NAME=⋯ (the analyzer's dynamic identifier). Records that differ only by
+// a collapsed value then merge. A non-positive envThreshold disables env
+// collapse.
+func AnalyzeExecsWithEnvThreshold(execs []types.ExecCalls, analyzer *PathAnalyzer, envThreshold int) ([]types.ExecCalls, error) {
+	return analyzeExecs(execs, analyzer, analyzeExecsOpts{envThreshold: envThreshold})
+}
+
+// AnalyzeExecsWithTailCollapse is AnalyzeExecs with argument collapse, for
+// compact profiles:
+//
+//   - threshold: when an argument position of a (collapsed) exec path is
+//     seen with more than threshold distinct values, that position becomes
+//     ⋯, so `curl <url>` folds into `curl ⋯`.
+//   - tailThreshold: when more than tailThreshold distinct arg vectors of
+//     one exec path share a shorter one as prefix, they all become that
+//     prefix followed by the analyzer's wildcard identifier, read as "any
+//     further args". `[⋯]` and `[⋯ --verbose]` then merge into `[⋯ *]`.
+//
+// A non-positive value disables the respective collapse.
+func AnalyzeExecsWithTailCollapse(execs []types.ExecCalls, analyzer *PathAnalyzer, threshold, tailThreshold int) ([]types.ExecCalls, error) {
+	return analyzeExecs(execs, analyzer, analyzeExecsOpts{argThreshold: threshold, tailThreshold: tailThreshold})
+}
+
+// AnalyzeExecsOrdered is AnalyzeExecs with a choice of output order. With
+// preserveOrder set, the result keeps the order in which each surviving
+// entry was first observed in execs, for consumers that reconstruct
+// startup sequences; duplicates are still merged into their first
+// occurrence. Otherwise the result is sorted, as AnalyzeExecs does.
+func AnalyzeExecsOrdered(execs []types.ExecCalls, analyzer *PathAnalyzer, preserveOrder bool) ([]types.ExecCalls, error) {
+	return analyzeExecs(execs, analyzer, analyzeExecsOpts{preserveOrder: preserveOrder})
+}
+
+// AnalyzeExecsWithFlagArgs is AnalyzeExecs with flag-aware argument
+// collapse, for commands whose flags come in varying order, where the
+// positional collapse of AnalyzeExecsWithTailCollapse fragments. Each arg
+// is a flag (starting with -), the value of the flag right before it, or
+// a positional; `--key=value` is a flag with its value inline, and `--`
+// ends the flags. Flag values are counted per flag and positionals per
+// position among the positionals, so once -H has been seen with more
+// than threshold distinct values on one exec path, `curl -H <token> URL`
+// becomes `curl -H ⋯ URL` wherever -H sits. Runs of consecutive flags are
+// then sorted by flag and value, so `-a X -b Y` and `-b Y -a X` give the
+// same record; positionals keep their place. A non-positive threshold
+// only sorts the flags.
+func AnalyzeExecsWithFlagArgs(execs []types.ExecCalls, analyzer *PathAnalyzer, threshold int) ([]types.ExecCalls, error) {
+	return analyzeExecs(execs, analyzer, analyzeExecsOpts{argThreshold: threshold, flagArgs: true})
+}
+
+// AnalyzeExecsWithProtectedPrefix is AnalyzeExecs with the positional
+// argument collapse of AnalyzeExecsWithTailCollapse, except that the first
+// protectPositions args are never collapsed, however many distinct values
+// they take. Subcommands stay auditable that way: `git commit <msg>` and
+// `git push <remote>` keep commit and push while the arg after them may
+// fold into ⋯. Later positions are counted separately for every value of
+// the protected ones, so a busy `git push` does not collapse the args of
+// `git status`. A non-positive threshold disables arg collapse; a
+// non-positive protectPositions protects nothing.
+func AnalyzeExecsWithProtectedPrefix(execs []types.ExecCalls, analyzer *PathAnalyzer, threshold, protectPositions int) ([]types.ExecCalls, error) {
+	return analyzeExecs(execs, analyzer, analyzeExecsOpts{argThreshold: threshold, protectedArgs: protectPositions})
+}
+
+// analyzeExecsOpts carries the knobs of the exported AnalyzeExecs
+// variants; the zero value is AnalyzeExecs. flagArgs switches
+// argThreshold from positional to flag-aware collapse; protectedArgs
+// leaves that many leading positions out of the positional one.
+type analyzeExecsOpts struct {
+	envThreshold  int
+	argThreshold  int
+	tailThreshold int
+	protectedArgs int
+	preserveOrder bool
+	flagArgs      bool
+}
+
+func analyzeExecs(execs []types.ExecCalls, analyzer *PathAnalyzer, opts analyzeExecsOpts) ([]types.ExecCalls, error) {
+	if execs == nil {
+		return nil, nil
+	}
+
+	for _, exec := range execs {
+		_, _ = AnalyzeExec(exec.Path, analyzer)
+	}
+	collapsedEnvs := dynamicEnvNames(execs, opts.envThreshold)
+	dynamic := analyzer.DynamicIdentifier()
+
+	analyzed := make([]types.ExecCalls, 0, len(execs))
+	for i := range execs {
+		exec := execs[i]
+		exec.Path = analyzer.analyzePath(exec.Path, execsIdentifier, walkRevisit)
+		exec.Envs = normalizeEnvs(exec.Envs, collapsedEnvs, dynamic)
+		analyzed = append(analyzed, exec)
+	}
+	if opts.flagArgs {
+		collapseFlagArgs(analyzed, opts.argThreshold, dynamic)
+	} else {
+		collapseArgs(analyzed, opts.argThreshold, opts.protectedArgs, dynamic)
+	}
+	collapseArgTails(analyzed, opts.tailThreshold, analyzer.WildcardIdentifier())
+
+	seen := make(map[string]struct{}, len(analyzed))
+	out := make([]types.ExecCalls, 0, len(analyzed))
+	for _, exec := range analyzed {
+		key := execKey(exec)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, exec)
+	}
+	if !opts.preserveOrder {
+		slices.SortFunc(out, compareExecs)
+	}
+	return out, nil
+}
+
+func AnalyzeExec(path string, analyzer *PathAnalyzer) (string, error) {
+	return analyzer.AnalyzePath(path, execsIdentifier)
+}
+
+func compareExecs(a, b types.ExecCalls) int {
+	if c := strings.Compare(a.Path, b.Path); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.String(), b.String()); c != 0 {
+		return c
+	}
+	return strings.Compare(execKey(a), execKey(b))
+}
+
+// collapseArgs replaces, in place, every argument at a position that has
+// more than threshold distinct values among the execs of the same path
+// with dynamic, except at the first protected positions. Later positions
+// are counted per value of the protected ones, so each subcommand gets
+// its own threshold. Args slices are copied before they are changed.
+func collapseArgs(execs []types.ExecCalls, threshold, protected int, dynamic string) {
+	if threshold <= 0 {
+		return
+	}
+	type position struct {
+		path   string
+		prefix string // the protected args, NUL-separated
+		index  int
+	}
+	prefix := func(args []string) string {
+		return strings.Join(args[:min(protected, len(args))], "\x00")
+	}
+	values := make(map[position]map[string]struct{})
+	for _, exec := range execs {
+		for i, arg := range exec.Args {
+			if i < protected {
+				continue
+			}
+			pos := position{exec.Path, prefix(exec.Args), i}
+			if values[pos] == nil {
+				values[pos] = make(map[string]struct{})
+			}
+			values[pos][arg] = struct{}{}
+		}
+	}
+	for i := range execs {
+		var collapsed []string
+		p := prefix(execs[i].Args)
+		for j := range execs[i].Args {
+			if j < protected || len(values[position{execs[i].Path, p, j}]) <= threshold {
+				continue
+			}
+			if collapsed == nil {
+				collapsed = slices.Clone(execs[i].Args)
+			}
+			collapsed[j] = dynamic
+		}
+		if collapsed != nil {
+			execs[i].Args = collapsed
+		}
+	}
+}
+
+// argGroup is one unit of an arg vector as collapseFlagArgs sees it: a
+// flag with its value, if any, or a single positional (flag empty).
+type argGroup struct {
+	flag     string
+	value    string
+	hasValue bool
+	inline   bool // --flag=value
+}
+
+// parseArgGroups splits args into flags, flag values and positionals.
+func parseArgGroups(args []string) []argGroup {
+	groups := make([]argGroup, 0, len(args))
+	flags := true
+	for _, arg := range args {
+		last := len(groups) - 1
+		switch {
+		case flags && arg == "--":
+			flags = false
+			groups = append(groups, argGroup{value: arg})
+		case flags && len(arg) > 1 && arg[0] == '-':
+			if flag, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(arg, "--") {
+				groups = append(groups, argGroup{flag: flag, value: value, hasValue: true, inline: true})
+			} else {
+				groups = append(groups, argGroup{flag: arg})
+			}
+		case flags && last >= 0 && groups[last].flag != "" && !groups[last].hasValue:
+			groups[last].value, groups[last].hasValue = arg, true
+		default:
+			groups = append(groups, argGroup{value: arg})
+		}
+	}
+	return groups
+}
+
+// appendArgGroup appends the args g was parsed from.
+func appendArgGroup(args []string, g argGroup) []string {
+	switch {
+	case g.flag == "":
+		return append(args, g.value)
+	case g.inline:
+		return append(args, g.flag+"="+g.value)
+	case g.hasValue:
+		return append(args, g.flag, g.value)
+	default:
+		return append(args, g.flag)
+	}
+}
+
+func compareArgGroups(a, b argGroup) int {
+	if c := strings.Compare(a.flag, b.flag); c != 0 {
+		return c
+	}
+	return strings.Compare(a.value, b.value)
+}
+
+// collapseFlagArgs rewrites, in place, the Args of every exec: the value
+// of a flag with more than threshold distinct values among the execs of
+// the same path, and a positional with more than threshold distinct
+// values at its position, become dynamic, and runs of consecutive flags
+// are sorted. Args slices are replaced, not modified.
+func collapseFlagArgs(execs []types.ExecCalls, threshold int, dynamic string) {
+	type slot struct {
+		path  string
+		flag  string
+		index int // position among the positionals; -1 for flag values
+	}
+	parsed := make([][]argGroup, len(execs))
+	values := make(map[slot]map[string]struct{})
+	for i, exec := range execs {
+		parsed[i] = parseArgGroups(exec.Args)
+		if threshold <= 0 {
+			continue
+		}
+		positional := 0
+		for _, g := range parsed[i] {
+			key := slot{exec.Path, g.flag, -1}
+			switch {
+			case g.flag == "":
+				key.index = positional
+				positional++
+			case !g.hasValue:
+				continue
+			}
+			if values[key] == nil {
+				values[key] = make(map[string]struct{})
+			}
+			values[key][g.value] = struct{}{}
+		}
+	}
+
+	for i := range execs {
+		if len(execs[i].Args) == 0 {
+			continue
+		}
+		groups := parsed[i]
+		if threshold > 0 {
+			positional := 0
+			for j, g := range groups {
+				key := slot{execs[i].Path, g.flag, -1}
+				if g.flag == "" {
+					key.index = positional
+					positional++
+				}
+				if len(values[key]) > threshold {
+					groups[j].value = dynamic
+				}
+			}
+		}
+		for start := 0; start < len(groups); {
+			end := start
+			for end < len(groups) && groups[end].flag != "" {
+				end++
+			}
+			slices.SortStableFunc(groups[start:end], compareArgGroups)
+			start = end + 1
+		}
+		args := make([]string, 0, len(execs[i].Args))
+		for _, g := range groups {
+			args = appendArgGroup(args, g)
+		}
+		execs[i].Args = args
+	}
+}
+
+// collapseArgTails rewrites, in place, the Args of every exec whose arg
+// vector extends a shorter vector seen for the same path, once more than
+// threshold distinct vectors share that prefix: they all become the
+// prefix followed by wildcard. Shorter prefixes are tried first, so a
+// vector absorbs into the most general tail it qualifies for.
+func collapseArgTails(execs []types.ExecCalls, threshold int, wildcard string) {
+	if threshold <= 0 {
+		return
+	}
+	byPath := make(map[string][][]string)
+	for _, exec := range execs {
+		if !slices.ContainsFunc(byPath[exec.Path], func(v []string) bool { return slices.Equal(v, exec.Args) }) {
+			byPath[exec.Path] = append(byPath[exec.Path], exec.Args)
+		}
+	}
+
+	tails := make(map[string][]string) // String() of the original exec path+args -> collapsed args
+	for p, vectors := range byPath {
+		slices.SortFunc(vectors, func(a, b []string) int {
+			if c := len(a) - len(b); c != 0 {
+				return c
+			}
+			return slices.Compare(a, b)
+		})
+		absorbed := make([]bool, len(vectors))
+		for i, prefix := range vectors {
+			if absorbed[i] {
+				continue
+			}
+			family := []int{i}
+			for j := i + 1; j < len(vectors); j++ {
+				if !absorbed[j] && len(vectors[j]) > len(prefix) && slices.Equal(vectors[j][:len(prefix)], prefix) {
+					family = append(family, j)
+				}
+			}
+			if len(family) <= threshold {
+				continue
+			}
+			tail := append(slices.Clone(prefix), wildcard)
+			for _, j := range family {
+				absorbed[j] = true
+				tails[argsKey(p, vectors[j])] = tail
+			}
+		}
+	}
+	for i := range execs {
+		if tail, ok := tails[argsKey(execs[i].Path, execs[i].Args)]; ok {
+			execs[i].Args = slices.Clone(tail)
+		}
+	}
+}
+
+func argsKey(path string, args []string) string {
+	return types.ExecCalls{Path: path, Args: args}.String()
+}
+
+// execKey identifies exec for deduplication by its path, args and
+// (normalized) envs. Unlike String(), it keeps args and envs apart, so
+// `env -i FOO=1` without envs does not merge with `env -i` run with
+// FOO=1 in its environment.
+func execKey(exec types.ExecCalls) string {
+	return argsKey(exec.Path, exec.Args) + "\x00" + strings.Join(exec.Envs, "\x00")
+}
+
+// dynamicEnvNames returns the variable names that have more than
+// threshold distinct values across execs. Returns nil when threshold is
+// non-positive.
+func dynamicEnvNames(execs []types.ExecCalls, threshold int) map[string]struct{} {
+	if threshold <= 0 {
+		return nil
+	}
+	values := make(map[string]map[string]struct{})
+	for _, exec := range execs {
+		for _, env := range exec.Envs {
+			name, value, ok := strings.Cut(env, "=")
+			if !ok {
+				continue
+			}
+			if values[name] == nil {
+				values[name] = make(map[string]struct{})
+			}
+			values[name][value] = struct{}{}
+		}
+	}
+	var dynamic map[string]struct{}
+	for name, vs := range values {
+		if len(vs) > threshold {
+			if dynamic == nil {
+				dynamic = make(map[string]struct{})
+			}
+			dynamic[name] = struct{}{}
+		}
+	}
+	return dynamic
+}
+
+// normalizeEnvs returns envs sorted and deduplicated, with the value of
+// every variable named in collapse replaced by the dynamic identifier. The input
+// slice is not modified; nil stays nil so untouched records compare equal
+// to their input.
+func normalizeEnvs(envs []string, collapse map[string]struct{}, dynamic string) []string {
+	if envs == nil {
+		return nil
+	}
+	out := make([]string, 0, len(envs))
+	for _, env := range envs {
+		if name, _, ok := strings.Cut(env, "="); ok {
+			if _, ok := collapse[name]; ok {
+				env = name + "=" + dynamic
+			}
+		}
+		out = append(out, env)
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
@@ -1,7 +1,9 @@
 package dynamicpathdetector
 
 import (
+	"cmp"
 	"maps"
+	"path"
 	"slices"
 	"strings"
 
@@ -9,50 +11,611 @@ import (
 	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
 )
 
+// opensIdentifier keys the trie AnalyzeOpens walks inside a PathAnalyzer
+// unless AnalyzeOpensOpts.Identifier names another one.
+const opensIdentifier = "opens"
+
+// writeOpensSuffix is appended to the identifier of the trie that opens
+// with write access are walked into under AnalyzeOpensOpts.PartitionByAccess.
+const writeOpensSuffix = ":write"
+
+// writeOpenFlags are the open(2) flags that give or imply write access.
+var writeOpenFlags = []string{"O_WRONLY", "O_RDWR", "O_APPEND", "O_CREAT", "O_TRUNC", "O_TMPFILE"}
+
+// defaultOpenFlags are the open(2) flag names the node-agent reports.
+var defaultOpenFlags = []string{
+	"O_RDONLY", "O_WRONLY", "O_RDWR",
+	"O_APPEND", "O_ASYNC", "O_CLOEXEC", "O_CREAT", "O_DIRECT", "O_DIRECTORY",
+	"O_DSYNC", "O_EXCL", "O_LARGEFILE", "O_NOATIME", "O_NOCTTY", "O_NOFOLLOW",
+	"O_NONBLOCK", "O_NDELAY", "O_PATH", "O_RSYNC", "O_SYNC", "O_TMPFILE", "O_TRUNC",
+}
+
+// DefaultOpenFlags returns a new set of the known open(2) flag names, for
+// use as the allowlist of AnalyzeOpensWithFlagAllowlist.
+func DefaultOpenFlags() mapset.Set[string] {
+	return mapset.NewThreadUnsafeSet(defaultOpenFlags...)
+}
+
 func AnalyzeOpens(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string]) ([]types.OpenCalls, error) {
+	return AnalyzeOpensWithFlagAllowlist(opens, analyzer, sbomSet, nil)
+}
+
+// AnalyzeOpensWithFlagAllowlist is AnalyzeOpens with flag validation:
+// every flag not in allowedFlags is dropped, and each result's Flags are
+// sorted and deduplicated, so malformed flags cannot accumulate on
+// collapsed entries. An entry whose flags were all unknown keeps an
+// empty Flags slice. A nil allowedFlags keeps every flag, as AnalyzeOpens
+// does.
+func AnalyzeOpensWithFlagAllowlist(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string], allowedFlags mapset.Set[string]) ([]types.OpenCalls, error) {
+	return AnalyzeOpensWithOptions(opens, analyzer, sbomSet, AnalyzeOpensOpts{AllowedFlags: allowedFlags})
+}
+
+// AnalyzeOpensOpts tunes AnalyzeOpensWithOptions. The zero value
+// reproduces AnalyzeOpens exactly.
+type AnalyzeOpensOpts struct {
+	// AllowedFlags, when non-nil, drops every flag not in the set; see
+	// AnalyzeOpensWithFlagAllowlist.
+	AllowedFlags mapset.Set[string]
+	// ExcludePrefixes drops every open at or below one of the prefixes
+	// (e.g. /proc, /sys) before analysis, so excluded paths neither
+	// appear in the result nor count toward any collapse threshold.
+	// Prefixes match at path boundaries: /proc excludes /proc/1/stat
+	// but not /process. SBOM paths are excluded too.
+	ExcludePrefixes []string
+	// OnCollapse, when non-nil, is called once for every open whose path
+	// is folded into a ⋯ or * entry, with the original and the collapsed
+	// path, so callers can count or log what collapse hides. Opens whose
+	// path comes through unchanged, including SBOM-listed paths (which are
+	// never collapsed), do not trigger it.
+	OnCollapse func(original, collapsed string)
+	// Compare, when non-nil, orders the result instead of the path order
+	// AnalyzeOpens uses; see AnalyzeOpensSorted.
+	Compare func(a, b types.OpenCalls) int
+	// MaxResults, when positive, bounds the number of entries; see
+	// AnalyzeOpensWithMaxResults.
+	MaxResults int
+	// PrefixAliases maps prefixes that expose the same files as another
+	// one, typically through a symlink, to that canonical prefix, e.g.
+	// {"/var/run": "/run"}. Every open under an aliased prefix is
+	// rewritten to the canonical one before analysis, so both views of a
+	// file are stored, counted and reported to OnCollapse as one path.
+	// Prefixes match at path boundaries and the longest one wins; the
+	// rewritten path is not looked up again. SBOM-listed paths are kept
+	// as listed.
+	PrefixAliases map[string]string
+	// Identifier, when non-empty, keys the trie the opens are walked into
+	// instead of the shared opens trie; see AnalyzeOpensForIdentifier.
+	Identifier string
+	// PartitionByAccess collapses opens with write access (O_WRONLY,
+	// O_RDWR, O_APPEND, O_CREAT, O_TRUNC or O_TMPFILE) apart from
+	// read-only ones: they are walked into a trie of their own, keyed by
+	// the identifier with ":write" appended, and merged into entries of
+	// their own. Fifty files read below /log then collapse into a
+	// read-only /log/⋯ while the one file written there stays literal,
+	// and the same path may appear twice in the result, once per access.
+	// Identifier configs for the write trie are looked up under its key.
+	PartitionByAccess bool
+	// KeepCollapsedDirs adds, for every entry collapse produces, the
+	// directory whose children were folded as a literal entry of its
+	// own, so /usr/lib/⋯ comes with /usr/lib and the directory stays
+	// visible as significant. The directory entry has no flags unless it
+	// was opened itself. It is not reported to OnCollapse and comes on
+	// top of MaxResults.
+	KeepCollapsedDirs bool
+}
+
+// identifier returns the trie key the opens are walked into.
+func (opts AnalyzeOpensOpts) identifier() string {
+	if opts.Identifier != "" {
+		return opts.Identifier
+	}
+	return opensIdentifier
+}
+
+// identifierFor returns the trie key an open with flags is walked into:
+// the write trie under PartitionByAccess when flags give write access,
+// the one of identifier otherwise.
+func (opts AnalyzeOpensOpts) identifierFor(flags []string) string {
+	if opts.PartitionByAccess && isWriteOpen(flags) {
+		return opts.identifier() + writeOpensSuffix
+	}
+	return opts.identifier()
+}
+
+// isWriteOpen reports whether flags give write access.
+func isWriteOpen(flags []string) bool {
+	return slices.ContainsFunc(flags, func(flag string) bool {
+		return slices.Contains(writeOpenFlags, flag)
+	})
+}
+
+// AnalyzeOpensWithOptions is AnalyzeOpens with the options in opts.
+func AnalyzeOpensWithOptions(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string], opts AnalyzeOpensOpts) ([]types.OpenCalls, error) {
+	return analyzeOpens(opens, analyzer, sbomSet, opts, nil), nil
+}
+
+// AnalyzeOpensSorted is AnalyzeOpens with the result ordered by cmp, a
+// comparator in the style of slices.SortFunc, for consumers that want
+// opens grouped by depth or flags rather than by path. Entries cmp
+// considers equal stay in path order, so the result is still
+// deterministic. A nil cmp keeps the path order.
+func AnalyzeOpensSorted(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string], cmp func(a, b types.OpenCalls) int) ([]types.OpenCalls, error) {
+	return AnalyzeOpensWithOptions(opens, analyzer, sbomSet, AnalyzeOpensOpts{Compare: cmp})
+}
+
+// AnalyzeOpensWithMaxResults is AnalyzeOpens with the result bounded to
+// maxResults entries. When collapse leaves more, for instance thousands
+// of directories each just under their threshold, the threshold is
+// lowered further, and uniformly, over the result: every directory with
+// more distinct entries below it than the lowered threshold has them
+// folded into dir/⋯, or into dir/* when some of them are deeper, the
+// shallowest such directory winning. The highest threshold that fits is
+// used, so as little as possible is folded.
+//
+// This is lossy: the folded entries match paths that were never opened,
+// and their flags are merged. SBOM-listed paths are never folded, so they
+// alone can still exceed maxResults. A non-positive maxResults keeps
+// every entry, as AnalyzeOpens does.
+func AnalyzeOpensWithMaxResults(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string], maxResults int) ([]types.OpenCalls, error) {
+	return AnalyzeOpensWithOptions(opens, analyzer, sbomSet, AnalyzeOpensOpts{MaxResults: maxResults})
+}
+
+// AnalyzeOpensForIdentifier is AnalyzeOpens walking the opens into the
+// analyzer's trie for identifier, e.g. a container name or mount
+// namespace, instead of the one trie all AnalyzeOpens calls share. In a
+// multi-container pod the same path may name different files in every
+// container, so with one identifier each, one analyzer collapses each
+// container's opens on their own counts only: /data/a in one and /data/b
+// in another do not add up toward the threshold of /data. Identifier
+// configs (see NewPathAnalyzerWithIdentifierConfigs) are looked up under
+// identifier too. An empty identifier is AnalyzeOpens.
+func AnalyzeOpensForIdentifier(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string], identifier string) ([]types.OpenCalls, error) {
+	return AnalyzeOpensWithOptions(opens, analyzer, sbomSet, AnalyzeOpensOpts{Identifier: identifier})
+}
+
+// AnalyzeOpensWithOriginals is AnalyzeOpens that also returns, for every
+// path in the result, the sorted and deduplicated original paths that
+// were folded into it, so a ⋯ or * entry can be expanded back to the
+// exact files for audit. Every result path is a key, including entries
+// that came through unchanged and SBOM-listed paths, which map to
+// themselves. The map is nil when opens is nil.
+func AnalyzeOpensWithOriginals(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string]) ([]types.OpenCalls, map[string][]string, error) {
 	if opens == nil {
-		return nil, nil
+		return nil, nil, nil
+	}
+	originals := make(map[string][]string)
+	result := analyzeOpens(opens, analyzer, sbomSet, AnalyzeOpensOpts{}, originals)
+	for p, paths := range originals {
+		slices.Sort(paths)
+		originals[p] = slices.Compact(paths)
+	}
+	return result, originals, nil
+}
+
+// analyzeOpens is AnalyzeOpensWithOptions, recording the originals of
+// each result path in originals when it is non-nil.
+func analyzeOpens(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string], opts AnalyzeOpensOpts, originals map[string][]string) []types.OpenCalls {
+	if opens == nil {
+		return nil
+	}
+	if len(opts.ExcludePrefixes) > 0 {
+		opens = slices.DeleteFunc(slices.Clone(opens), func(open types.OpenCalls) bool {
+			return isExcludedPath(open.Path, opts.ExcludePrefixes)
+		})
+	}
+	if len(opts.PrefixAliases) > 0 {
+		opens = canonicalOpens(opens, opts.PrefixAliases, sbomSet)
+	}
+	analyzer.addOpensTotals(opens, opts)
+
+	globs := analyzer.userGlobs(opens)
+	for _, open := range opens {
+		if _, ok := analyzer.matchUserGlob(globs, open.Path); !ok {
+			_, _ = analyzer.AnalyzePath(open.Path, opts.identifierFor(open.Flags))
+		}
 	}
+	return collapseOpens(opens, analyzer, sbomSet, globs, opts, originals)
+}
+
+// AnalyzeOpensStream is AnalyzeOpens over channels, for profiles too large
+// to collect into one slice first. Each open is walked into the trie as it
+// arrives, which is AnalyzeOpens' first pass. Collapse needs the second
+// pass over every open, though, and a directory may cross its threshold
+// with the very last one, so nothing is emitted until in is closed.
+// User patterns with a mid-path * (see userGlobs) are only known once
+// they arrive, so literals they absorb may already have been walked and
+// count toward the thresholds of their directories. The total a
+// ThresholdPercent is a share of is only known then too, so those
+// prefixes collapse in the second pass alone.
+// Until then the opens are buffered, so memory still peaks at roughly the
+// size of the input. What streaming saves is the caller's copy of the
+// input and of the result: results are sent one at a time, sorted by path
+// as AnalyzeOpens returns them, and can be written out as they come.
+//
+// Both returned channels are closed once the results are sent. Like the
+// error AnalyzeOpens returns, the error channel receives nothing today,
+// but callers should check it. The caller must drain the results.
+func AnalyzeOpensStream(in <-chan types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string]) (<-chan types.OpenCalls, <-chan error) {
+	out := make(chan types.OpenCalls)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(out)
+		var opens []types.OpenCalls
+		for open := range in {
+			if !analyzer.isMidPathGlob(open.Path) {
+				_, _ = AnalyzeOpen(open.Path, analyzer)
+			}
+			opens = append(opens, open)
+		}
+		if opens == nil {
+			return
+		}
+		analyzer.addOpensTotals(opens, AnalyzeOpensOpts{})
+		for _, open := range collapseOpens(opens, analyzer, sbomSet, analyzer.userGlobs(opens), AnalyzeOpensOpts{}, nil) {
+			out <- open
+		}
+	}()
+	return out, errc
+}
 
+// collapseOpens is the second pass of AnalyzeOpens: opens have all been
+// walked into analyzer once, except those matching globs, and are now
+// mapped to their collapsed paths and merged. Only opts.AllowedFlags,
+// opts.OnCollapse, opts.Compare, opts.MaxResults, opts.Identifier,
+// opts.PartitionByAccess and opts.KeepCollapsedDirs are used here.
+// When originals is non-nil, the original path of every open is appended
+// under the path it was merged into.
+func collapseOpens(opens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string], globs *PatternSet, opts AnalyzeOpensOpts, originals map[string][]string) []types.OpenCalls {
 	if sbomSet == nil {
 		sbomSet = mapset.NewThreadUnsafeSet[string]()
 	}
 
-	dynamicOpens := make(map[string]types.OpenCalls)
-	for _, open := range opens {
-		_, _ = AnalyzeOpen(open.Path, analyzer)
+	// results[i] is the path opens[i] is stored under, "" when it is
+	// dropped; worked out for every open first so MaxResults can fold them.
+	results := make([]string, len(opens))
+	for i := range opens {
+		if sbomSet.ContainsOne(opens[i].Path) {
+			continue
+		}
+		if result, err := analyzeOpenWithGlobs(opens[i].Path, analyzer, globs, opts.identifierFor(opens[i].Flags)); err == nil {
+			results[i] = result
+		}
+	}
+	if opts.MaxResults > 0 {
+		analyzer.foldToFit(opens, results, sbomSet, opts.MaxResults)
 	}
 
+	// Under PartitionByAccess, write opens are merged into writeOpens.
+	dynamicOpens := make(map[string]types.OpenCalls)
+	var writeOpens map[string]types.OpenCalls
+	if opts.PartitionByAccess {
+		writeOpens = make(map[string]types.OpenCalls)
+	}
 	for i := range opens {
 		// sbomSet files have to be always present in the dynamicOpens
 		if sbomSet.ContainsOne(opens[i].Path) {
 			dynamicOpens[opens[i].Path] = opens[i]
+			if originals != nil {
+				originals[opens[i].Path] = append(originals[opens[i].Path], opens[i].Path)
+			}
 			continue
 		}
 
-		result, err := AnalyzeOpen(opens[i].Path, analyzer)
-		if err != nil {
+		result := results[i]
+		if result == "" {
 			continue
 		}
+		if originals != nil {
+			originals[result] = append(originals[result], opens[i].Path)
+		}
 
-		if result != opens[i].Path {
-			if existing, ok := dynamicOpens[result]; ok {
-				existing.Flags = mapset.Sorted(mapset.NewThreadUnsafeSet(slices.Concat(existing.Flags, opens[i].Flags)...))
-				dynamicOpens[result] = existing
-			} else {
-				dynamicOpen := types.OpenCalls{Path: result, Flags: opens[i].Flags}
-				dynamicOpens[result] = dynamicOpen
+		merged := dynamicOpens
+		if writeOpens != nil && isWriteOpen(opens[i].Flags) {
+			merged = writeOpens
+		}
+		if opts.OnCollapse != nil || opts.KeepCollapsedDirs {
+			cleaned, dir := analyzer.cleanPath(opens[i].Path)
+			if dir {
+				cleaned += "/"
+			}
+			if result != cleaned && opts.OnCollapse != nil {
+				opts.OnCollapse(opens[i].Path, result)
+			}
+			if result != cleaned && opts.KeepCollapsedDirs {
+				if collapsedDir, ok := analyzer.collapsedDir(result); ok {
+					if _, exists := merged[collapsedDir]; !exists {
+						merged[collapsedDir] = types.OpenCalls{Path: collapsedDir}
+					}
+				}
+			}
+		}
+		// Merge even when the path came through unchanged: a user-supplied
+		// /app/* entry is its own result, and the literals it absorbed may
+		// already be recorded under it.
+		mergeOpen(merged, result, opens[i].Flags)
+	}
+
+	if opts.AllowedFlags != nil {
+		for _, merged := range []map[string]types.OpenCalls{dynamicOpens, writeOpens} {
+			for p, open := range merged {
+				open.Flags = filterFlags(open.Flags, opts.AllowedFlags)
+				merged[p] = open
 			}
-		} else {
-			dynamicOpens[opens[i].Path] = opens[i]
 		}
 	}
 
+	result := slices.AppendSeq(slices.Collect(maps.Values(dynamicOpens)), maps.Values(writeOpens))
+	slices.SortFunc(result, func(a, b types.OpenCalls) int {
+		if opts.Compare != nil {
+			if c := opts.Compare(a, b); c != 0 {
+				return c
+			}
+		}
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		// only a path read and written under PartitionByAccess gets here:
+		// the read entry comes first
+		if aw, bw := isWriteOpen(a.Flags), isWriteOpen(b.Flags); aw != bw {
+			if aw {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a.String(), b.String())
+	})
+	return result
+}
+
+// AnalyzeOpensIncremental merges newOpens into an already-analyzed result
+// without re-walking it. existing must be the output of a previous
+// AnalyzeOpens (or AnalyzeOpensIncremental) call made with the same
+// analyzer, so the analyzer's trie already holds every path behind
+// existing; only newOpens are fed through it. A new path that lands on an
+// existing collapsed entry (e.g. /home/⋯/file.txt) merges its flags into
+// that entry.
+//
+// Cost is O(len(newOpens)) trie walks plus one pass to index existing.
+// Entries in existing are not re-collapsed when new paths push their
+// directory over the threshold; the next full AnalyzeOpens pass folds
+// them. Neither input slice is modified.
+func AnalyzeOpensIncremental(existing []types.OpenCalls, newOpens []types.OpenCalls, analyzer *PathAnalyzer, sbomSet mapset.Set[string]) ([]types.OpenCalls, error) {
+	if newOpens == nil {
+		return existing, nil
+	}
+
+	if sbomSet == nil {
+		sbomSet = mapset.NewThreadUnsafeSet[string]()
+	}
+
+	dynamicOpens := make(map[string]types.OpenCalls, len(existing)+len(newOpens))
+	for _, open := range existing {
+		mergeOpen(dynamicOpens, open.Path, open.Flags)
+	}
+
+	analyzer.addOpensTotals(newOpens, AnalyzeOpensOpts{})
+	globs := analyzer.userGlobs(slices.Concat(existing, newOpens))
+	for _, open := range newOpens {
+		if _, ok := analyzer.matchUserGlob(globs, open.Path); !ok {
+			_, _ = AnalyzeOpen(open.Path, analyzer)
+		}
+	}
+
+	for i := range newOpens {
+		if sbomSet.ContainsOne(newOpens[i].Path) {
+			mergeOpen(dynamicOpens, newOpens[i].Path, newOpens[i].Flags)
+			continue
+		}
+
+		result, err := analyzeOpenWithGlobs(newOpens[i].Path, analyzer, globs, opensIdentifier)
+		if err != nil {
+			continue
+		}
+		mergeOpen(dynamicOpens, result, newOpens[i].Flags)
+	}
+
 	return slices.SortedFunc(maps.Values(dynamicOpens), func(a, b types.OpenCalls) int {
 		return strings.Compare(a.Path, b.Path)
 	}), nil
 }
 
+// userGlobs compiles the paths of opens that are user-supplied patterns
+// with a * (the analyzer's wildcard identifier) before their last segment, such as /var/lib/*/data, in input
+// order. The trie cannot hold them: a * node there stands for everything
+// below it, so /var/lib/*/data would widen to /var/lib/* and swallow
+// /var/lib/foo/other too. Instead, every open matching one of them (the
+// pattern included) is kept out of the trie and folded into the first
+// matching pattern, so its literals never count toward a collapse. A
+// trailing * like /app/* needs none of this; the trie already absorbs
+// what it covers. Returns nil when there are no such patterns.
+func (ua *PathAnalyzer) userGlobs(opens []types.OpenCalls) *PatternSet {
+	var patterns []string
+	for _, open := range opens {
+		if ua.isMidPathGlob(open.Path) && !slices.Contains(patterns, open.Path) {
+			patterns = append(patterns, open.Path)
+		}
+	}
+	if patterns == nil {
+		return nil
+	}
+	// Patterns are never empty here, so compiling cannot fail.
+	globs, _ := CompilePatternsWithOptions(patterns, ua.compareOpts())
+	return globs
+}
+
+// isMidPathGlob reports whether p has a * segment before its last one.
+func (ua *PathAnalyzer) isMidPathGlob(p string) bool {
+	p = strings.TrimRight(p, "/")
+	return strings.Contains(p, "/"+ua.wildcardIdentifier+"/") || strings.HasPrefix(p, ua.wildcardIdentifier+"/")
+}
+
+// matchUserGlob returns the pattern of globs that p folds into: p itself
+// when it is one of the patterns, otherwise the first one matching it.
+func (ua *PathAnalyzer) matchUserGlob(globs *PatternSet, p string) (string, bool) {
+	if globs == nil {
+		return "", false
+	}
+	if ua.isMidPathGlob(p) {
+		return p, true
+	}
+	return globs.Match(p)
+}
+
+// analyzeOpenWithGlobs is AnalyzeOpen for opens that may match globs,
+// walking the trie for identifier. It is the second pass over opens the
+// first pass already counted, so it leaves Hits alone.
+func analyzeOpenWithGlobs(p string, analyzer *PathAnalyzer, globs *PatternSet, identifier string) (string, error) {
+	if glob, ok := analyzer.matchUserGlob(globs, p); ok {
+		return glob, nil
+	}
+	return analyzer.analyzePath(p, identifier, walkRevisit), nil
+}
+
+// collapsedDir returns the directory of p whose children were collapsed:
+// the literal path before its first ⋯ or * segment, "/" for /⋯. It
+// reports false when p has no such segment.
+func (ua *PathAnalyzer) collapsedDir(p string) (string, bool) {
+	for i := 0; i < len(p); {
+		end := strings.IndexByte(p[i:], '/')
+		if end < 0 {
+			end = len(p)
+		} else {
+			end += i
+		}
+		if segment := p[i:end]; segment == ua.dynamicIdentifier || segment == ua.wildcardIdentifier {
+			return cmp.Or(strings.TrimSuffix(p[:i], "/"), "/"), true
+		}
+		i = end + 1
+	}
+	return "", false
+}
+
+// mergeOpen records flags for path in dynamicOpens, unioning them with any
+// flags already stored under the same path. Stored flags are always sorted
+// and deduplicated, so each result is in OpenCalls.String's canonical form.
+func mergeOpen(dynamicOpens map[string]types.OpenCalls, path string, flags []string) {
+	if existing, ok := dynamicOpens[path]; ok {
+		existing.Flags = mapset.Sorted(mapset.NewThreadUnsafeSet(slices.Concat(existing.Flags, flags)...))
+		dynamicOpens[path] = existing
+		return
+	}
+	if flags != nil {
+		flags = mapset.Sorted(mapset.NewThreadUnsafeSet(flags...))
+	}
+	dynamicOpens[path] = types.OpenCalls{Path: path, Flags: flags}
+}
+
+// canonicalOpens returns a copy of opens with every path not in sbomSet
+// rewritten by canonicalPath.
+func canonicalOpens(opens []types.OpenCalls, aliases map[string]string, sbomSet mapset.Set[string]) []types.OpenCalls {
+	canonical := slices.Clone(opens)
+	for i := range canonical {
+		if sbomSet != nil && sbomSet.ContainsOne(canonical[i].Path) {
+			continue
+		}
+		canonical[i].Path = canonicalPath(canonical[i].Path, aliases)
+	}
+	return canonical
+}
+
+// canonicalPath replaces the longest prefix of p that is a key of
+// aliases by its value. p is cleaned first, keeping a trailing slash;
+// when no prefix matches, p is returned as is.
+func canonicalPath(p string, aliases map[string]string) string {
+	cleaned := path.Clean(p)
+	best, target := "", ""
+	for alias, canonical := range aliases {
+		alias = path.Clean(alias)
+		if len(alias) > len(best) && hasPrefixAtBoundary(cleaned, alias) {
+			best, target = alias, canonical
+		}
+	}
+	if best == "" {
+		return p
+	}
+	rewritten := path.Clean(target + "/" + strings.TrimPrefix(cleaned[len(best):], "/"))
+	if len(p) > 1 && p[len(p)-1] == '/' && rewritten != "/" {
+		rewritten += "/"
+	}
+	return rewritten
+}
+
+// isExcludedPath reports whether p lies at or below one of prefixes.
+func isExcludedPath(p string, prefixes []string) bool {
+	p = path.Clean(p)
+	for _, prefix := range prefixes {
+		if hasPrefixAtBoundary(p, path.Clean(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterFlags returns the flags in allowed, sorted and deduplicated.
+func filterFlags(flags []string, allowed mapset.Set[string]) []string {
+	kept := mapset.NewThreadUnsafeSet[string]()
+	for _, flag := range flags {
+		if allowed.ContainsOne(flag) {
+			kept.Add(flag)
+		}
+	}
+	return mapset.Sorted(kept)
+}
+
+// CountUniqueOpens returns the number of distinct paths in opens, as
+// AnalyzePath would see them (path.Clean applied) but before any
+// collapse. Comparing it with the length of the analyzed result shows how
+// much raw cardinality collapse is hiding. No trie is built.
+func CountUniqueOpens(opens []types.OpenCalls) int {
+	unique := make(map[string]struct{}, len(opens))
+	for _, open := range opens {
+		unique[path.Clean(open.Path)] = struct{}{}
+	}
+	return len(unique)
+}
+
+// addOpensTotals declares the unique paths of opens to analyzer as fed to
+// the trie opts walks each of them into (see AddPathTotal), when the
+// configs of that trie need totals.
+func (ua *PathAnalyzer) addOpensTotals(opens []types.OpenCalls, opts AnalyzeOpensOpts) {
+	// unique is nil for the tries that need no total
+	unique := make(map[string]map[string]struct{})
+	for _, open := range opens {
+		identifier := opts.identifierFor(open.Flags)
+		paths, seen := unique[identifier]
+		if !seen {
+			if slices.ContainsFunc(ua.configsFor(identifier), func(c CollapseConfig) bool { return c.ThresholdPercent > 0 }) {
+				paths = make(map[string]struct{})
+			}
+			unique[identifier] = paths
+		}
+		if paths != nil {
+			paths[path.Clean(open.Path)] = struct{}{}
+		}
+	}
+	for identifier, paths := range unique {
+		if paths != nil {
+			ua.AddPathTotal(identifier, len(paths))
+		}
+	}
+}
+
+// AnalyzeOpen is AnalyzeOpenWithIdentifier for the trie AnalyzeOpens
+// uses.
 func AnalyzeOpen(path string, analyzer *PathAnalyzer) (string, error) {
-	return analyzer.AnalyzePath(path, "opens")
+	return AnalyzeOpenWithIdentifier(path, analyzer, opensIdentifier)
+}
+
+// AnalyzeOpenWithIdentifier walks path through analyzer's trie for
+// identifier and returns it collapsed. Opens of different categories,
+// such as reads and writes or the opens of different containers, can
+// share one analyzer under different identifiers and still collapse
+// independently. As with AnalyzeOpensOpts.Identifier, an empty
+// identifier is the trie AnalyzeOpen uses.
+func AnalyzeOpenWithIdentifier(path string, analyzer *PathAnalyzer, identifier string) (string, error) {
+	if identifier == "" {
+		identifier = opensIdentifier
+	}
+	return analyzer.AnalyzePath(path, identifier)
 }
@@ -1,9 +1,12 @@
 package dynamicpathdetector
 
 import (
+	"maps"
 	"path"
+	"slices"
 	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 // bufPool reuses byte-slice capacity across AnalyzePath calls. strings.Builder
@@ -34,14 +37,206 @@ func NewPathAnalyzer(threshold int) *PathAnalyzer {
 // configs is copied so the caller can reuse or mutate the slice without
 // affecting the analyzer.
 func NewPathAnalyzerWithConfigs(defaultThreshold int, configs []CollapseConfig) *PathAnalyzer {
+	return newPathAnalyzer(defaultThreshold, configs, DynamicIdentifier, WildcardIdentifier)
+}
+
+// NewPathAnalyzerFull is NewPathAnalyzerWithConfigs with a whole
+// CollapseConfig as the default instead of just a threshold: besides its
+// Threshold, its ExtensionThresholds, CollapseNumericSegments,
+// CollapseEntropicSegments, MaxDepth and PreserveHidden apply wherever no
+// entry of configs matches. It is added as a lowest-priority {Prefix: "/"}
+// entry, so a "/" entry in configs still wins, and FindConfigForPath
+// reports it with that prefix whatever defaultCfg.Prefix says.
+func NewPathAnalyzerFull(defaultCfg CollapseConfig, configs []CollapseConfig) *PathAnalyzer {
+	defaultCfg.Prefix = "/"
+	ua := NewPathAnalyzerWithConfigs(defaultCfg.Threshold, append(slices.Clip(configs), defaultCfg))
+	ua.defaultCfg = ua.configs[len(ua.configs)-1]
+	ua.defaultCfg.ExtensionThresholds = maps.Clone(ua.defaultCfg.ExtensionThresholds)
+	return ua
+}
+
+// NewPathAnalyzerWithIdentifiers builds an analyzer that emits and
+// recognises the given identifiers instead of ⋯ and *, for consumers that
+// mangle non-ASCII output. dynamic stands for exactly one segment and
+// wildcard for any number of segments, as DynamicIdentifier and
+// WildcardIdentifier do. An empty string keeps the default for that
+// identifier. Identifiers must not contain '/' and must differ from each
+// other; match profiles produced this way with the analyzer's
+// CompareDynamic method.
+func NewPathAnalyzerWithIdentifiers(threshold int, dynamic, wildcard string) *PathAnalyzer {
+	if dynamic == "" {
+		dynamic = DynamicIdentifier
+	}
+	if wildcard == "" {
+		wildcard = WildcardIdentifier
+	}
+	return newPathAnalyzer(threshold, nil, dynamic, wildcard)
+}
+
+// NewPathAnalyzerWithMaxDepth is NewPathAnalyzerWithConfigs with a
+// MaxDepth guard: path components beyond maxDepth are folded into one
+// trailing ⋯, or * when there is more than one, instead of being added to
+// the trie. A non-positive maxDepth means unlimited.
+func NewPathAnalyzerWithMaxDepth(defaultThreshold int, configs []CollapseConfig, maxDepth int) *PathAnalyzer {
+	ua := NewPathAnalyzerWithConfigs(defaultThreshold, configs)
+	ua.MaxDepth = max(maxDepth, 0)
+	return ua
+}
+
+// NewPathAnalyzerWithIdentifierConfigs builds an analyzer whose tries use
+// different CollapseConfigs per identifier, e.g. so endpoints on port 443
+// collapse slower than those on 8080. An identifier listed in
+// identifierConfigs is walked with its own configs only; any other
+// identifier gets none. defaultThreshold applies wherever no config
+// matches. A {Prefix: "/"} entry sets an identifier's threshold for the
+// whole trie. The map and slices are copied.
+func NewPathAnalyzerWithIdentifierConfigs(defaultThreshold int, identifierConfigs map[string][]CollapseConfig) *PathAnalyzer {
+	ua := NewPathAnalyzerWithConfigs(defaultThreshold, nil)
+	ua.identifierConfigs = make(map[string][]CollapseConfig, len(identifierConfigs))
+	for identifier, configs := range identifierConfigs {
+		ua.identifierConfigs[identifier] = cloneConfigs(configs)
+	}
+	return ua
+}
+
+func newPathAnalyzer(defaultThreshold int, configs []CollapseConfig, dynamic, wildcard string) *PathAnalyzer {
+	return &PathAnalyzer{
+		RootNodes:          make(map[string]*SegmentNode),
+		threshold:          defaultThreshold,
+		configs:            cloneConfigs(configs),
+		defaultCfg:         CollapseConfig{Prefix: "/", Threshold: defaultThreshold},
+		dynamicIdentifier:  dynamic,
+		wildcardIdentifier: wildcard,
+	}
+}
+
+// cloneConfigs deep-copies configs so the analyzer does not share them
+// with the caller.
+func cloneConfigs(configs []CollapseConfig) []CollapseConfig {
 	copied := make([]CollapseConfig, len(configs))
 	copy(copied, configs)
-	return &PathAnalyzer{
-		RootNodes:  make(map[string]*SegmentNode),
-		threshold:  defaultThreshold,
-		configs:    copied,
-		defaultCfg: CollapseConfig{Prefix: "/", Threshold: defaultThreshold},
+	for i := range copied {
+		copied[i].ExtensionThresholds = maps.Clone(copied[i].ExtensionThresholds)
+	}
+	return copied
+}
+
+// configsFor returns the CollapseConfigs the trie for identifier is
+// walked with: its own from NewPathAnalyzerWithIdentifierConfigs, or the
+// analyzer-wide ones.
+func (ua *PathAnalyzer) configsFor(identifier string) []CollapseConfig {
+	if configs, ok := ua.identifierConfigs[identifier]; ok {
+		return configs
+	}
+	return ua.configs
+}
+
+// WithTemporaryConfig returns a view of ua for a stricter (or looser) run,
+// e.g. during an incident: cfg replaces the config with the same Prefix,
+// or is added when there is none, in the view only. Everything else is
+// taken from ua as it is now, and the configs of ua are shared rather
+// than cloned, so a view is cheap to make and drop. The view starts
+// without learned paths and learns its own; nothing it does reaches ua.
+func (ua *PathAnalyzer) WithTemporaryConfig(cfg CollapseConfig) *PathAnalyzer {
+	cfg.ExtensionThresholds = maps.Clone(cfg.ExtensionThresholds)
+	ua.mu.RLock()
+	defer ua.mu.RUnlock()
+	view := &PathAnalyzer{
+		RootNodes:             make(map[string]*SegmentNode),
+		MaxDepth:              ua.MaxDepth,
+		CollapseAdjacent:      ua.CollapseAdjacent,
+		PreserveTrailingSlash: ua.PreserveTrailingSlash,
+		MinChildHits:          ua.MinChildHits,
+		threshold:             ua.threshold,
+		configs:               withConfig(ua.configs, cfg),
+		defaultCfg:            ua.defaultCfg,
+		dynamicIdentifier:     ua.dynamicIdentifier,
+		wildcardIdentifier:    ua.wildcardIdentifier,
 	}
+	if ua.identifierConfigs != nil {
+		view.identifierConfigs = make(map[string][]CollapseConfig, len(ua.identifierConfigs))
+		for identifier, configs := range ua.identifierConfigs {
+			view.identifierConfigs[identifier] = withConfig(configs, cfg)
+		}
+	}
+	return view
+}
+
+// withConfig returns a copy of configs with cfg in place of the entry for
+// its Prefix, or appended. The entries themselves are not cloned.
+func withConfig(configs []CollapseConfig, cfg CollapseConfig) []CollapseConfig {
+	i := slices.IndexFunc(configs, func(c CollapseConfig) bool { return c.Prefix == cfg.Prefix })
+	if i < 0 {
+		return append(slices.Clip(configs), cfg)
+	}
+	copied := slices.Clone(configs)
+	copied[i] = cfg
+	return copied
+}
+
+// DynamicIdentifier returns the single-segment identifier this analyzer
+// emits in place of collapsed segments (⋯ unless configured otherwise).
+func (ua *PathAnalyzer) DynamicIdentifier() string {
+	return ua.dynamicIdentifier
+}
+
+// WildcardIdentifier returns the multi-segment identifier this analyzer
+// emits (* unless configured otherwise).
+func (ua *PathAnalyzer) WildcardIdentifier() string {
+	return ua.wildcardIdentifier
+}
+
+// CompareDynamic is the package-level CompareDynamic using this
+// analyzer's identifiers, for matching profiles it produced.
+func (ua *PathAnalyzer) CompareDynamic(dynamicPath, regularPath string) bool {
+	return CompareDynamicWithOptions(dynamicPath, regularPath, ua.compareOpts())
+}
+
+// compareOpts returns the CompareDynamicOpts for this analyzer's
+// identifiers.
+func (ua *PathAnalyzer) compareOpts() CompareDynamicOpts {
+	return CompareDynamicOpts{
+		DynamicIdentifier:  ua.dynamicIdentifier,
+		WildcardIdentifier: ua.wildcardIdentifier,
+	}
+}
+
+// hasDynamicChild is SegmentNode.IsNextDynamic for this analyzer's
+// dynamic identifier.
+func (ua *PathAnalyzer) hasDynamicChild(node *SegmentNode) bool {
+	_, exists := node.child(ua.dynamicIdentifier)
+	return exists
+}
+
+// Reset drops every learned trie while keeping the collapse configuration,
+// leaving the analyzer in the same state as a freshly constructed one with
+// the same thresholds. Lets a caller reuse one analyzer across containers
+// instead of building a new one each time. The RootNodes map itself is
+// cleared rather than reallocated so its buckets are reused too.
+func (ua *PathAnalyzer) Reset() {
+	ua.mu.Lock()
+	defer ua.mu.Unlock()
+	if ua.RootNodes == nil {
+		ua.RootNodes = make(map[string]*SegmentNode)
+		return
+	}
+	clear(ua.RootNodes)
+	clear(ua.totals)
+}
+
+// AddPathTotal declares that n more paths are fed to the trie for
+// identifier, the total that CollapseConfig.ThresholdPercent is a share
+// of. The AnalyzeOpens functions declare their opens themselves; callers
+// walking paths with AnalyzePath or AddPaths call it with their count
+// before the walk that should collapse, typically between a first pass
+// that inserts every path and a second that reads the results.
+func (ua *PathAnalyzer) AddPathTotal(identifier string, n int) {
+	ua.mu.Lock()
+	defer ua.mu.Unlock()
+	if ua.totals == nil {
+		ua.totals = make(map[string]int)
+	}
+	ua.totals[identifier] += n
 }
 
 // effectiveThreshold returns the collapse threshold applicable to the given
@@ -54,17 +249,223 @@ func NewPathAnalyzerWithConfigs(defaultThreshold int, configs []CollapseConfig)
 // introspect the active config see the same result the analyzer actually
 // uses at walk time. Mismatched comparators (`>=` vs `>`) on duplicate
 // prefixes are a silent footgun for anyone who doesn't dedupe configs.
-func (ua *PathAnalyzer) effectiveThreshold(pathPrefix string) int {
+func (ua *PathAnalyzer) effectiveThreshold(configs *configResolver, pathPrefix string) int {
+	if i := configs.index(pathPrefix); i >= 0 {
+		if configs.tooShallow(i, pathPrefix) {
+			return NeverCollapse
+		}
+		return configs.threshold(i)
+	}
+	return ua.threshold
+}
+
+// configIndex returns the index of the longest-prefix entry of configs
+// matching pathPrefix, or -1 when none applies. Shared by
+// effectiveThreshold, childCollapseThreshold and FindConfigForPath so the
+// tiebreak described above holds by construction.
+func configIndex(configs []CollapseConfig, pathPrefix string) int {
+	bestIdx := -1
 	bestLen := -1
-	best := ua.threshold
-	for i := range ua.configs {
-		c := &ua.configs[i]
+	for i := range configs {
+		c := &configs[i]
 		if len(c.Prefix) > bestLen && hasPrefixAtBoundary(pathPrefix, c.Prefix) {
+			bestIdx = i
 			bestLen = len(c.Prefix)
-			best = c.Threshold
 		}
 	}
-	return best
+	return bestIdx
+}
+
+// configResolver answers configIndex for the prefixes of the path being
+// walked. Outside a batch it simply calls configIndex. In AddPaths it also
+// remembers the answers for the previous path, so the prefixes a path
+// shares with its predecessor (typically a whole parent directory) are
+// not resolved again.
+type configResolver struct {
+	configs  []CollapseConfig
+	total    int // paths fed to the trie, for ThresholdPercent
+	batch    bool
+	previous string           // the previous path of the batch
+	resolved []resolvedPrefix // answers for prefixes of previous, in walk order
+}
+
+// resolvedPrefix is configIndex's answer for the first end bytes of the
+// path being walked.
+type resolvedPrefix struct {
+	end   int
+	index int
+}
+
+// next prepares r for walking p, the next path of a batch: answers for
+// prefixes p does not share with the previous path are dropped. Walk
+// order is shortest prefix first, so everything from the first unshared
+// answer on can go.
+func (r *configResolver) next(p string) {
+	n := min(len(p), len(r.previous))
+	shared := 0
+	for shared < n && p[shared] == r.previous[shared] {
+		shared++
+	}
+	keep := 0
+	for keep < len(r.resolved) && r.resolved[keep].end <= shared {
+		keep++
+	}
+	r.resolved = r.resolved[:keep]
+	r.previous = p
+}
+
+// index is configIndex(r.configs, prefix). prefix must be a prefix of
+// the path being walked.
+func (r *configResolver) index(prefix string) int {
+	if !r.batch {
+		return configIndex(r.configs, prefix)
+	}
+	for _, resolved := range r.resolved {
+		if resolved.end == len(prefix) {
+			return resolved.index
+		}
+	}
+	i := configIndex(r.configs, prefix)
+	r.resolved = append(r.resolved, resolvedPrefix{end: len(prefix), index: i})
+	return i
+}
+
+// threshold returns the Threshold of configs[i], or the one its
+// ThresholdPercent comes to for r.total paths.
+func (r *configResolver) threshold(i int) int {
+	c := &r.configs[i]
+	if c.ThresholdPercent <= 0 {
+		return c.Threshold
+	}
+	if r.total <= 0 {
+		return NeverCollapse
+	}
+	return max(int(c.ThresholdPercent*float64(r.total)/100), 2)
+}
+
+// tooShallow reports whether the directory dirPath has fewer components
+// than the MinSegmentsBeforeCollapse of configs[i], so its children must
+// stay literal.
+func (r *configResolver) tooShallow(i int, dirPath string) bool {
+	minSegments := r.configs[i].MinSegmentsBeforeCollapse
+	return minSegments > 0 && pathDepth(dirPath) < minSegments
+}
+
+// pathDepth returns the number of non-empty components of p: 0 for "" and
+// "/", 1 for "/tmp" and "/tmp/".
+func pathDepth(p string) int {
+	n := 0
+	for i := 0; i < len(p); i++ {
+		if p[i] != '/' && (i == 0 || p[i-1] == '/') {
+			n++
+		}
+	}
+	return n
+}
+
+// childCollapseThreshold returns the threshold deciding whether the children of
+// the node at nodePath collapse, given the rest of the walked path below
+// it. When the rest is a single final segment whose extension has an entry
+// in the matching config's ExtensionThresholds, that entry wins over the
+// prefix Threshold. Sibling Count still covers every child of the node,
+// since the trie can only collapse a directory as a whole.
+func (ua *PathAnalyzer) childCollapseThreshold(configs *configResolver, nodePath, rest string) int {
+	if nodePath == "" {
+		// The root directory, which the walk reaches before any "/".
+		nodePath = "/"
+	}
+	i := configs.index(nodePath)
+	if i < 0 {
+		return ua.threshold
+	}
+	if configs.tooShallow(i, nodePath) {
+		return NeverCollapse
+	}
+	c := &configs.configs[i]
+	if len(c.ExtensionThresholds) > 0 && rest != "" && strings.IndexByte(rest, '/') < 0 {
+		if t, ok := c.ExtensionThresholds[path.Ext(rest)]; ok {
+			return t
+		}
+	}
+	return configs.threshold(i)
+}
+
+// alwaysDynamicSegment returns the dynamic identifier in place of segment
+// when segment is all digits or looks like a UUID or hash and the config
+// matching the parent path prefix enables collapsing that kind of segment
+// (CollapseNumericSegments / CollapseEntropicSegments); otherwise it
+// returns segment unchanged. The shape checks run first so the config
+// lookup is skipped for ordinary segments.
+//
+// A segment containing the dynamic identifier amid other characters, as
+// in /a/foo⋯bar/c from a profile edited or produced elsewhere, is always
+// dynamic too: stored literally it could never match anything, since
+// CompareDynamic only honours ⋯ as a whole segment.
+func (ua *PathAnalyzer) alwaysDynamicSegment(configs *configResolver, pathPrefix, segment string) string {
+	if len(segment) > len(ua.dynamicIdentifier) && strings.Contains(segment, ua.dynamicIdentifier) {
+		return ua.dynamicIdentifier
+	}
+	numeric := isAllDigits(segment)
+	entropic := isUUID(segment) || isLongHex(segment)
+	if !numeric && !entropic {
+		return segment
+	}
+	i := configs.index(pathPrefix)
+	if i < 0 || configs.tooShallow(i, pathPrefix) {
+		return segment
+	}
+	if c := &configs.configs[i]; numeric && c.CollapseNumericSegments || entropic && c.CollapseEntropicSegments {
+		return ua.dynamicIdentifier
+	}
+	return segment
+}
+
+// preservesHidden reports whether the config matching pathPrefix keeps
+// hidden children out of collapses (CollapseConfig.PreserveHidden).
+func (ua *PathAnalyzer) preservesHidden(configs *configResolver, pathPrefix string) bool {
+	if pathPrefix == "" {
+		// The root directory, which the walk reaches before any "/".
+		pathPrefix = "/"
+	}
+	i := configs.index(pathPrefix)
+	return i >= 0 && configs.configs[i].PreserveHidden
+}
+
+// anyPreserveHidden reports whether some config sets PreserveHidden, so
+// walks without one can skip the per-segment lookups.
+func (r *configResolver) anyPreserveHidden() bool {
+	for i := range r.configs {
+		if r.configs[i].PreserveHidden {
+			return true
+		}
+	}
+	return false
+}
+
+// countChildren returns how many children node has, not counting hidden
+// ones when keepHidden is set.
+func countChildren(node *SegmentNode, keepHidden bool) int {
+	n := len(node.Children)
+	if keepHidden {
+		for name := range node.Children {
+			if isHiddenSegment(name) {
+				n--
+			}
+		}
+	}
+	return n
+}
+
+// frequentChildren returns how many children of node have at least
+// minHits Hits, not counting hidden ones when keepHidden is set.
+func frequentChildren(node *SegmentNode, minHits int, keepHidden bool) int {
+	n := 0
+	for name, child := range node.Children {
+		if child.Hits >= minHits && !(keepHidden && isHiddenSegment(name)) {
+			n++
+		}
+	}
+	return n
 }
 
 // hasPrefixAtBoundary is like strings.HasPrefix but only matches if the
@@ -93,21 +494,61 @@ func hasPrefixAtBoundary(pathPrefix, prefix string) bool {
 	return pathPrefix[len(prefix)] == '/'
 }
 
+// AnalyzePath inserts p into the trie for identifier and returns it with
+// the collapses made so far applied. Analyzing a path the trie already
+// holds changes no Count, which counts distinct children, so walking the
+// same input twice cannot trip a threshold early; only Hits grows. A
+// directory that goes over its threshold collapses on the next walk
+// through it, which is why AnalyzeOpens and AnalyzeEndpoints make two
+// passes. PeekPath is the read-only counterpart.
+//
+// p may hold any bytes. A segment that is, or contains, the dynamic or
+// wildcard identifier is taken as that pattern rather than as a literal
+// name: stored profiles are fed back through the analyzer, and escaping
+// the identifiers would make that re-analysis change them.
 func (ua *PathAnalyzer) AnalyzePath(p, identifier string) (string, error) {
-	p = path.Clean(p)
+	return ua.analyzePath(p, identifier, walkCount), nil
+}
+
+// walkMode is what a walk of the trie records besides the path itself.
+type walkMode uint8
+
+const (
+	// walkCount counts the path in the Hits of the nodes it walks: one
+	// walk per occurrence of the path in the input.
+	walkCount walkMode = iota
+	// walkRevisit leaves Hits alone, for the second pass AnalyzeOpens,
+	// AnalyzeEndpoints and AnalyzeExecs make over input they already
+	// counted in the first.
+	walkRevisit
+	// walkPeek is a dry run for PeekPath: it copies each node before
+	// changing it, so the trie is left as it was.
+	walkPeek
+)
+
+// analyzePath is AnalyzePath with the walk mode chosen by the caller.
+func (ua *PathAnalyzer) analyzePath(p, identifier string, mode walkMode) string {
+	p, dir := ua.cleanPath(p)
+	ua.mu.Lock()
+	defer ua.mu.Unlock()
 	node, exists := ua.RootNodes[identifier]
 	if !exists {
-		node = &SegmentNode{
-			SegmentName: identifier,
-			Count:       0,
-			Children:    make(map[string]*SegmentNode),
-		}
+		node = newSegmentNode(identifier)
 		ua.RootNodes[identifier] = node
 	}
-	return ua.processSegments(node, p), nil
+	return ua.processSegments(node, &configResolver{configs: ua.configsFor(identifier), total: ua.totals[identifier]}, p, dir, mode)
 }
 
-func (ua *PathAnalyzer) processSegments(node *SegmentNode, p string) string {
+// cleanPath applies path.Clean to p and reports whether p is a directory
+// path whose trailing slash PreserveTrailingSlash keeps. The root is never
+// one: it has no segment to mark.
+func (ua *PathAnalyzer) cleanPath(p string) (string, bool) {
+	dir := ua.PreserveTrailingSlash && len(p) > 1 && p[len(p)-1] == '/'
+	p = path.Clean(p)
+	return p, dir && p != "/"
+}
+
+func (ua *PathAnalyzer) processSegments(node *SegmentNode, configs *configResolver, p string, dir bool, mode walkMode) string {
 	// Acquire a pooled byte-slice. len=0, cap preserved from previous reuse.
 	bufPtr := bufPool.Get().(*[]byte)
 	buf := (*bufPtr)[:0]
@@ -116,7 +557,38 @@ func (ua *PathAnalyzer) processSegments(node *SegmentNode, p string) string {
 		buf = make([]byte, 0, len(p)+16)
 	}
 
+	buf = ua.walkPath(node, configs, p, dir, mode, buf)
+
+	// Post-process: collapse runs of adjacent DynamicIdentifier segments
+	// (e.g. "/a/⋯/⋯/b") into a single WildcardIdentifier ("/a/*/b"). Done
+	// in place by shrinking buf — zero allocation because the output is
+	// always shorter than the input.
+	buf = collapseAdjacentDynamic(buf, ua.dynamicIdentifier, ua.wildcardIdentifier)
+
+	// string(buf) always copies, so it is safe to return the pool capacity
+	// immediately afterwards — the returned string does not alias buf.
+	out := string(buf)
+	*bufPtr = buf
+	bufPool.Put(bufPtr)
+	return out
+}
+
+// walkPath inserts p below node, collapsing along the way, and appends
+// the walked segment names to buf, before adjacent ⋯ are squashed. dir
+// marks p as a directory path (see cleanPath).
+func (ua *PathAnalyzer) walkPath(node *SegmentNode, configs *configResolver, p string, dir bool, mode walkMode, buf []byte) []byte {
+	var nc nodeCopies
+	if mode == walkPeek {
+		nc = nodeCopies{}
+	}
+	node = nc.own(node)
 	currentNode := node
+	// walked counts the levels of currentNode already walked: always 1,
+	// except inside a dynamic run (see adjacent.go), whose levels the walk
+	// steps through one segment at a time without leaving the node.
+	walked := 0
+	depth := 0
+	anyHidden := configs.anyPreserveHidden()
 	i := 0
 	for {
 		start := i
@@ -124,6 +596,34 @@ func (ua *PathAnalyzer) processSegments(node *SegmentNode, p string) string {
 			i++
 		}
 		segment := p[start:i]
+		span := ua.dynamicSpan(currentNode.SegmentName)
+		tooDeep := ua.depthExceeded(configs, p[:start], depth, segment)
+		// keepHidden is the parent directory's PreserveHidden, which
+		// decides whether segment and the parent's hidden children stay
+		// out of any ⋯ or * the parent collapses into.
+		keepHidden := anyHidden && ua.preservesHidden(configs, p[:start])
+		if walked > 0 && walked < span && (segment == ua.wildcardIdentifier || tooDeep || keepHidden && isHiddenSegment(segment)) {
+			// The walk leaves the run here: cut it so the rest of the
+			// loop sees an ordinary node.
+			ua.splitDynamicRun(currentNode, walked)
+			span = walked
+		}
+		if tooDeep {
+			// MaxDepth reached: everything from here down becomes a
+			// single leaf and the rest of p is never walked. The leaf is
+			// ⋯ for one remaining segment and * for more, so the stored
+			// path still matches p.
+			currentNode = nc.own(ua.processSegment(currentNode, ua.foldedIdentifier(p[i:]), ua.effectiveThreshold(configs, p[:start]), keepHidden, nc))
+			if mode == walkCount {
+				currentNode.Hits++
+			}
+			walked = 1
+			buf = ua.appendSegmentName(buf, currentNode)
+			break
+		}
+		if segment != "" {
+			depth++
+		}
 		// Two thresholds at two scopes — necessary because processSegment
 		// and updateNodeStats ask different questions about different nodes:
 		//
@@ -141,17 +641,46 @@ func (ua *PathAnalyzer) processSegments(node *SegmentNode, p string) string {
 		// to). It answers: "do this node's direct children exceed the
 		// collapse threshold configured for this node's path?". Here we
 		// do want p[:i] — updateNodeStats then collapses the current
-		// node's children to ⋯ when Count > threshold.
-		insertThreshold := ua.effectiveThreshold(p[:start])
-		collapseThreshold := ua.effectiveThreshold(p[:i])
-		currentNode = ua.processSegment(currentNode, segment, insertThreshold)
-		ua.updateNodeStats(currentNode, collapseThreshold)
-		buf = append(buf, currentNode.SegmentName...)
+		// node's children to ⋯ when Count > threshold. `rest` (the path
+		// below this node) lets a per-extension override apply when the
+		// only thing left to walk is a file name.
+		insertThreshold := ua.effectiveThreshold(configs, p[:start])
+		var rest string
+		if i < len(p) {
+			rest = p[i+1:]
+		}
+		collapseThreshold := ua.childCollapseThreshold(configs, p[:i], rest)
+		if walked > 0 && walked < span {
+			// Next level of a dynamic run: it has a single ⋯ child, so
+			// whatever segment comes next walks it.
+			walked++
+			if walked == span {
+				ua.updateNodeStats(currentNode, collapseThreshold, anyHidden && ua.preservesHidden(configs, p[:i]), nc)
+			}
+			buf = append(buf, ua.dynamicIdentifier...)
+		} else {
+			segment = ua.alwaysDynamicSegment(configs, p[:start], segment)
+			next := nc.own(ua.processSegment(currentNode, segment, insertThreshold, keepHidden, nc))
+			if mode == walkCount {
+				next.Hits++
+			}
+			walked = 1
+			if ua.CollapseAdjacent && ua.canMergeDynamicRun(node, currentNode, next) {
+				walked += span
+				ua.mergeDynamicRun(currentNode, next)
+				next = currentNode
+			}
+			currentNode = next
+			if walked >= ua.dynamicSpan(currentNode.SegmentName) {
+				ua.updateNodeStats(currentNode, collapseThreshold, anyHidden && ua.preservesHidden(configs, p[:i]), nc)
+			}
+			buf = ua.appendSegmentName(buf, currentNode)
+		}
 		// Wildcard absorbs the rest of the path: once a segment has been
 		// emitted as `*`, walking deeper would just append more "/*"
 		// suffixes, producing "/a/*/*/*" where the correct output is
 		// "/a/*". Terminate emission here.
-		if currentNode.SegmentName == WildcardIdentifier {
+		if currentNode.SegmentName == ua.wildcardIdentifier {
 			break
 		}
 		i++
@@ -161,72 +690,142 @@ func (ua *PathAnalyzer) processSegments(node *SegmentNode, p string) string {
 		buf = append(buf, '/')
 	}
 
-	// Post-process: collapse runs of adjacent DynamicIdentifier segments
-	// (e.g. "/a/⋯/⋯/b") into a single WildcardIdentifier ("/a/*/b"). Done
-	// in place by shrinking buf — zero allocation because the output is
-	// always shorter than the input.
-	buf = collapseAdjacentDynamic(buf)
+	if walked < ua.dynamicSpan(currentNode.SegmentName) {
+		// p ended inside a dynamic run; only its last level may be
+		// Terminal.
+		ua.splitDynamicRun(currentNode, walked)
+	}
+	if dir && currentNode.SegmentName != ua.wildcardIdentifier {
+		currentNode.Dir = true
+		return append(buf, '/')
+	}
+	currentNode.Terminal = true
+	return buf
+}
 
-	// string(buf) always copies, so it is safe to return the pool capacity
-	// immediately afterwards — the returned string does not alias buf.
-	out := string(buf)
-	*bufPtr = buf
-	bufPool.Put(bufPtr)
-	return out
+// appendSegmentName appends the segment the walk emits on entering node:
+// its name, or a single ⋯ for a dynamic run, whose further levels are
+// emitted as the walk steps through them.
+func (ua *PathAnalyzer) appendSegmentName(buf []byte, node *SegmentNode) []byte {
+	if ua.dynamicSpan(node.SegmentName) > 1 {
+		return append(buf, ua.dynamicIdentifier...)
+	}
+	return append(buf, node.SegmentName...)
+}
+
+// foldedIdentifier returns the identifier a path folded by MaxDepth ends
+// in, given rest, what follows the first folded segment: ⋯ when that
+// segment is the last one, * when further segments are folded with it.
+func (ua *PathAnalyzer) foldedIdentifier(rest string) string {
+	if rest == "" {
+		return ua.dynamicIdentifier
+	}
+	return ua.wildcardIdentifier
+}
+
+// depthExceeded reports whether segment, coming after depth components
+// at pathPrefix, lies beyond MaxDepth or beyond the MaxDepth of the config
+// matching pathPrefix. The empty root segment of an absolute path does
+// not count towards the depth.
+func (ua *PathAnalyzer) depthExceeded(configs *configResolver, pathPrefix string, depth int, segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if ua.MaxDepth > 0 && depth >= ua.MaxDepth {
+		return true
+	}
+	i := configs.index(pathPrefix)
+	if i < 0 || configs.configs[i].MaxDepth <= 0 {
+		return false
+	}
+	c := &configs.configs[i]
+	// Depth under c counts from its last component, which is itself at
+	// depth strings.Count(c.Prefix, "/"); "/" has no component of its own.
+	base := 0
+	if c.Prefix != "/" {
+		base = strings.Count(c.Prefix, "/") - 1
+	}
+	return depth-base >= c.MaxDepth
 }
 
 // collapseAdjacentDynamic compacts buf in place: any run of
-// "⋯/⋯[/⋯…]" becomes a single "*". Returns a buf[:n] slice where n is
-// the compacted length. Does not allocate; suitable for the hot path.
-func collapseAdjacentDynamic(buf []byte) []byte {
-	// DynamicIdentifier is U+22EF, three UTF-8 bytes: 0xE2 0x8B 0xAF.
-	const d0, d1, d2 = 0xE2, 0x8B, 0xAF
-	const dynLen = 3
+// "⋯/⋯[/⋯…]" (dynamic identifiers) becomes a single wildcard identifier.
+// Returns a buf[:n] slice where n is the compacted length. Does not
+// allocate as long as the wildcard is no longer than the shortest run it
+// replaces ("⋯/⋯"), which holds for the defaults; suitable for the hot
+// path.
+func collapseAdjacentDynamic(buf []byte, dynamic, wildcard string) []byte {
+	dynLen := len(dynamic)
+	src := buf
+	if len(wildcard) > 2*dynLen+1 {
+		// Writing the wildcard could overtake the read position; read
+		// from a copy instead.
+		src = append([]byte(nil), buf...)
+	}
 	isDyn := func(i int) bool {
-		return i+dynLen <= len(buf) && buf[i] == d0 && buf[i+1] == d1 && buf[i+2] == d2
+		return i+dynLen <= len(src) && string(src[i:i+dynLen]) == dynamic
 	}
 
-	out := 0
+	out := buf[:0]
 	i := 0
-	for i < len(buf) {
-		// Need at least "⋯/⋯" (7 bytes) to trigger a collapse.
-		if isDyn(i) && i+dynLen+1+dynLen <= len(buf) && buf[i+dynLen] == '/' && isDyn(i+dynLen+1) {
-			buf[out] = '*'
-			out++
+	for i < len(src) {
+		// Need at least "⋯/⋯" to trigger a collapse.
+		if isDyn(i) && i+dynLen+1+dynLen <= len(src) && src[i+dynLen] == '/' && isDyn(i+dynLen+1) {
+			out = append(out, wildcard...)
 			// Consume "⋯/⋯" plus any further "/⋯" in the run.
 			i += dynLen + 1 + dynLen
-			for i+1+dynLen <= len(buf) && buf[i] == '/' && isDyn(i+1) {
+			for i+1+dynLen <= len(src) && src[i] == '/' && isDyn(i+1) {
 				i += 1 + dynLen
 			}
 			continue
 		}
-		buf[out] = buf[i]
-		out++
+		out = append(out, src[i])
 		i++
 	}
-	return buf[:out]
+	return out
 }
 
-func (ua *PathAnalyzer) processSegment(node *SegmentNode, segment string, threshold int) *SegmentNode {
-	if segment == DynamicIdentifier {
-		return ua.handleDynamicSegment(node)
+// processSegment returns node's child for segment, creating or collapsing
+// children as needed. With keepHidden (the PreserveHidden of node's
+// config), hidden children are left out of every collapse: a hidden
+// segment always gets its own literal child, which does not count towards
+// node's Count, and ⋯ and * only absorb the other children. node must be
+// one the walk may change; in a dry run, nc holds the copies it changes
+// instead of the trie (see nodeCopies).
+func (ua *PathAnalyzer) processSegment(node *SegmentNode, segment string, threshold int, keepHidden bool, nc nodeCopies) *SegmentNode {
+	if keepHidden && isHiddenSegment(segment) {
+		if child, exists := node.child(segment); exists {
+			return child
+		}
+		child := newSegmentNode(segment)
+		node.setChild(segment, child)
+		return child
 	}
 	// Wildcard short-circuit: once a node has a * child, all paths through
-	// it go there. This is the glob-style "collapse everything below here"
-	// behaviour; set up either by threshold=1 (see below) or by a caller
-	// explicitly feeding a WildcardIdentifier segment.
-	if wildcardChild, exists := node.Children[WildcardIdentifier]; exists {
+	// it go there, explicit ⋯ segments included. This is the glob-style
+	// "collapse everything below here" behaviour; set up either by
+	// threshold=1 (see below) or by a caller explicitly feeding a
+	// WildcardIdentifier segment.
+	if wildcardChild, exists := node.child(ua.wildcardIdentifier); exists {
 		return wildcardChild
 	}
-	if node.IsNextDynamic() {
-		if len(node.Children) > 1 {
-			temp := node.Children[DynamicIdentifier]
-			node.Children = map[string]*SegmentNode{}
-			node.Children[DynamicIdentifier] = temp
+	if segment == ua.dynamicIdentifier {
+		return ua.handleDynamicSegment(node, keepHidden, nc)
+	}
+	// An explicit * (e.g. from a user-supplied profile entry like /app/*)
+	// covers every sibling, so it absorbs them rather than becoming one
+	// more literal child — including a ⋯ the siblings already collapsed
+	// into, which would otherwise swallow the * instead.
+	if segment == ua.wildcardIdentifier {
+		return ua.createWildcardNode(node, keepHidden, nc)
+	}
+	if dynamicChild, exists := node.child(ua.dynamicIdentifier); exists {
+		if countChildren(node, keepHidden) > 1 {
+			ua.keepOnlyChild(node, ua.dynamicIdentifier, dynamicChild, keepHidden)
 		}
-		return node.Children[DynamicIdentifier]
+		return dynamicChild
 	}
-	if child, exists := node.Children[segment]; exists {
+	if child, exists := node.child(segment); exists {
 		return child
 	}
 	// Threshold-1 short-circuit: a prefix explicitly configured to accept
@@ -234,27 +833,23 @@ func (ua *PathAnalyzer) processSegment(node *SegmentNode, segment string, thresh
 	// the first *new* segment rather than going through the ⋯ path. This
 	// matches the caller's intent of "anything under /app is noise".
 	if threshold == 1 {
-		return ua.createWildcardNode(node)
+		return ua.createWildcardNode(node, keepHidden, nc)
 	}
 	return ua.handleNewSegment(node, segment)
 }
 
 func (ua *PathAnalyzer) handleNewSegment(node *SegmentNode, segment string) *SegmentNode {
 	node.Count++
-	newNode := &SegmentNode{
-		SegmentName: segment,
-		Count:       0,
-		Children:    make(map[string]*SegmentNode),
-	}
-	node.Children[segment] = newNode
+	newNode := newSegmentNode(segment)
+	node.setChild(segment, newNode)
 	return newNode
 }
 
-func (ua *PathAnalyzer) handleDynamicSegment(node *SegmentNode) *SegmentNode {
-	if dynamicChild, exists := node.Children[DynamicIdentifier]; exists {
+func (ua *PathAnalyzer) handleDynamicSegment(node *SegmentNode, keepHidden bool, nc nodeCopies) *SegmentNode {
+	if dynamicChild, exists := node.child(ua.dynamicIdentifier); exists {
 		return dynamicChild
 	} else {
-		return ua.createDynamicNode(node)
+		return ua.createDynamicNode(node, keepHidden, nc)
 	}
 }
 
@@ -268,58 +863,87 @@ func (ua *PathAnalyzer) handleDynamicSegment(node *SegmentNode) *SegmentNode {
 // pinned by TestAnalyzeOpensThreshold1ImmediateWildcard /
 // "single path - no collapse yet" which expects /instant/only-child/data
 // to collapse to /instant/* after a single insert.
-func (ua *PathAnalyzer) createWildcardNode(node *SegmentNode) *SegmentNode {
-	wildcard := &SegmentNode{
-		SegmentName: WildcardIdentifier,
-		Count:       0,
-		Children:    make(map[string]*SegmentNode),
-	}
+func (ua *PathAnalyzer) createWildcardNode(node *SegmentNode, keepHidden bool, nc nodeCopies) *SegmentNode {
+	wildcard := newSegmentNode(ua.wildcardIdentifier)
 	// Absorb any previously-accumulated children. Mirrors createDynamicNode.
-	for _, child := range node.Children {
-		shallowChildrenCopy(child, wildcard)
-	}
-	node.Children = map[string]*SegmentNode{
-		WildcardIdentifier: wildcard,
-	}
+	ua.absorbChildren(node, wildcard, keepHidden, nc)
+	ua.keepOnlyChild(node, ua.wildcardIdentifier, wildcard, keepHidden)
 	return wildcard
 }
 
-func (ua *PathAnalyzer) createDynamicNode(node *SegmentNode) *SegmentNode {
-	dynamicNode := &SegmentNode{
-		SegmentName: DynamicIdentifier,
-		Count:       0,
-		Children:    make(map[string]*SegmentNode),
-	}
+func (ua *PathAnalyzer) createDynamicNode(node *SegmentNode, keepHidden bool, nc nodeCopies) *SegmentNode {
+	dynamicNode := newSegmentNode(ua.dynamicIdentifier)
 
-	// Copy all existing children to the new dynamic node
-	for _, child := range node.Children {
-		shallowChildrenCopy(child, dynamicNode)
-	}
+	// Copy all existing children to the new dynamic node, counting them
+	// as updateNodeStats does so the next walk can collapse below it.
+	ua.absorbChildren(node, dynamicNode, keepHidden, nc)
+	dynamicNode.Count = countChildren(dynamicNode, keepHidden)
 
 	// Replace all children with the new dynamic node
-	node.Children = map[string]*SegmentNode{
-		DynamicIdentifier: dynamicNode,
-	}
+	ua.keepOnlyChild(node, ua.dynamicIdentifier, dynamicNode, keepHidden)
 
 	return dynamicNode
 }
 
+// absorbChildren merges the subtrees of node's children into dst,
+// skipping hidden children when keepHidden is set.
+func (ua *PathAnalyzer) absorbChildren(node, dst *SegmentNode, keepHidden bool, nc nodeCopies) {
+	for name, child := range node.Children {
+		if keepHidden && isHiddenSegment(name) {
+			continue
+		}
+		ua.shallowChildrenCopy(child, dst, nc)
+	}
+}
+
+// keepOnlyChild replaces node's children with child, as setOnlyChild
+// does, but with keepHidden the hidden children stay next to it.
+func (ua *PathAnalyzer) keepOnlyChild(node *SegmentNode, name string, child *SegmentNode, keepHidden bool) {
+	hidden := 0
+	if keepHidden {
+		for n := range node.Children {
+			if isHiddenSegment(n) {
+				hidden++
+			}
+		}
+	}
+	if hidden == 0 {
+		node.setOnlyChild(name, child)
+		return
+	}
+	children := make(map[string]*SegmentNode, hidden+1)
+	for n, c := range node.Children {
+		if isHiddenSegment(n) {
+			children[n] = c
+		}
+	}
+	children[name] = child
+	node.Children = children
+}
+
 // updateNodeStats collapses node's children into a single ⋯ (DynamicIdentifier)
 // child once the number of distinct children exceeds the provided threshold.
 // Threshold is passed in by the caller so per-prefix overrides (via
-// CollapseConfig) can take effect without this function knowing about them.
-func (ua *PathAnalyzer) updateNodeStats(node *SegmentNode, threshold int) {
-	if node.Count > threshold && !node.IsNextDynamic() {
-		dynamicChild := &SegmentNode{
-			SegmentName: DynamicIdentifier,
-			Count:       0,
-			Children:    make(map[string]*SegmentNode),
+// CollapseConfig) can take effect without this function knowing about them;
+// so is keepHidden, which leaves hidden children out of the collapse.
+// A node whose children are already a * is left alone: * covers more than
+// ⋯ would. NeverCollapse never collapses, and neither does a negative
+// threshold that slipped past ValidateConfigs.
+func (ua *PathAnalyzer) updateNodeStats(node *SegmentNode, threshold int, keepHidden bool, nc nodeCopies) {
+	if threshold <= 0 {
+		return
+	}
+	if _, wildcard := node.child(ua.wildcardIdentifier); wildcard {
+		return
+	}
+	if node.Count > threshold && !ua.hasDynamicChild(node) {
+		if ua.MinChildHits > 1 && frequentChildren(node, ua.MinChildHits, keepHidden) <= threshold {
+			return
 		}
+		dynamicChild := newSegmentNode(ua.dynamicIdentifier)
 
 		// Copy all descendants
-		for _, child := range node.Children {
-			shallowChildrenCopy(child, dynamicChild)
-		}
+		ua.absorbChildren(node, dynamicChild, keepHidden, nc)
 
 		// The absorbed children become dynamicChild's own children —
 		// update dynamicChild.Count so subsequent updateNodeStats calls
@@ -328,29 +952,67 @@ func (ua *PathAnalyzer) updateNodeStats(node *SegmentNode, threshold int) {
 		// Without this, multi-level grids like /a/{many}/{many}/leaf
 		// only collapse the first level and leave the grandchild
 		// literals intact in the output.
-		dynamicChild.Count = len(dynamicChild.Children)
+		// Hidden grandchildren are not counted when keepHidden is set,
+		// just as handleNewSegment does not count them.
+		dynamicChild.Count = countChildren(dynamicChild, keepHidden)
 
-		node.Children = map[string]*SegmentNode{
-			DynamicIdentifier: dynamicChild,
-		}
+		ua.keepOnlyChild(node, ua.dynamicIdentifier, dynamicChild, keepHidden)
 	}
 }
 
-func shallowChildrenCopy(src, dst *SegmentNode) {
-	for segmentName := range src.Children {
-		if _, ok := dst.Children[segmentName]; !ok {
-			dst.Children[segmentName] = src.Children[segmentName]
+// shallowChildrenCopy merges src's subtree into dst as if the two nodes
+// were one: children are moved by pointer when dst lacks them and merged
+// recursively when both have them. A path that ended at src also ends at
+// dst afterwards, and src's Hits are added to dst's. Dynamic runs of
+// different lengths under the same ⋯ key are split to line up first.
+// A merged child's Count is the sum of both Counts less the children the
+// two have in common, so a name seen below several absorbed siblings
+// counts once rather than pushing the merged node over its threshold.
+//
+// src is consumed: a moved child belongs to dst alone, so neither src nor
+// the children merged from it may be used afterwards. In a dry run (see
+// nodeCopies) the children that change are copied first and the trie
+// keeps src intact.
+func (ua *PathAnalyzer) shallowChildrenCopy(src, dst *SegmentNode, nc nodeCopies) {
+	if src.Terminal {
+		dst.Terminal = true
+	}
+	if src.Dir {
+		dst.Dir = true
+	}
+	dst.Hits += src.Hits
+	for segmentName, srcChild := range src.Children {
+		if dstChild, ok := dst.child(segmentName); !ok {
+			dst.setChild(segmentName, srcChild)
 		} else {
-			dst.Children[segmentName].Count += src.Children[segmentName].Count
-			shallowChildrenCopy(src.Children[segmentName], dst.Children[segmentName])
+			srcChild, dstChild = nc.own(srcChild), nc.ownChild(dst, segmentName, dstChild)
+			ua.alignDynamicRuns(srcChild, dstChild)
+			dstChild.Count += srcChild.Count - sharedChildren(srcChild, dstChild)
+			ua.shallowChildrenCopy(srcChild, dstChild, nc)
 		}
 	}
 }
 
+// sharedChildren returns the number of child names a and b both have.
+func sharedChildren(a, b *SegmentNode) int {
+	if len(a.Children) > len(b.Children) {
+		a, b = b, a
+	}
+	n := 0
+	for name := range a.Children {
+		if _, ok := b.Children[name]; ok {
+			n++
+		}
+	}
+	return n
+}
+
 // CompareDynamic checks whether `regularPath` is matched by `dynamicPath`.
 // The dynamic path may contain DynamicIdentifier (⋯, exactly-one-segment
 // wildcard) or WildcardIdentifier (*, zero-or-more-segment mid-path /
-// one-or-more-segment trailing wildcard). The node-agent R0002 rule
+// one-or-more-segment trailing wildcard). So `/a/*/c` matches `/a/c`
+// as well as `/a/b/c`; CompareDynamicOpts.NonEmptyWildcard turns the
+// zero-segment match off. The node-agent R0002 rule
 // (Files Access Anomalies) uses this at every file-open to decide whether
 // the access is in-profile.
 //
@@ -364,21 +1026,80 @@ func shallowChildrenCopy(src, dst *SegmentNode) {
 //     is an explicit unanchored `*`.
 //
 // Trailing-slash insensitivity: `/etc/` is treated as `/etc`, and
-// `/etc/passwd/` as `/etc/passwd`. Trailing empty path components from
+// `/etc/passwd/` as `/etc/passwd`, in the pattern as in the regular path;
+// `/etc/*` therefore does not match `/etc/` either, as it does not match
+// `/etc`. Trailing empty path components from
 // `strings.Split` are trimmed so `len(regular) > 0` correctly reflects
-// the presence of a real path tail when matching trailing `*`.
+// the presence of a real path tail when matching trailing `*`. The
+// regular path is put through NormalizePath first, so `/a/b/../c` is
+// compared as `/a/c`.
 //
 // The empty regular path (`""`) is treated as "no path" and matches
 // nothing — distinct from the root path `/`, which matches unanchored
 // `*` per the contract above.
+//
+// Within a static segment, `?` matches exactly one character (rune), as
+// in shell globs: `/dev/tty?` matches `/dev/tty0` but not `/dev/tty10`
+// or `/dev/tty`. `?` never matches `/`, so it cannot change how many
+// segments a pattern spans.
 func CompareDynamic(dynamicPath, regularPath string) bool {
+	return CompareDynamicWithOptions(dynamicPath, regularPath, CompareDynamicOpts{})
+}
+
+// CompareDynamicOpts tunes how CompareDynamicWithOptions compares static
+// segments. The zero value reproduces CompareDynamic exactly.
+type CompareDynamicOpts struct {
+	// CaseInsensitive compares static segments with strings.EqualFold, for
+	// paths coming from case-insensitive filesystems (e.g. Windows
+	// containers, where /Users/Foo and /users/foo are the same file).
+	// DynamicIdentifier and WildcardIdentifier matching is unaffected.
+	CaseInsensitive bool
+	// DynamicIdentifier and WildcardIdentifier override the identifiers
+	// recognised in dynamicPath; empty means the package defaults (⋯, *).
+	DynamicIdentifier  string
+	WildcardIdentifier string
+	// NonEmptyWildcard makes a mid-path * consume at least one segment,
+	// as a trailing * always does, so `/a/*/c` no longer matches `/a/c`.
+	// By default a mid-path * may consume zero segments, which is how
+	// CompareDynamic has always matched.
+	NonEmptyWildcard bool
+}
+
+// withDefaultIdentifiers fills in the package identifiers for those opts
+// leaves empty.
+func (opts CompareDynamicOpts) withDefaultIdentifiers() CompareDynamicOpts {
+	if opts.DynamicIdentifier == "" {
+		opts.DynamicIdentifier = DynamicIdentifier
+	}
+	if opts.WildcardIdentifier == "" {
+		opts.WildcardIdentifier = WildcardIdentifier
+	}
+	return opts
+}
+
+// CompareDynamicWithOptions is CompareDynamic with tunable segment
+// comparison. See CompareDynamic for the anchoring and trailing-slash
+// contract, which applies unchanged.
+func CompareDynamicWithOptions(dynamicPath, regularPath string, opts CompareDynamicOpts) bool {
 	// Empty inputs match nothing. Note that splitPath("") and splitPath("/")
 	// both yield [""] after trim, so without this guard an empty profile
 	// entry would silently match the root path.
 	if dynamicPath == "" || regularPath == "" {
 		return false
 	}
-	return compareSegments(splitPath(dynamicPath), splitPath(regularPath))
+	return compareSegments(splitPath(dynamicPath), splitPath(NormalizePath(regularPath)), opts.withDefaultIdentifiers())
+}
+
+// NormalizePath resolves `.` and `..` segments and repeated slashes the
+// way AnalyzePath does before insertion, so an unnormalized runtime path
+// like `/a/b/../c` matches the stored `/a/c`. `..` at the root is
+// clamped (`/../etc/passwd` is `/etc/passwd`); relative paths stay
+// relative. Unlike path.Clean, the empty path stays empty.
+func NormalizePath(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Clean(p)
 }
 
 // splitPath splits a path on `/` and trims trailing empty segments
@@ -394,11 +1115,11 @@ func splitPath(p string) []string {
 	return s
 }
 
-func compareSegments(dynamic, regular []string) bool {
+func compareSegments(dynamic, regular []string, opts CompareDynamicOpts) bool {
 	if len(dynamic) == 0 {
 		return len(regular) == 0
 	}
-	if dynamic[0] == WildcardIdentifier {
+	if dynamic[0] == opts.WildcardIdentifier {
 		// Trailing `*` matches one OR MORE remaining segments — never
 		// zero. This is what makes `/etc/*` not match the bare `/etc`
 		// directory, while still matching `/etc/passwd` and any deeper
@@ -416,8 +1137,12 @@ func compareSegments(dynamic, regular []string) bool {
 		// match — user-authored profiles can contain literal /*/*
 		// patterns even though analyzer-generated ones are squashed by
 		// collapseAdjacentDynamicIdentifiers).
-		for i := 0; i <= len(regular); i++ {
-			if compareSegments(dynamic[1:], regular[i:]) {
+		i := 0
+		if opts.NonEmptyWildcard {
+			i = 1
+		}
+		for ; i <= len(regular); i++ {
+			if compareSegments(dynamic[1:], regular[i:], opts) {
 				return true
 			}
 		}
@@ -426,37 +1151,101 @@ func compareSegments(dynamic, regular []string) bool {
 	if len(regular) == 0 {
 		return false
 	}
-	if dynamic[0] == DynamicIdentifier || dynamic[0] == regular[0] {
-		return compareSegments(dynamic[1:], regular[1:])
+	if dynamic[0] == opts.DynamicIdentifier || segmentEqual(dynamic[0], regular[0], opts) {
+		return compareSegments(dynamic[1:], regular[1:], opts)
 	}
 	return false
 }
 
+// segmentEqual compares a static pattern segment a against a regular
+// segment b. A `?` in a matches any single rune of b.
+func segmentEqual(a, b string, opts CompareDynamicOpts) bool {
+	if strings.IndexByte(a, '?') >= 0 {
+		return matchSingleCharWildcards(a, b, opts.CaseInsensitive)
+	}
+	if opts.CaseInsensitive {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// matchSingleCharWildcards walks a and b rune by rune, letting each `?`
+// in a stand for one rune of b. Neither string contains `/`: both are
+// single segments from splitPath.
+func matchSingleCharWildcards(a, b string, caseInsensitive bool) bool {
+	for a != "" && b != "" {
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		switch {
+		case ra == '?', ra == rb:
+		case caseInsensitive && strings.EqualFold(a[:sizeA], b[:sizeB]):
+		default:
+			return false
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return a == "" && b == ""
+}
+
 // FindConfigForPath returns a value copy of the CollapseConfig whose
 // Prefix matches `path` with the longest match. Falls back to the
 // analyzer's default config (Prefix:"/") when no per-prefix override
 // applies, so the result is always meaningful — there is no "no match"
-// signal.
+// signal. The result's Prefix is the prefix that matched ("/" for the
+// default), which is what to log when explaining why a path did or did
+// not collapse. Configs given per identifier to
+// NewPathAnalyzerWithIdentifierConfigs are not consulted.
 //
 // Returning by value keeps the analyzer's internal state immutable
 // from callers. NewPathAnalyzerWithConfigs already makes a defensive
 // inbound copy of `configs`; this is its outbound twin. Without it,
 // `cfg := analyzer.FindConfigForPath(p); cfg.Threshold = 1` would
 // silently mutate the analyzer's threshold map for every future call.
+//
+// ExtensionThresholds is cloned for the same reason: a value copy of the
+// struct would otherwise still share the analyzer's map.
 func (ua *PathAnalyzer) FindConfigForPath(path string) CollapseConfig {
-	bestIdx := -1
-	bestLen := -1
-	for i := range ua.configs {
-		cfg := &ua.configs[i]
-		if hasPrefixAtBoundary(path, cfg.Prefix) && len(cfg.Prefix) > bestLen {
-			bestIdx = i
-			bestLen = len(cfg.Prefix)
-		}
-	}
+	bestIdx := configIndex(ua.configs, path)
 	if bestIdx == -1 {
 		return ua.defaultCfg
 	}
-	return ua.configs[bestIdx]
+	cfg := ua.configs[bestIdx]
+	cfg.ExtensionThresholds = maps.Clone(cfg.ExtensionThresholds)
+	return cfg
+}
+
+// EffectiveThreshold returns the threshold deciding whether the last
+// segment of path collapses into ⋯ together with its siblings in the opens
+// trie. It is EffectiveThresholdFor with the identifier AnalyzeOpens uses.
+func (ua *PathAnalyzer) EffectiveThreshold(p string) int {
+	return ua.EffectiveThresholdFor(p, opensIdentifier)
+}
+
+// EffectiveThresholdFor returns the threshold deciding whether the last
+// segment of path collapses into ⋯ together with its siblings in the trie
+// for identifier: the Threshold of the config matching the parent
+// directory (or the default), unless that config has an
+// ExtensionThresholds entry for the last segment's extension. Configs
+// given per identifier are consulted as AnalyzePath does, and a
+// ThresholdPercent is resolved against the identifier's total. This is the
+// number AnalyzePath compares against when walking path, so unlike
+// FindConfigForPath it needs no further interpretation. NeverCollapse (0)
+// means the segment is never collapsed.
+//
+// MaxDepth is not reflected: segments beyond it are folded into ⋯ or *
+// whatever their threshold.
+func (ua *PathAnalyzer) EffectiveThresholdFor(p, identifier string) int {
+	p = path.Clean(p)
+	ua.mu.RLock()
+	configs := &configResolver{configs: ua.configsFor(identifier), total: ua.totals[identifier]}
+	ua.mu.RUnlock()
+	// Same scope as walkPath: the node at p[:i] decides about its
+	// children, with the rest of the path below it.
+	i := strings.LastIndexByte(p, '/')
+	if i < 0 || p == "/" {
+		return ua.effectiveThreshold(configs, p)
+	}
+	return ua.childCollapseThreshold(configs, p[:i], p[i+1:])
 }
 
 // CollapseAdjacentDynamicIdentifiers replaces runs of adjacent
@@ -465,5 +1254,5 @@ func (ua *PathAnalyzer) FindConfigForPath(path string) CollapseConfig {
 // identifiers prevent collapsing. String wrapper over the internal
 // byte-level collapseAdjacentDynamic, intended for test coverage.
 func CollapseAdjacentDynamicIdentifiers(p string) string {
-	return string(collapseAdjacentDynamic([]byte(p)))
+	return string(collapseAdjacentDynamic([]byte(p), DynamicIdentifier, WildcardIdentifier))
 }
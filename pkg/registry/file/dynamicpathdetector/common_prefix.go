@@ -0,0 +1,61 @@
+package dynamicpathdetector
+
+import (
+	"strings"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+)
+
+// TrimCommonPrefix returns the longest directory all opens lie below,
+// such as /opt/myapp, and a copy of opens with it cut from every path, so
+// /opt/myapp/bin/run is stored as /bin/run. Compacting a container whose
+// opens all sit in one deep tree saves repeating the tree in every entry;
+// RestoreCommonPrefix undoes it. The prefix ends at a path boundary and
+// never at a path itself: with /opt/myapp and /opt/myapp/conf it is /opt.
+// When there is no such directory, or a path is not absolute, the prefix
+// is "" and opens is returned as it is. Flags are shared with opens,
+// which is not modified.
+func TrimCommonPrefix(opens []types.OpenCalls) (prefix string, trimmed []types.OpenCalls) {
+	if len(opens) == 0 {
+		return "", opens
+	}
+	prefix = opens[0].Path
+	for _, open := range opens {
+		if !strings.HasPrefix(open.Path, "/") {
+			return "", opens
+		}
+		for prefix != "" && !isStrictlyBelow(open.Path, prefix) {
+			prefix = prefix[:strings.LastIndexByte(prefix, '/')]
+		}
+	}
+	if prefix == "" {
+		return "", opens
+	}
+	trimmed = make([]types.OpenCalls, len(opens))
+	for i, open := range opens {
+		open.Path = open.Path[len(prefix):]
+		trimmed[i] = open
+	}
+	return prefix, trimmed
+}
+
+// RestoreCommonPrefix puts back the prefix TrimCommonPrefix cut from the
+// paths of trimmed, returning a copy. An empty prefix returns trimmed as
+// it is.
+func RestoreCommonPrefix(prefix string, trimmed []types.OpenCalls) []types.OpenCalls {
+	if prefix == "" {
+		return trimmed
+	}
+	opens := make([]types.OpenCalls, len(trimmed))
+	for i, open := range trimmed {
+		open.Path = prefix + open.Path
+		opens[i] = open
+	}
+	return opens
+}
+
+// isStrictlyBelow reports whether p lies below the directory dir, at a
+// path boundary; a trailing slash makes the directory itself count.
+func isStrictlyBelow(p, dir string) bool {
+	return len(p) > len(dir) && p[len(dir)] == '/' && strings.HasPrefix(p, dir)
+}
@@ -0,0 +1,99 @@
+package dynamicpathdetector
+
+import (
+	"slices"
+	"strings"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+)
+
+// OpensDiff is the difference between two versions of a profile's opens,
+// as reported by DiffOpensDetailed. Every entry's Flags are sorted and
+// deduplicated, and every slice is sorted by path.
+type OpensDiff struct {
+	// Added holds the paths only in the new opens.
+	Added []types.OpenCalls
+	// Removed holds the paths only in the old opens that nothing in the
+	// new opens matches.
+	Removed []types.OpenCalls
+	// Covered holds the paths only in the old opens that a ⋯ or * entry
+	// of the new opens matches, typically a literal folded into a
+	// collapsed entry. They are no longer listed, but their opens are
+	// still allowed.
+	Covered []types.OpenCalls
+	// FlagChanged holds the paths in both whose flags differ, with the
+	// new flags.
+	FlagChanged []types.OpenCalls
+}
+
+// DiffOpens compares two versions of a profile's opens by path, for audit
+// logs of profile drift. removed includes old paths that a new ⋯ or *
+// entry now covers; use DiffOpensDetailed to tell them apart. Duplicate
+// paths within one version have their flags unioned, and flag order does
+// not matter. Neither input slice is modified.
+func DiffOpens(oldOpens, newOpens []types.OpenCalls) (added, removed, flagChanged []types.OpenCalls) {
+	diff := DiffOpensDetailed(oldOpens, newOpens)
+	removed = slices.SortedFunc(slices.Values(slices.Concat(diff.Removed, diff.Covered)), compareOpenPaths)
+	return diff.Added, removed, diff.FlagChanged
+}
+
+// DiffOpensDetailed is DiffOpens with removed paths split into those the
+// new opens no longer allow (Removed) and those a new ⋯ or * entry still
+// matches (Covered). Coverage is decided by CompareDynamic on paths only.
+func DiffOpensDetailed(oldOpens, newOpens []types.OpenCalls) OpensDiff {
+	oldByPath := indexOpens(oldOpens)
+	newByPath := indexOpens(newOpens)
+
+	var diff OpensDiff
+	for p, open := range newByPath {
+		previous, ok := oldByPath[p]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, open)
+		case !slices.Equal(previous.Flags, open.Flags):
+			diff.FlagChanged = append(diff.FlagChanged, open)
+		}
+	}
+	for p, open := range oldByPath {
+		if _, ok := newByPath[p]; ok {
+			continue
+		}
+		if coveredByOpens(p, newOpens) {
+			diff.Covered = append(diff.Covered, open)
+		} else {
+			diff.Removed = append(diff.Removed, open)
+		}
+	}
+
+	slices.SortFunc(diff.Added, compareOpenPaths)
+	slices.SortFunc(diff.Removed, compareOpenPaths)
+	slices.SortFunc(diff.Covered, compareOpenPaths)
+	slices.SortFunc(diff.FlagChanged, compareOpenPaths)
+	return diff
+}
+
+// indexOpens keys opens by path, merging duplicates the way AnalyzeOpens
+// does.
+func indexOpens(opens []types.OpenCalls) map[string]types.OpenCalls {
+	byPath := make(map[string]types.OpenCalls, len(opens))
+	for _, open := range opens {
+		mergeOpen(byPath, open.Path, open.Flags)
+	}
+	return byPath
+}
+
+// coveredByOpens reports whether some entry of opens other than p itself
+// matches p. A literal entry only matches its own path, so in practice
+// this finds the ⋯ and * entries.
+func coveredByOpens(p string, opens []types.OpenCalls) bool {
+	for _, open := range opens {
+		if open.Path != p && CompareDynamic(open.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareOpenPaths(a, b types.OpenCalls) int {
+	return strings.Compare(a.Path, b.Path)
+}
@@ -0,0 +1,110 @@
+package dynamicpathdetector
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ToDOT writes the analyzer's tries as a Graphviz digraph, one cluster
+// per identifier, for inspecting why a path did or did not collapse
+// (render with `dot -Tsvg`). Each node is labelled with its segment,
+// Count and Hits. ⋯ nodes are filled blue and * nodes orange, and nodes
+// where a path ended have a double border. A directory that is the Prefix
+// of a CollapseConfig is outlined red, with that config's Threshold,
+// MaxDepth and MinSegmentsBeforeCollapse in its label. Children are written in sorted order, so the
+// output of an unchanged analyzer is stable.
+func (ua *PathAnalyzer) ToDOT(w io.Writer) error {
+	ua.mu.RLock()
+	var b strings.Builder
+	b.WriteString("digraph PathAnalyzer {\n")
+	b.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	fmt.Fprintf(&b, "\tlabel=%s;\n", strconv.Quote(fmt.Sprintf("default threshold=%d", ua.threshold)))
+	d := &dotWriter{ua: ua, b: &b}
+	for _, identifier := range slices.Sorted(maps.Keys(ua.RootNodes)) {
+		d.configs = ua.configsFor(identifier)
+		fmt.Fprintf(&b, "\tsubgraph %s {\n", strconv.Quote("cluster_"+identifier))
+		fmt.Fprintf(&b, "\t\tlabel=%s;\n", strconv.Quote(identifier))
+		root := d.node(ua.RootNodes[identifier], identifier, "")
+		d.children(ua.RootNodes[identifier], root, "", true)
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("}\n")
+	ua.mu.RUnlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotWriter numbers the nodes of ToDOT's output as they are written.
+type dotWriter struct {
+	ua      *PathAnalyzer
+	b       *strings.Builder
+	configs []CollapseConfig
+	next    int
+}
+
+// node writes node under the given label and returns its DOT id. p is the
+// path leading to node, "" for the root.
+func (d *dotWriter) node(node *SegmentNode, label, p string) string {
+	id := "n" + strconv.Itoa(d.next)
+	d.next++
+
+	var attrs []string
+	if p != "" {
+		label += fmt.Sprintf("\ncount=%d hits=%d", node.Count, node.Hits)
+		if i := slices.IndexFunc(d.configs, func(c CollapseConfig) bool { return c.Prefix == p }); i >= 0 {
+			if d.configs[i].ThresholdPercent > 0 {
+				label += fmt.Sprintf("\nthreshold=%g%%", d.configs[i].ThresholdPercent)
+			} else {
+				label += fmt.Sprintf("\nthreshold=%d", d.configs[i].Threshold)
+			}
+			if d.configs[i].MaxDepth > 0 {
+				label += fmt.Sprintf(" maxDepth=%d", d.configs[i].MaxDepth)
+			}
+			if d.configs[i].MinSegmentsBeforeCollapse > 0 {
+				label += fmt.Sprintf(" minSegments=%d", d.configs[i].MinSegmentsBeforeCollapse)
+			}
+			attrs = append(attrs, "color=red")
+		}
+	}
+	switch {
+	case node.SegmentName == d.ua.wildcardIdentifier:
+		attrs = append(attrs, "style=filled", "fillcolor=orange")
+	case d.ua.dynamicSpan(node.SegmentName) > 0:
+		attrs = append(attrs, "style=filled", "fillcolor=lightblue")
+	}
+	if node.Terminal || node.Dir {
+		attrs = append(attrs, "peripheries=2")
+	}
+	attrs = append([]string{"label=" + strconv.Quote(label)}, attrs...)
+	fmt.Fprintf(d.b, "\t\t%s [%s];\n", id, strings.Join(attrs, ", "))
+	return id
+}
+
+// children writes the subtree below node, whose DOT id is id and whose
+// path is p, in sorted child order.
+func (d *dotWriter) children(node *SegmentNode, id, p string, isRoot bool) {
+	for _, name := range slices.Sorted(maps.Keys(node.Children)) {
+		child := node.Children[name]
+		segment := child.SegmentName
+		var childPath string
+		switch {
+		case isRoot && segment == "":
+			// The empty segment anchoring absolute paths.
+			childPath, segment = "/", "/"
+		case isRoot:
+			childPath = segment
+		case p == "/":
+			childPath = "/" + segment
+		default:
+			childPath = p + "/" + segment
+		}
+		childID := d.node(child, segment, childPath)
+		fmt.Fprintf(d.b, "\t\t%s -> %s;\n", id, childID)
+		d.children(child, childID, childPath, false)
+	}
+}
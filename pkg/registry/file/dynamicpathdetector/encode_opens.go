@@ -0,0 +1,159 @@
+package dynamicpathdetector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+)
+
+// opensBinaryVersion is the first byte of EncodeOpensBinary's output.
+const opensBinaryVersion = 1
+
+// EncodeOpensBinary encodes opens in a compact binary form for storage,
+// typically the collapsed output of AnalyzeOpens, where the same
+// directories, ⋯ segments and flags repeat across many entries.
+//
+// The encoding is a version byte, a table of every distinct path segment
+// and flag in order of first use, and then the opens, each a list of
+// segment indexes followed by a list of flag indexes. All numbers are
+// uvarints and every string is length-prefixed. Paths are split on '/'
+// and joined back as they were, so relative paths, trailing slashes and
+// arbitrary bytes survive. A nil Flags decodes as nil and an empty one as
+// empty; an empty opens decodes as nil. DecodeOpensBinary reverses it.
+func EncodeOpensBinary(opens []types.OpenCalls) []byte {
+	index := make(map[string]uint64)
+	var table []string
+	intern := func(s string) uint64 {
+		i, ok := index[s]
+		if !ok {
+			i = uint64(len(table))
+			index[s] = i
+			table = append(table, s)
+		}
+		return i
+	}
+
+	var body []byte
+	body = binary.AppendUvarint(body, uint64(len(opens)))
+	for _, open := range opens {
+		segments := strings.Split(open.Path, "/")
+		body = binary.AppendUvarint(body, uint64(len(segments)))
+		for _, segment := range segments {
+			body = binary.AppendUvarint(body, intern(segment))
+		}
+		// 0 is a nil Flags, n+1 a Flags of n entries.
+		if open.Flags == nil {
+			body = binary.AppendUvarint(body, 0)
+			continue
+		}
+		body = binary.AppendUvarint(body, uint64(len(open.Flags))+1)
+		for _, flag := range open.Flags {
+			body = binary.AppendUvarint(body, intern(flag))
+		}
+	}
+
+	out := []byte{opensBinaryVersion}
+	out = binary.AppendUvarint(out, uint64(len(table)))
+	for _, s := range table {
+		out = binary.AppendUvarint(out, uint64(len(s)))
+		out = append(out, s...)
+	}
+	return append(out, body...)
+}
+
+// DecodeOpensBinary decodes opens written by EncodeOpensBinary. It
+// returns an error, and no opens, for data that is truncated, has
+// trailing bytes, refers past its string table or has an unknown version.
+func DecodeOpensBinary(data []byte) ([]types.OpenCalls, error) {
+	if len(data) == 0 {
+		return nil, errors.New("decode opens: empty input")
+	}
+	if data[0] != opensBinaryVersion {
+		return nil, fmt.Errorf("decode opens: unsupported version %d", data[0])
+	}
+	d := &opensDecoder{data: data[1:]}
+
+	table := make([]string, d.count())
+	for i := range table {
+		n := d.count()
+		if d.err != nil {
+			break
+		}
+		table[i] = string(d.data[:n])
+		d.data = d.data[n:]
+	}
+	str := func() string {
+		i := d.uvarint()
+		if d.err == nil && i >= uint64(len(table)) {
+			d.err = fmt.Errorf("string index %d out of range for %d strings", i, len(table))
+		}
+		if d.err != nil {
+			return ""
+		}
+		return table[i]
+	}
+
+	var opens []types.OpenCalls
+	if n := d.count(); n > 0 {
+		opens = make([]types.OpenCalls, n)
+	}
+	for i := range opens {
+		segments := make([]string, d.count())
+		for j := range segments {
+			segments[j] = str()
+		}
+		opens[i].Path = strings.Join(segments, "/")
+		flags := d.uvarint()
+		if flags == 0 || d.err != nil {
+			continue
+		}
+		opens[i].Flags = make([]string, 0, min(flags-1, uint64(len(d.data))))
+		for j := uint64(1); j < flags && d.err == nil; j++ {
+			opens[i].Flags = append(opens[i].Flags, str())
+		}
+	}
+	if d.err == nil && len(d.data) > 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.data))
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("decode opens: %w", d.err)
+	}
+	return opens, nil
+}
+
+// opensDecoder reads uvarints off the front of data, keeping the first
+// error so DecodeOpensBinary can check once at the end.
+type opensDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *opensDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errors.New("truncated or overlong uvarint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// count reads a length that must fit in the remaining input, since every
+// element it counts takes at least one byte. This keeps corrupt input
+// from making DecodeOpensBinary allocate more than its own size.
+func (d *opensDecoder) count() int {
+	v := d.uvarint()
+	if d.err == nil && v > uint64(len(d.data)) {
+		d.err = fmt.Errorf("count %d exceeds the %d bytes left", v, len(d.data))
+	}
+	if d.err != nil {
+		return 0
+	}
+	return int(v)
+}
@@ -0,0 +1,139 @@
+package dynamicpathdetector
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+)
+
+// foldToFit rewrites results, the paths collapseOpens stores opens under
+// ("" for dropped ones), so that together with the SBOM-listed paths of
+// opens there are at most maxResults distinct entries, as
+// AnalyzeOpensWithMaxResults describes. Results that already fit are left
+// alone.
+func (ua *PathAnalyzer) foldToFit(opens []types.OpenCalls, results []string, sbomSet mapset.Set[string], maxResults int) {
+	unique := make(map[string]struct{}, len(results))
+	for _, r := range results {
+		if r != "" {
+			unique[r] = struct{}{}
+		}
+	}
+	fixed := make(map[string]struct{})
+	for i := range opens {
+		if sbomSet.ContainsOne(opens[i].Path) {
+			fixed[opens[i].Path] = struct{}{}
+		}
+	}
+	budget := maxResults - len(fixed)
+	if len(unique) <= budget {
+		return
+	}
+
+	paths := slices.Sorted(maps.Keys(unique))
+	children := resultChildren(paths)
+	widest := 0
+	for _, names := range children {
+		widest = max(widest, len(names))
+	}
+
+	// Lowering the threshold only ever moves a fold to a shallower
+	// directory, so the entry count shrinks monotonically with it: search
+	// for the highest threshold that fits. At widest nothing folds.
+	lo, hi := 0, widest
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if countTargets(ua.foldAt(paths, children, mid)) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	folded := ua.foldAt(paths, children, lo)
+	for i, r := range results {
+		if r != "" {
+			results[i] = folded[r]
+		}
+	}
+}
+
+// resultChildren returns the distinct child segment names below every
+// directory of paths, keyed by the directory without its trailing slash
+// ("" for the root).
+func resultChildren(paths []string) map[string]map[string]struct{} {
+	children := make(map[string]map[string]struct{})
+	for _, p := range paths {
+		t := trimTrailingSlash(p)
+		for i := 0; i < len(t); i++ {
+			if t[i] != '/' {
+				continue
+			}
+			child := t[i+1:]
+			if j := strings.IndexByte(child, '/'); j >= 0 {
+				child = child[:j]
+			}
+			if child == "" {
+				continue
+			}
+			dir := t[:i]
+			if children[dir] == nil {
+				children[dir] = make(map[string]struct{})
+			}
+			children[dir][child] = struct{}{}
+		}
+	}
+	return children
+}
+
+// foldAt maps every path to the entry it is stored under when the
+// threshold is lowered to threshold: below the shallowest directory with
+// more than threshold children, everything becomes dir/⋯, or dir/* when
+// some of it is deeper than one segment.
+func (ua *PathAnalyzer) foldAt(paths []string, children map[string]map[string]struct{}, threshold int) map[string]string {
+	out := make(map[string]string, len(paths))
+	members := make(map[string][]string)
+	for _, p := range paths {
+		out[p] = p
+		t := trimTrailingSlash(p)
+		for i := 0; i < len(t); i++ {
+			if t[i] == '/' && len(children[t[:i]]) > threshold {
+				members[t[:i]] = append(members[t[:i]], p)
+				break
+			}
+		}
+	}
+	for dir, folded := range members {
+		name := ua.dynamicIdentifier
+		for _, p := range folded {
+			if rest := trimTrailingSlash(p)[len(dir)+1:]; strings.IndexByte(rest, '/') >= 0 || rest == ua.wildcardIdentifier {
+				name = ua.wildcardIdentifier
+				break
+			}
+		}
+		target := string(collapseAdjacentDynamic([]byte(dir+"/"+name), ua.dynamicIdentifier, ua.wildcardIdentifier))
+		for _, p := range folded {
+			out[p] = target
+		}
+	}
+	return out
+}
+
+// countTargets returns the number of distinct entries in folded.
+func countTargets(folded map[string]string) int {
+	targets := make(map[string]struct{}, len(folded))
+	for _, target := range folded {
+		targets[target] = struct{}{}
+	}
+	return len(targets)
+}
+
+// trimTrailingSlash drops the trailing slash of a directory entry kept by
+// PreserveTrailingSlash; "/" stays as it is.
+func trimTrailingSlash(p string) string {
+	if len(p) > 1 && p[len(p)-1] == '/' {
+		return p[:len(p)-1]
+	}
+	return p
+}
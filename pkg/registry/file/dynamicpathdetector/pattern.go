@@ -0,0 +1,132 @@
+package dynamicpathdetector
+
+import (
+	"cmp"
+	"errors"
+	"strings"
+)
+
+// ErrEmptyPattern is returned by CompilePattern for an empty pattern,
+// which CompareDynamic treats as matching nothing.
+var ErrEmptyPattern = errors.New("empty dynamic path pattern")
+
+// CompiledPattern is a dynamic path pattern split into segments once, for
+// callers that test many runtime paths against the same stored profile
+// entry. Matches has the same semantics as CompareDynamic. A
+// CompiledPattern is immutable and safe for concurrent use.
+type CompiledPattern struct {
+	pattern  string
+	segments []string
+	opts     CompareDynamicOpts
+}
+
+// CompilePattern precomputes the segment structure of dynamicPath so
+// repeated Matches calls only have to split the candidate path.
+func CompilePattern(dynamicPath string) (*CompiledPattern, error) {
+	return CompilePatternWithOptions(dynamicPath, CompareDynamicOpts{})
+}
+
+// CompilePatternWithOptions is CompilePattern for matching as
+// CompareDynamicWithOptions does with opts, e.g. against the profile of an
+// analyzer built with NewPathAnalyzerWithIdentifiers.
+func CompilePatternWithOptions(dynamicPath string, opts CompareDynamicOpts) (*CompiledPattern, error) {
+	if dynamicPath == "" {
+		return nil, ErrEmptyPattern
+	}
+	return &CompiledPattern{
+		pattern:  dynamicPath,
+		segments: splitPath(dynamicPath),
+		opts:     opts.withDefaultIdentifiers(),
+	}, nil
+}
+
+// Matches reports whether path is matched by the compiled pattern; it is
+// equivalent to CompareDynamicWithOptions(pattern, path, opts) with the
+// options it was compiled with.
+func (c *CompiledPattern) Matches(path string) bool {
+	if path == "" {
+		return false
+	}
+	return compareSegments(c.segments, splitPath(NormalizePath(path)), c.opts)
+}
+
+// String returns the pattern the matcher was compiled from.
+func (c *CompiledPattern) String() string {
+	return c.pattern
+}
+
+// IsDynamic reports whether path is a pattern rather than a literal path,
+// that is whether one of its segments is DynamicIdentifier or
+// WildcardIdentifier. Like CompareDynamic, it only recognises them as
+// whole segments: `/a/*` is dynamic but `/a/b*c` and `/lib.so.⋯` are
+// literal names. `?` globs inside a segment are not considered.
+func IsDynamic(path string) bool {
+	return IsDynamicWithOptions(path, CompareDynamicOpts{})
+}
+
+// IsDynamicWithOptions is IsDynamic for the identifiers of opts; its other
+// fields do not matter here.
+func IsDynamicWithOptions(path string, opts CompareDynamicOpts) bool {
+	opts = opts.withDefaultIdentifiers()
+	for segment := range strings.SplitSeq(path, "/") {
+		if segment == opts.DynamicIdentifier || segment == opts.WildcardIdentifier {
+			return true
+		}
+	}
+	return false
+}
+
+// MoreSpecific compares two dynamic path patterns by how specific they
+// are, for picking among several stored patterns that match the same
+// runtime path. It returns a negative number when a is more specific
+// than b, a positive number when b is, and 0 when neither is, so
+// slices.SortFunc(patterns, MoreSpecific) puts the most specific first.
+//
+// More whole literal segments win; between equal counts, fewer *, then
+// fewer ⋯, then fewer segments with a ? glob win. So
+// `/app/config/x` ranks before `/app/config/⋯`, which ranks before
+// `/app/*`. Trailing slashes are ignored.
+func MoreSpecific(a, b string) int {
+	return MoreSpecificWithOptions(a, b, CompareDynamicOpts{})
+}
+
+// MoreSpecificWithOptions is MoreSpecific for patterns written with the
+// identifiers of opts; its other fields do not matter here.
+func MoreSpecificWithOptions(a, b string, opts CompareDynamicOpts) int {
+	opts = opts.withDefaultIdentifiers()
+	sa, sb := patternSpecificity(a, &opts), patternSpecificity(b, &opts)
+	if c := cmp.Compare(sb.literals, sa.literals); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(sa.wildcards, sb.wildcards); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(sa.dynamics, sb.dynamics); c != 0 {
+		return c
+	}
+	return cmp.Compare(sa.globs, sb.globs)
+}
+
+// specificity counts the segments of a pattern by kind.
+type specificity struct {
+	literals, wildcards, dynamics, globs int
+}
+
+func patternSpecificity(pattern string, opts *CompareDynamicOpts) specificity {
+	var s specificity
+	for _, segment := range splitPath(pattern) {
+		switch {
+		case segment == "":
+			// The anchor of an absolute path.
+		case segment == opts.WildcardIdentifier:
+			s.wildcards++
+		case segment == opts.DynamicIdentifier:
+			s.dynamics++
+		case strings.IndexByte(segment, '?') >= 0:
+			s.globs++
+		default:
+			s.literals++
+		}
+	}
+	return s
+}
@@ -0,0 +1,187 @@
+package dynamicpathdetector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatternSet is a list of dynamic path patterns compiled into one segment
+// trie, for rule engines that test every runtime path against all the
+// stored entries of a profile. Literal segments are looked up by key, so
+// matching a path only visits the patterns that share its prefix instead
+// of calling CompareDynamic once per pattern. Match has the same
+// semantics as CompareDynamicWithOptions with the options the set was
+// compiled with. A PatternSet is immutable and safe for concurrent use.
+type PatternSet struct {
+	patterns []string
+	root     *patternNode
+	opts     CompareDynamicOpts
+}
+
+// patternNode is one pattern segment in a PatternSet trie. Pattern
+// indices are positions in PatternSet.patterns; -1 means none.
+type patternNode struct {
+	literal  map[string]*patternNode
+	glob     map[string]*patternNode // static segments containing ?
+	dynamic  *patternNode            // ⋯
+	wildcard *patternNode            // * with more segments after it
+	end      int                     // first pattern ending at this node
+	trailing int                     // first pattern ending in * right after this node
+	first    int                     // first pattern anywhere in this subtree
+}
+
+func newPatternNode(first int) *patternNode {
+	return &patternNode{end: -1, trailing: -1, first: first}
+}
+
+// CompilePatterns compiles patterns into a PatternSet. Like CompilePattern
+// it rejects an empty pattern, reporting its index.
+func CompilePatterns(patterns []string) (*PatternSet, error) {
+	return CompilePatternsWithOptions(patterns, CompareDynamicOpts{})
+}
+
+// CompilePatternsWithOptions is CompilePatterns for matching as
+// CompareDynamicWithOptions does with opts, e.g. against the profile of an
+// analyzer built with NewPathAnalyzerWithIdentifiers. A case-insensitive
+// set compares literal segments one by one instead of by key.
+func CompilePatternsWithOptions(patterns []string, opts CompareDynamicOpts) (*PatternSet, error) {
+	set := &PatternSet{
+		patterns: append([]string(nil), patterns...),
+		root:     newPatternNode(-1),
+		opts:     opts.withDefaultIdentifiers(),
+	}
+	for i, pattern := range patterns {
+		if pattern == "" {
+			return nil, fmt.Errorf("pattern %d: %w", i, ErrEmptyPattern)
+		}
+		set.insert(splitPath(pattern), i)
+	}
+	return set, nil
+}
+
+// insert adds the pattern at index i. Patterns are inserted in order, so
+// a node's first is the index of the pattern that created it.
+func (s *PatternSet) insert(segments []string, i int) {
+	node := s.root
+	if node.first < 0 {
+		node.first = i
+	}
+	for j, segment := range segments {
+		if segment == s.opts.WildcardIdentifier && j == len(segments)-1 {
+			if node.trailing < 0 {
+				node.trailing = i
+			}
+			return
+		}
+		node = node.child(segment, i, &s.opts)
+	}
+	if node.end < 0 {
+		node.end = i
+	}
+}
+
+// child returns the child for the pattern segment, creating it for the
+// pattern at index i if needed.
+func (n *patternNode) child(segment string, i int, opts *CompareDynamicOpts) *patternNode {
+	switch {
+	case segment == opts.DynamicIdentifier:
+		if n.dynamic == nil {
+			n.dynamic = newPatternNode(i)
+		}
+		return n.dynamic
+	case segment == opts.WildcardIdentifier:
+		if n.wildcard == nil {
+			n.wildcard = newPatternNode(i)
+		}
+		return n.wildcard
+	}
+	children := &n.literal
+	if strings.IndexByte(segment, '?') >= 0 {
+		children = &n.glob
+	}
+	if *children == nil {
+		*children = make(map[string]*patternNode)
+	}
+	c, ok := (*children)[segment]
+	if !ok {
+		c = newPatternNode(i)
+		(*children)[segment] = c
+	}
+	return c
+}
+
+// Match reports whether any pattern in the set matches path and, if so,
+// returns the first such pattern in the order given to CompilePatterns.
+func (s *PatternSet) Match(path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	best := s.root.match(splitPath(NormalizePath(path)), len(s.patterns), &s.opts)
+	if best == len(s.patterns) {
+		return "", false
+	}
+	return s.patterns[best], true
+}
+
+// Patterns returns the patterns the set was compiled from, in order.
+func (s *PatternSet) Patterns() []string {
+	return append([]string(nil), s.patterns...)
+}
+
+// match returns the index of the first pattern below n that matches
+// regular, or best if none matches before it. Subtrees whose first
+// pattern is not before best cannot improve on it and are skipped. The
+// cases mirror compareSegments.
+func (n *patternNode) match(regular []string, best int, opts *CompareDynamicOpts) int {
+	if n == nil || n.first >= best {
+		return best
+	}
+	if len(regular) == 0 && n.end >= 0 {
+		best = min(best, n.end)
+	}
+	if len(regular) > 0 && n.trailing >= 0 {
+		best = min(best, n.trailing)
+	}
+	if n.wildcard != nil {
+		i := 0
+		if opts.NonEmptyWildcard {
+			i = 1
+		}
+		for ; i <= len(regular); i++ {
+			best = n.wildcard.match(regular[i:], best, opts)
+		}
+	}
+	if len(regular) == 0 {
+		return best
+	}
+	segment, rest := regular[0], regular[1:]
+	if opts.CaseInsensitive {
+		for pattern, c := range n.literal {
+			if c.first < best && strings.EqualFold(pattern, segment) {
+				best = c.match(rest, best, opts)
+			}
+		}
+	} else {
+		best = n.literal[segment].match(rest, best, opts)
+	}
+	best = n.dynamic.match(rest, best, opts)
+	for pattern, c := range n.glob {
+		if c.first < best && matchSingleCharWildcards(pattern, segment, opts.CaseInsensitive) {
+			best = c.match(rest, best, opts)
+		}
+	}
+	return best
+}
+
+// MatchAny reports whether any of patterns matches path, returning the
+// first matching pattern in order. It compiles patterns on every call;
+// callers testing many paths against the same list should compile it once
+// with CompilePatterns. The error is that of CompilePatterns.
+func MatchAny(patterns []string, path string) (bool, string, error) {
+	set, err := CompilePatterns(patterns)
+	if err != nil {
+		return false, "", err
+	}
+	match, ok := set.Match(path)
+	return ok, match, nil
+}
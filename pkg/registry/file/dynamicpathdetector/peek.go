@@ -0,0 +1,56 @@
+package dynamicpathdetector
+
+import "maps"
+
+// PeekPath returns what AnalyzePath would return for p right now, without
+// inserting p or collapsing anything. Repeated calls never move the
+// collapse boundary, which makes it suitable for admission-style checks
+// of candidate paths against an already-trained analyzer.
+//
+// The result reflects the current trie only: a path that AnalyzePath
+// would make the (threshold+1)-th child of a directory does not collapse
+// here either, because AnalyzePath only collapses on the next walk.
+func (ua *PathAnalyzer) PeekPath(p, identifier string) (string, error) {
+	p, dir := ua.cleanPath(p)
+	ua.mu.RLock()
+	defer ua.mu.RUnlock()
+	node, exists := ua.RootNodes[identifier]
+	if !exists {
+		// Walked as an empty trie, but never stored.
+		node = newSegmentNode(identifier)
+	}
+	return ua.processSegments(node, &configResolver{configs: ua.configsFor(identifier), total: ua.totals[identifier]}, p, dir, walkPeek), nil
+}
+
+// nodeCopies holds the nodes a walkPeek walk has copied, which it may
+// change freely. A real walk has a nil nodeCopies and changes the trie in
+// place.
+type nodeCopies map[*SegmentNode]struct{}
+
+// own returns the node a walk may change in place of node: node itself
+// in a real walk or once copied, a fresh copy otherwise. The copy has its
+// own Children map but shares the children, which are owned in turn
+// before they change.
+func (nc nodeCopies) own(node *SegmentNode) *SegmentNode {
+	if nc == nil {
+		return node
+	}
+	if _, ok := nc[node]; ok {
+		return node
+	}
+	owned := *node
+	owned.Children = maps.Clone(node.Children)
+	nc[&owned] = struct{}{}
+	return &owned
+}
+
+// ownChild is own for parent's child name, which it replaces with the
+// copy so later lookups under parent find it. parent must be a node the
+// walk may change: owned, or created by the walk itself.
+func (nc nodeCopies) ownChild(parent *SegmentNode, name string, child *SegmentNode) *SegmentNode {
+	owned := nc.own(child)
+	if owned != child {
+		parent.Children[name] = owned
+	}
+	return owned
+}
@@ -0,0 +1,45 @@
+package dynamicpathdetector
+
+// Prune drops rarely seen paths from every trie: any subtree whose node
+// was walked by fewer than minCount analyzed paths (its Hits) is removed,
+// so one-off paths stop accumulating in long-running profiles. ⋯ and *
+// nodes are aggregates of many paths and are never removed, and neither
+// is any node on the way to one. The Count of a node that loses children
+// is reduced accordingly, so later collapse decisions only see the
+// children that are left. A non-positive minCount is a no-op.
+//
+// Hits counts entries of the input, once each (see SegmentNode), so after
+// AnalyzeOpens an open listed once has 1 and survives only Prune(1).
+func (ua *PathAnalyzer) Prune(minCount int) {
+	if minCount <= 0 {
+		return
+	}
+	ua.mu.Lock()
+	defer ua.mu.Unlock()
+	for _, root := range ua.RootNodes {
+		ua.pruneChildren(root, minCount)
+	}
+}
+
+// pruneChildren prunes bottom-up so a rare node is only removed once none
+// of its descendants had to be kept. A directory that loses all of its
+// children is removed too unless some analyzed path ended there, so it
+// does not show up in GetStoredPaths as a path nobody opened.
+func (ua *PathAnalyzer) pruneChildren(node *SegmentNode, minCount int) {
+	removed := 0
+	for name, child := range node.Children {
+		hadChildren := len(child.Children) > 0
+		ua.pruneChildren(child, minCount)
+		switch {
+		case name == ua.dynamicIdentifier || name == ua.wildcardIdentifier:
+			continue
+		case len(child.Children) > 0:
+			continue
+		case child.Hits >= minCount && (child.Terminal || child.Dir || !hadChildren):
+			continue
+		}
+		delete(node.Children, name)
+		removed++
+	}
+	node.Count = max(node.Count-removed, 0)
+}
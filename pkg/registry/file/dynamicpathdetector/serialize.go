@@ -0,0 +1,103 @@
+package dynamicpathdetector
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// pathAnalyzerJSON is the wire form of a PathAnalyzer. The analyzer keeps
+// its thresholds unexported so callers cannot mutate them after
+// construction; this struct exposes them only for (de)serialization.
+type pathAnalyzerJSON struct {
+	RootNodes     map[string]*SegmentNode     `json:"rootNodes"`
+	Threshold     int                         `json:"threshold"`
+	Configs       []CollapseConfig            `json:"configs,omitempty"`
+	PerIdentifier map[string][]CollapseConfig `json:"identifierConfigs,omitempty"`
+	DefaultConfig CollapseConfig              `json:"defaultConfig"`
+	Dynamic       string                      `json:"dynamicIdentifier,omitempty"`
+	Wildcard      string                      `json:"wildcardIdentifier,omitempty"`
+	MaxDepth      int                         `json:"maxDepth,omitempty"`
+	Adjacent      bool                        `json:"collapseAdjacent,omitempty"`
+	TrailingSlash bool                        `json:"preserveTrailingSlash,omitempty"`
+	MinChildHits  int                         `json:"minChildHits,omitempty"`
+	Totals        map[string]int              `json:"totals,omitempty"`
+}
+
+// MarshalJSON serializes the learned trie together with the collapse
+// configuration and identifiers, so a warmed-up analyzer can be shipped to
+// another replica. Collapsed ⋯ / * nodes and their Count fields are preserved
+// as-is; nothing is recomputed on the way out.
+func (ua *PathAnalyzer) MarshalJSON() ([]byte, error) {
+	ua.mu.RLock()
+	defer ua.mu.RUnlock()
+	return json.Marshal(pathAnalyzerJSON{
+		RootNodes:     ua.RootNodes,
+		Threshold:     ua.threshold,
+		Configs:       ua.configs,
+		PerIdentifier: ua.identifierConfigs,
+		DefaultConfig: ua.defaultCfg,
+		Dynamic:       ua.dynamicIdentifier,
+		Wildcard:      ua.wildcardIdentifier,
+		MaxDepth:      ua.MaxDepth,
+		Adjacent:      ua.CollapseAdjacent,
+		TrailingSlash: ua.PreserveTrailingSlash,
+		MinChildHits:  ua.MinChildHits,
+		Totals:        ua.totals,
+	})
+}
+
+// UnmarshalJSON restores an analyzer produced by MarshalJSON. After a
+// round-trip, AnalyzePath makes the same collapse decisions as the
+// original analyzer did.
+func (ua *PathAnalyzer) UnmarshalJSON(data []byte) error {
+	var wire pathAnalyzerJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return fmt.Errorf("unmarshal path analyzer: %w", err)
+	}
+	if wire.RootNodes == nil {
+		wire.RootNodes = make(map[string]*SegmentNode)
+	}
+	for identifier, node := range wire.RootNodes {
+		if node == nil {
+			return fmt.Errorf("unmarshal path analyzer: nil root node for identifier %q", identifier)
+		}
+		normalizeChildren(node)
+	}
+	if wire.DefaultConfig.Prefix == "" {
+		wire.DefaultConfig = CollapseConfig{Prefix: "/", Threshold: wire.Threshold}
+	}
+	if wire.Dynamic == "" {
+		wire.Dynamic = DynamicIdentifier
+	}
+	if wire.Wildcard == "" {
+		wire.Wildcard = WildcardIdentifier
+	}
+	ua.mu.Lock()
+	defer ua.mu.Unlock()
+	ua.RootNodes = wire.RootNodes
+	ua.threshold = wire.Threshold
+	ua.configs = wire.Configs
+	ua.identifierConfigs = wire.PerIdentifier
+	ua.defaultCfg = wire.DefaultConfig
+	ua.dynamicIdentifier = wire.Dynamic
+	ua.wildcardIdentifier = wire.Wildcard
+	ua.MaxDepth = wire.MaxDepth
+	ua.CollapseAdjacent = wire.Adjacent
+	ua.PreserveTrailingSlash = wire.TrailingSlash
+	ua.MinChildHits = wire.MinChildHits
+	ua.totals = wire.Totals
+	return nil
+}
+
+// normalizeChildren walks the subtree rooted at node and drops any nil
+// child entries. A nil Children map is left nil: leaves allocate theirs
+// on first insert.
+func normalizeChildren(node *SegmentNode) {
+	for name, child := range node.Children {
+		if child == nil {
+			delete(node.Children, name)
+			continue
+		}
+		normalizeChildren(child)
+	}
+}
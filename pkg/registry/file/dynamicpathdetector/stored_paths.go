@@ -0,0 +1,102 @@
+package dynamicpathdetector
+
+import (
+	"slices"
+	"strings"
+)
+
+// GetStoredPaths returns the paths currently stored in the trie for
+// identifier, in sorted order. A path is stored if an analyzed path ended
+// there, even when longer paths continue below it. Each path is reported
+// as AnalyzePath would emit it, so collapsed segments show up as ⋯ or *,
+// and a directory path analyzed with PreserveTrailingSlash keeps its
+// trailing slash. Returns nil when the identifier has never been analyzed.
+func (ua *PathAnalyzer) GetStoredPaths(identifier string) []string {
+	ua.mu.RLock()
+	defer ua.mu.RUnlock()
+	root, ok := ua.RootNodes[identifier]
+	if !ok {
+		return nil
+	}
+	var paths []string
+	walkStoredPaths(root, "", true, ua.dynamicIdentifier, ua.wildcardIdentifier, func(p string, node *SegmentNode, leaf bool) {
+		if node.Dir {
+			paths = append(paths, p+"/")
+		}
+		// A leaf that only directory paths ended at has no file form.
+		if leaf && (node.Terminal || !node.Dir) {
+			paths = append(paths, p)
+		}
+	})
+	slices.Sort(paths)
+	return paths
+}
+
+// DynamicPaths returns the stored paths for identifier that have a
+// collapsed segment, ⋯ or * (or the analyzer's own identifiers), in
+// sorted order: GetStoredPaths without the paths kept literal, which
+// shows at a glance where cardinality exploded. Returns nil when nothing
+// has collapsed or the identifier has never been analyzed.
+func (ua *PathAnalyzer) DynamicPaths(identifier string) []string {
+	var dynamic []string
+	for _, p := range ua.GetStoredPaths(identifier) {
+		if slices.ContainsFunc(strings.Split(strings.TrimSuffix(p, "/"), "/"), func(segment string) bool {
+			return segment == ua.dynamicIdentifier || segment == ua.wildcardIdentifier
+		}) {
+			dynamic = append(dynamic, p)
+		}
+	}
+	return dynamic
+}
+
+// GetStoredPathsWithCounts returns every path in the trie for identifier,
+// directories included, mapped to its node's Count — the number of
+// distinct child segments seen under it. Count is not reset when children
+// collapse, so a directory that folded its children into ⋯ still reports
+// how many it absorbed, and the ⋯ / * node itself reports the distinct
+// grandchildren merged into it. Leaves report 0. Sorting the result by
+// value is the quickest way to find hot directories.
+func (ua *PathAnalyzer) GetStoredPathsWithCounts(identifier string) map[string]int {
+	ua.mu.RLock()
+	defer ua.mu.RUnlock()
+	root, ok := ua.RootNodes[identifier]
+	if !ok {
+		return nil
+	}
+	counts := make(map[string]int)
+	walkStoredPaths(root, "", true, ua.dynamicIdentifier, ua.wildcardIdentifier, func(p string, node *SegmentNode, _ bool) {
+		counts[p] = node.Count
+	})
+	return counts
+}
+
+// walkStoredPaths calls visit for every descendant of node with the path
+// leading to it and whether it ends a stored path. The root node (the
+// identifier) contributes no segment, and the empty segment that anchors
+// absolute paths below it is descended into without being visited. A
+// wildcard node is always treated as a leaf and not descended into,
+// mirroring processSegments, which never emits anything after a *.
+func walkStoredPaths(node *SegmentNode, prefix string, isRoot bool, dynamic, wildcard string, visit func(p string, node *SegmentNode, leaf bool)) {
+	for name, child := range node.Children {
+		// A dynamic run is keyed ⋯ but named after all the levels it
+		// covers; report it under its name like the unmerged chain.
+		segment := name
+		if name == dynamic && child.SegmentName != "" {
+			segment = child.SegmentName
+		}
+		p := segment
+		if !isRoot {
+			p = prefix + "/" + segment
+		}
+		if p == "" {
+			walkStoredPaths(child, p, false, dynamic, wildcard, visit)
+			continue
+		}
+		if name == wildcard {
+			visit(p, child, true)
+			continue
+		}
+		visit(p, child, child.Terminal || len(child.Children) == 0)
+		walkStoredPaths(child, p, false, dynamic, wildcard, visit)
+	}
+}
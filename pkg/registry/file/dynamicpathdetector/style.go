@@ -0,0 +1,96 @@
+package dynamicpathdetector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DynamicStyle selects how collapsed segments are written into stored
+// profiles. It only affects output: the trie always uses
+// DynamicIdentifier and WildcardIdentifier internally.
+type DynamicStyle string
+
+const (
+	// DynamicStyleEllipsis writes ⋯ for one segment and * for any number
+	// of segments, exactly as the analyzer produces them. The zero value
+	// DynamicStyle("") means the same.
+	DynamicStyleEllipsis DynamicStyle = "ellipsis"
+	// DynamicStyleSingleStar writes * for both, for consumers that cannot
+	// parse ⋯. The one-segment / many-segments distinction is lost, so a
+	// path in this style cannot be analyzed again as it was; it suits
+	// exports, and the profile processors refuse it.
+	DynamicStyleSingleStar DynamicStyle = "singleStarPerSegment"
+	// DynamicStyleDoubleStar writes * for one segment and ** for any
+	// number of segments, the usual glob convention.
+	DynamicStyleDoubleStar DynamicStyle = "doubleStar"
+)
+
+// ParseDynamicStyle validates a configured style name. The empty string
+// selects DynamicStyleEllipsis.
+func ParseDynamicStyle(s string) (DynamicStyle, error) {
+	switch style := DynamicStyle(s); style {
+	case "", DynamicStyleEllipsis:
+		return DynamicStyleEllipsis, nil
+	case DynamicStyleSingleStar, DynamicStyleDoubleStar:
+		return style, nil
+	default:
+		return DynamicStyleEllipsis, fmt.Errorf("unknown dynamic style %q", s)
+	}
+}
+
+// FormatDynamicPath rewrites the ⋯ and * segments of an analyzed path into
+// style. Only whole segments are rewritten; other segments are copied
+// as-is.
+//
+// Styles other than DynamicStyleEllipsis are meant for consumers of the
+// stored profile. Their output must go through ParseDynamicPath before it
+// is fed back into a PathAnalyzer, which would read every * as
+// WildcardIdentifier.
+//
+// Only the package identifiers DynamicIdentifier and WildcardIdentifier
+// are rewritten, as the analyzers of the profile processors use them; a
+// path from an analyzer built with NewPathAnalyzerWithIdentifiers keeps
+// its own identifiers.
+func FormatDynamicPath(p string, style DynamicStyle) string {
+	if style == "" || style == DynamicStyleEllipsis {
+		return p
+	}
+	if !strings.Contains(p, DynamicIdentifier) && !strings.Contains(p, WildcardIdentifier) {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		switch {
+		case segment == DynamicIdentifier:
+			segments[i] = "*"
+		case segment == WildcardIdentifier && style == DynamicStyleDoubleStar:
+			segments[i] = "**"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// ParseDynamicPath undoes FormatDynamicPath, so a profile stored in style
+// can be analyzed again. Under DynamicStyleDoubleStar * reads back as ⋯
+// and ** as *. DynamicStyleSingleStar cannot tell them apart and reads
+// every * back as ⋯, which keeps its siblings apart rather than folding
+// them into a wildcard but narrows a stored * to one segment; formatting
+// the result again gives the same path.
+// Like FormatDynamicPath it only knows the package identifiers: the result
+// is written with DynamicIdentifier and WildcardIdentifier whichever
+// analyzer it is fed to.
+func ParseDynamicPath(p string, style DynamicStyle) string {
+	if style == "" || style == DynamicStyleEllipsis || !strings.Contains(p, "*") {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		switch {
+		case segment == "*":
+			segments[i] = DynamicIdentifier
+		case segment == "**" && style == DynamicStyleDoubleStar:
+			segments[i] = WildcardIdentifier
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,75 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertNoSharedNodes fails when a node is reachable from root along
+// more than one path.
+func assertNoSharedNodes(t *testing.T, root *dynamicpathdetector.SegmentNode) {
+	t.Helper()
+	seen := make(map[*dynamicpathdetector.SegmentNode]string)
+	var walk func(node *dynamicpathdetector.SegmentNode, p string)
+	walk = func(node *dynamicpathdetector.SegmentNode, p string) {
+		if other, ok := seen[node]; ok {
+			t.Errorf("node at %s is shared with %s", p, other)
+			return
+		}
+		seen[node] = p
+		for name, child := range node.Children {
+			walk(child, p+"/"+name)
+		}
+	}
+	walk(root, "")
+}
+
+// TestCollapseCountsSharedChildrenOnce collapses /a/d{0..3}, whose
+// subtrees all hold sub/f0 and sub/f1: the merged sub has two children,
+// not one pair per absorbed directory, so it stays below the threshold.
+func TestCollapseCountsSharedChildrenOnce(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(3)
+	var paths []string
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 2; j++ {
+			paths = append(paths, fmt.Sprintf("/a/d%d/sub/f%d", i, j))
+		}
+	}
+	last := paths[len(paths)-1]
+	// /a exceeds the threshold with d3, and the walk of the last path
+	// collapses it; PeekPath must see the same merge beforehand.
+	analyzeAll(t, analyzer, paths[:len(paths)-1]...)
+	peeked, err := analyzer.PeekPath(last, "opens")
+	require.NoError(t, err)
+	got, err := analyzer.AnalyzePath(last, "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/a/⋯/sub/f1", got)
+	assert.Equal(t, got, peeked)
+
+	root := analyzer.RootNodes["opens"]
+	assertNoSharedNodes(t, root)
+	assert.Equal(t, map[string]int{
+		"/a":          4,
+		"/a/⋯":        1,
+		"/a/⋯/sub":    2,
+		"/a/⋯/sub/f0": 0,
+		"/a/⋯/sub/f1": 0,
+	}, analyzer.GetStoredPathsWithCounts("opens"))
+
+	// Later inserts below the ⋯ node count from the merged state.
+	analyzeAll(t, analyzer, "/a/d9/sub/f2", "/a/d9/sub/f2/x", "/a/d0/sub/f0")
+	assertNoSharedNodes(t, root)
+	assert.Equal(t, map[string]int{
+		"/a":            4,
+		"/a/⋯":          1,
+		"/a/⋯/sub":      3,
+		"/a/⋯/sub/f0":   0,
+		"/a/⋯/sub/f1":   0,
+		"/a/⋯/sub/f2":   1,
+		"/a/⋯/sub/f2/x": 0,
+	}, analyzer.GetStoredPathsWithCounts("opens"))
+}
@@ -0,0 +1,86 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addPathsInput mixes configured prefixes (including a per-extension
+// threshold and numeric collapse), grouped and interleaved directories,
+// unclean paths and explicit ⋯ and * segments.
+func addPathsInput() []string {
+	var paths []string
+	for i := 0; i < 12; i++ {
+		paths = append(paths,
+			fmt.Sprintf("/opt/plugin%d/lib.so", i),
+			fmt.Sprintf("/opt/plugin%d/conf.yaml", i),
+			fmt.Sprintf("/var/run/%d/pid", i),
+			fmt.Sprintf("/usr/lib/x86_64-linux-gnu/lib%d.so", i),
+		)
+	}
+	for i := 0; i < 8; i++ {
+		paths = append(paths, fmt.Sprintf("/proc/%d/status", 1000+i), fmt.Sprintf("/app/run/%d", i))
+	}
+	return append(paths,
+		"/etc/../etc//hosts", "/etc/hosts/", "/data/⋯/x", "/data/a/*", "/", "relative/path",
+		"/opt/plugin0/lib.so", "/usr/lib/x86_64-linux-gnu/lib0.so",
+	)
+}
+
+func TestAddPathsMatchesAnalyzePath(t *testing.T) {
+	configs := append(testCollapseConfigs,
+		dynamicpathdetector.CollapseConfig{Prefix: "/usr/lib", Threshold: 4, ExtensionThresholds: map[string]int{".so": 2}},
+		dynamicpathdetector.CollapseConfig{Prefix: "/proc", Threshold: 50, CollapseNumericSegments: true},
+	)
+	paths := addPathsInput()
+
+	looped := dynamicpathdetector.NewPathAnalyzerWithConfigs(3, configs)
+	for _, p := range paths {
+		_, err := looped.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+	batched := dynamicpathdetector.NewPathAnalyzerWithConfigs(3, configs)
+	batched.AddPaths(paths[:10], "opens")
+	batched.AddPaths(paths[10:], "opens")
+
+	assert.Equal(t, flattenTrie(looped.RootNodes["opens"]), flattenTrie(batched.RootNodes["opens"]))
+	assert.Equal(t, looped.GetStoredPaths("opens"), batched.GetStoredPaths("opens"))
+	for _, p := range []string{"/opt/new/lib.so", "/usr/lib/x86_64-linux-gnu/libnew.so", "/proc/99/status"} {
+		want, err := looped.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		got, err := batched.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+	}
+}
+
+func TestAddPathsEmpty(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(3)
+	analyzer.AddPaths(nil, "opens")
+	assert.Nil(t, analyzer.GetStoredPaths("opens"), "no trie is created for an empty batch")
+}
+
+// flattenTrie maps every node below root to its Count, Terminal and Hits,
+// keyed by the node path. Unlike comparing the nodes directly it does not
+// tell a nil Children map from an empty one, which depends on whether the
+// node ever had a child.
+func flattenTrie(root *dynamicpathdetector.SegmentNode) map[string][3]int {
+	flat := make(map[string][3]int)
+	var walk func(node *dynamicpathdetector.SegmentNode, p string)
+	walk = func(node *dynamicpathdetector.SegmentNode, p string) {
+		terminal := 0
+		if node.Terminal {
+			terminal = 1
+		}
+		flat[p] = [3]int{node.Count, terminal, node.Hits}
+		for name, child := range node.Children {
+			walk(child, p+"/"+name)
+		}
+	}
+	walk(root, "")
+	return flat
+}
@@ -3,12 +3,16 @@ package dynamicpathdetectortests
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"slices"
 	"testing"
 
 	"github.com/kinbiko/jsonassert"
 	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/apis/softwarecomposition/consts"
 	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAnalyzeEndpoints(t *testing.T) {
@@ -128,10 +132,6 @@ func TestAnalyzeEndpoints(t *testing.T) {
 				},
 			},
 			expected: []types.HTTPEndpoint{
-				{
-					Endpoint: ":81/users/123",
-					Methods:  []string{"GET"},
-				},
 				{
 					Endpoint: ":123/users/456",
 					Methods:  []string{"POST"},
@@ -140,6 +140,10 @@ func TestAnalyzeEndpoints(t *testing.T) {
 					Endpoint: ":123/x/x",
 					Methods:  []string{"GET", "POST"},
 				},
+				{
+					Endpoint: ":81/users/123",
+					Methods:  []string{"GET"},
+				},
 			},
 		},
 		{
@@ -237,6 +241,67 @@ func TestAnalyzeEndpointsWithExactThreshold(t *testing.T) {
 	assert.Equal(t, expected, result)
 }
 
+// TestAnalyzeEndpointsReanalysisKeepsCounts re-runs the same threshold
+// endpoints through one analyzer: walking paths the trie already holds
+// must not count them again and trip the threshold.
+func TestAnalyzeEndpointsReanalysisKeepsCounts(t *testing.T) {
+	threshold := dynamicpathdetector.EndpointDynamicThreshold
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+
+	var input []types.HTTPEndpoint
+	for i := 0; i < threshold; i++ {
+		input = append(input, types.HTTPEndpoint{
+			Endpoint: fmt.Sprintf(":80/users/%d", i),
+			Methods:  []string{"GET"},
+		})
+	}
+	for pass := 0; pass < 3; pass++ {
+		result := dynamicpathdetector.AnalyzeEndpoints(&input, analyzer)
+		assert.Len(t, result, threshold, "pass %d", pass)
+		for _, p := range []string{":80/users/0", ":80/users/1"} {
+			got, err := dynamicpathdetector.AnalyzeURL(p, analyzer)
+			require.NoError(t, err)
+			assert.Equal(t, p, got, "pass %d", pass)
+		}
+	}
+	assert.Equal(t, threshold, analyzer.GetStoredPathsWithCounts("80")["/users"])
+}
+
+// TestAnalyzeEndpoints_HostQualifiedForms checks that endpoints carrying a
+// bracketed IPv6 or named host collapse exactly like the canonical
+// `:port/path` form, and that the host is dropped from the result.
+func TestAnalyzeEndpoints_HostQualifiedForms(t *testing.T) {
+	threshold := dynamicpathdetector.EndpointDynamicThreshold
+	for _, format := range []string{":80/api/users/%d", "[2001:db8::1]:80/api/users/%d", "host.internal:80/api/users/%d"} {
+		t.Run(format, func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+			var input []types.HTTPEndpoint
+			for i := 0; i < threshold+1; i++ {
+				input = append(input, types.HTTPEndpoint{
+					Endpoint: fmt.Sprintf(format, i),
+					Methods:  []string{"GET"},
+				})
+			}
+			result := dynamicpathdetector.AnalyzeEndpoints(&input, analyzer)
+			assert.Equal(t, []types.HTTPEndpoint{
+				{Endpoint: ":80/api/users/\u22ef", Methods: []string{"GET"}},
+			}, result)
+		})
+	}
+}
+
+func TestMergeDuplicateEndpoints_HostQualifiedFoldsIntoWildcard(t *testing.T) {
+	endpoints := []*types.HTTPEndpoint{
+		{Endpoint: ":0/api", Methods: []string{"GET"}},
+		{Endpoint: "[2001:db8::1]:80/api", Methods: []string{"POST"}},
+		{Endpoint: "host.internal:443/api", Methods: []string{"PUT"}},
+	}
+	result := dynamicpathdetector.MergeDuplicateEndpoints(endpoints)
+	require.Len(t, result, 1)
+	assert.Equal(t, ":0/api", result[0].Endpoint)
+	assert.ElementsMatch(t, []string{"GET", "POST", "PUT"}, result[0].Methods)
+}
+
 func TestAnalyzeEndpointsWithInvalidURL(t *testing.T) {
 	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil)
 
@@ -251,6 +316,32 @@ func TestAnalyzeEndpointsWithInvalidURL(t *testing.T) {
 	assert.Equal(t, 0, len(result))
 }
 
+func TestAnalyzeURLLenientForms(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		want     string
+	}{
+		{"fragment", ":80/path#section", ":80/path"},
+		{"fragment after query", ":80/path?x=1#frag", ":80/path"},
+		{"userinfo", "user:pass@host:80/path", ":80/path"},
+		{"scheme-relative", "//host:80/path", ":80/path"},
+		{"scheme-relative with userinfo", "//user@host:443/a#b", ":443/a"},
+		{"no path", "host:80", ":80/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.EndpointDynamicThreshold)
+			got, err := dynamicpathdetector.AnalyzeURL(tt.endpoint, analyzer)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := dynamicpathdetector.AnalyzeURL(":80/p%zz", dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.EndpointDynamicThreshold))
+	assert.Error(t, err, "a bad escape in the path is still invalid")
+}
+
 // TestAnalyzeEndpoints_WildcardDoesNotContaminateUnrelatedPaths pins the bug
 // flagged by upstream review on kubescape/storage#316: a single wildcard-port
 // endpoint must NOT cause unrelated specific-port endpoints (different path)
@@ -437,6 +528,38 @@ func TestMergeDuplicateEndpoints_SpecificFirstThenWildcard(t *testing.T) {
 	assert.ElementsMatch(t, []string{"GET", "POST"}, result[0].Methods)
 }
 
+// TestMergeDuplicateEndpoints_HeadersByteStable merges the same two
+// endpoints in both orders, many times over, and expects identical
+// Headers bytes every time: keys sorted, values sorted and deduplicated.
+func TestMergeDuplicateEndpoints_HeadersByteStable(t *testing.T) {
+	newEndpoints := func() (*types.HTTPEndpoint, *types.HTTPEndpoint) {
+		return &types.HTTPEndpoint{
+			Endpoint:  ":80/api/data",
+			Methods:   []string{"GET"},
+			Direction: "outbound",
+			Headers:   json.RawMessage(`{"X-Trace":["b","a"],"Accept":["text/html","application/json"],"Host":["svc"]}`),
+		}, &types.HTTPEndpoint{
+			Endpoint:  ":80/api/data",
+			Methods:   []string{"POST"},
+			Direction: "outbound",
+			Headers:   json.RawMessage(`{"User-Agent":["curl","go"],"Accept":["text/html","*/*"],"X-Trace":["c"]}`),
+		}
+	}
+	const want = `{"Accept":["*/*","application/json","text/html"],"Host":["svc"],"User-Agent":["curl","go"],"X-Trace":["a","b","c"]}`
+
+	for i := 0; i < 20; i++ {
+		a, b := newEndpoints()
+		result := dynamicpathdetector.MergeDuplicateEndpoints([]*types.HTTPEndpoint{a, b})
+		require.Len(t, result, 1)
+		assert.Equal(t, want, string(result[0].Headers))
+
+		a, b = newEndpoints()
+		result = dynamicpathdetector.MergeDuplicateEndpoints([]*types.HTTPEndpoint{b, a})
+		require.Len(t, result, 1)
+		assert.Equal(t, want, string(result[0].Headers), "merge order must not change the bytes")
+	}
+}
+
 // TestMergeDuplicateEndpoints_NoWildcardKeepsAllSpecificPorts asserts that
 // without a wildcard sibling, distinct (port,path) pairs all survive.
 // A regression here would mean the merge is collapsing too aggressively.
@@ -565,3 +688,496 @@ func TestMergeDuplicateEndpoints_InternalFieldMatching_StillFolds(t *testing.T)
 	assert.True(t, result[0].Internal, "merged endpoint must preserve Internal=true")
 	assert.ElementsMatch(t, []string{"GET", "POST"}, result[0].Methods)
 }
+
+// TestMergeDuplicateEndpoints_DirectionGuardsWildcardFolding pins that the
+// wildcard-port sibling lookup is keyed on Direction in both input orders:
+// an inbound :0 entry must not absorb an outbound specific-port entry for
+// the same path, and neither side's Methods may leak into the other.
+func TestMergeDuplicateEndpoints_DirectionGuardsWildcardFolding(t *testing.T) {
+	newPair := func() (*types.HTTPEndpoint, *types.HTTPEndpoint) {
+		return &types.HTTPEndpoint{
+			Endpoint:  ":0/api",
+			Methods:   []string{"GET"},
+			Direction: "inbound",
+		}, &types.HTTPEndpoint{
+			Endpoint:  ":80/api",
+			Methods:   []string{"POST"},
+			Direction: "outbound",
+		}
+	}
+
+	wildcard, specific := newPair()
+	result := dynamicpathdetector.MergeDuplicateEndpoints([]*types.HTTPEndpoint{wildcard, specific})
+	require.Len(t, result, 2, "wildcard-first: different directions must not merge")
+	assert.Equal(t, []string{"GET"}, wildcard.Methods)
+	assert.Equal(t, []string{"POST"}, specific.Methods)
+
+	wildcard, specific = newPair()
+	result = dynamicpathdetector.MergeDuplicateEndpoints([]*types.HTTPEndpoint{specific, wildcard})
+	require.Len(t, result, 2, "specific-first: different directions must not merge")
+	assert.Equal(t, []string{"GET"}, wildcard.Methods)
+	assert.Equal(t, []string{"POST"}, specific.Methods)
+}
+
+func TestAnalyzeEndpointsWithQueryThreshold(t *testing.T) {
+	const threshold = 3
+	var input []types.HTTPEndpoint
+	for i := 0; i < threshold+1; i++ {
+		input = append(input, types.HTTPEndpoint{
+			Endpoint: fmt.Sprintf(":80/api/data?token=t%d&page=1", i),
+			Methods:  []string{"GET"},
+		})
+	}
+	input[0].Methods = []string{"POST"}
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil)
+	result := dynamicpathdetector.AnalyzeEndpointsWithQueryThreshold(&input, analyzer, threshold)
+	require.Len(t, result, 1)
+	assert.Equal(t, ":80/api/data?page=1&token=⋯", result[0].Endpoint)
+	assert.ElementsMatch(t, []string{"GET", "POST"}, result[0].Methods)
+}
+
+func TestAnalyzeEndpointsWithQueryThreshold_BelowThresholdKeepsValues(t *testing.T) {
+	input := []types.HTTPEndpoint{
+		{Endpoint: ":80/search?q=a&q=a&lang=en", Methods: []string{"GET"}},
+		{Endpoint: ":80/search?q=b", Methods: []string{"GET"}},
+		{Endpoint: ":80/health", Methods: []string{"GET"}},
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil)
+	result := dynamicpathdetector.AnalyzeEndpointsWithQueryThreshold(&input, analyzer, 3)
+
+	var got []string
+	for _, ep := range result {
+		got = append(got, ep.Endpoint)
+	}
+	assert.ElementsMatch(t, []string{":80/search?lang=en&q=a", ":80/search?q=b", ":80/health"}, got)
+}
+
+func TestAnalyzeEndpoints_DropsQueryByDefault(t *testing.T) {
+	var input []types.HTTPEndpoint
+	for i := 0; i < 5; i++ {
+		input = append(input, types.HTTPEndpoint{
+			Endpoint: fmt.Sprintf(":80/api/data?token=t%d", i),
+			Methods:  []string{"GET"},
+		})
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil)
+	result := dynamicpathdetector.AnalyzeEndpoints(&input, analyzer)
+	require.Len(t, result, 1)
+	assert.Equal(t, ":80/api/data", result[0].Endpoint)
+}
+
+// TestAnalyzeEndpoints_OrderIndependent feeds the same endpoint set in many
+// orders and requires byte-identical output, including merged Methods and
+// Headers.
+func TestAnalyzeEndpoints_OrderIndependent(t *testing.T) {
+	var input []types.HTTPEndpoint
+	for i := 0; i < dynamicpathdetector.EndpointDynamicThreshold+2; i++ {
+		input = append(input, types.HTTPEndpoint{
+			Endpoint:  fmt.Sprintf(":80/users/%d/profile", i),
+			Methods:   []string{[]string{"GET", "POST", "PUT"}[i%3]},
+			Direction: "inbound",
+			Headers:   json.RawMessage(fmt.Sprintf(`{"X-Request":["r%d"]}`, i%4)),
+		})
+	}
+	input = append(input,
+		types.HTTPEndpoint{Endpoint: ":0/health", Methods: []string{"HEAD"}, Direction: "inbound"},
+		types.HTTPEndpoint{Endpoint: ":8080/health", Methods: []string{"GET"}, Direction: "inbound"},
+		types.HTTPEndpoint{Endpoint: ":8080/health", Methods: []string{"GET"}, Direction: "outbound"},
+		types.HTTPEndpoint{Endpoint: ":443/login", Methods: []string{"POST"}, Direction: "outbound", Internal: true},
+		types.HTTPEndpoint{Endpoint: ":443/login", Methods: []string{"DELETE"}, Direction: "outbound"},
+	)
+
+	analyze := func(in []types.HTTPEndpoint) string {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil)
+		data, err := json.Marshal(dynamicpathdetector.AnalyzeEndpoints(&in, analyzer))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	want := analyze(slices.Clone(input))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		shuffled := slices.Clone(input)
+		rng.Shuffle(len(shuffled), func(a, b int) { shuffled[a], shuffled[b] = shuffled[b], shuffled[a] })
+		assert.Equal(t, want, analyze(shuffled), "permutation %d", i)
+	}
+}
+
+func TestCountUniqueEndpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints []types.HTTPEndpoint
+		want      int
+	}{
+		{name: "nil"},
+		{
+			name: "duplicates",
+			endpoints: []types.HTTPEndpoint{
+				{Endpoint: ":80/users/123", Methods: []string{"GET"}, Direction: "inbound"},
+				{Endpoint: ":80/users/123", Methods: []string{"POST"}, Direction: "inbound"},
+				{Endpoint: ":80/users/123/", Direction: "inbound"},
+				{Endpoint: ":80/users/123?page=2", Direction: "inbound"},
+			},
+			want: 1,
+		},
+		{
+			name: "port, direction and internal distinguish",
+			endpoints: []types.HTTPEndpoint{
+				{Endpoint: ":80/users/123", Direction: "inbound"},
+				{Endpoint: ":443/users/123", Direction: "inbound"},
+				{Endpoint: ":80/users/123", Direction: "outbound"},
+				{Endpoint: ":80/users/123", Direction: "inbound", Internal: true},
+				{Endpoint: ":80/users/456", Direction: "inbound"},
+			},
+			want: 5,
+		},
+		{
+			name: "invalid endpoints are skipped",
+			endpoints: []types.HTTPEndpoint{
+				{Endpoint: ":::invalid-u323@!#rl:::"},
+				{Endpoint: ":80/health"},
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, dynamicpathdetector.CountUniqueEndpoints(tt.endpoints))
+		})
+	}
+}
+
+func TestAnalyzeEndpointsWithExamples(t *testing.T) {
+	var input []types.HTTPEndpoint
+	for i := 0; i < dynamicpathdetector.EndpointDynamicThreshold+2; i++ {
+		input = append(input,
+			types.HTTPEndpoint{Endpoint: fmt.Sprintf(":80/users/%d", i+10), Methods: []string{"GET"}, Direction: "inbound"},
+			types.HTTPEndpoint{Endpoint: fmt.Sprintf(":8080/items/%d", i+10), Methods: []string{"GET"}, Direction: "inbound"},
+		)
+	}
+	input = append(input,
+		types.HTTPEndpoint{Endpoint: ":0/items/⋯", Methods: []string{"HEAD"}, Direction: "inbound"},
+		types.HTTPEndpoint{Endpoint: ":80/health", Methods: []string{"GET"}, Direction: "inbound"},
+	)
+
+	analyze := func(in []types.HTTPEndpoint) ([]types.HTTPEndpoint, map[string]string) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil)
+		return dynamicpathdetector.AnalyzeEndpointsWithExamples(&in, analyzer)
+	}
+
+	result, examples := analyze(slices.Clone(input))
+	assert.Equal(t, []string{":0/items/⋯", ":80/health", ":80/users/⋯"}, endpointStrings(result))
+	assert.Equal(t, map[string]string{
+		":80/users/⋯": ":80/users/10",
+		":0/items/⋯":  ":8080/items/10",
+	}, examples, "the smallest folded endpoint, including ports folded into :0")
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		shuffled := slices.Clone(input)
+		rng.Shuffle(len(shuffled), func(a, b int) { shuffled[a], shuffled[b] = shuffled[b], shuffled[a] })
+		_, got := analyze(shuffled)
+		assert.Equal(t, examples, got, "permutation %d", i)
+	}
+}
+
+func endpointStrings(endpoints []types.HTTPEndpoint) []string {
+	s := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		s[i] = e.Endpoint
+	}
+	slices.Sort(s)
+	return s
+}
+
+func TestAnalyzeEndpointsNormalizesMethods(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.EndpointDynamicThreshold)
+	input := []types.HTTPEndpoint{
+		{Endpoint: ":80/users", Methods: []string{"get", "Post"}},
+		{Endpoint: ":80/users", Methods: []string{"GET", "Get"}},
+		{Endpoint: ":80/health", Methods: []string{"get", "PURGE"}},
+	}
+
+	result := dynamicpathdetector.AnalyzeEndpoints(&input, analyzer)
+	require.Len(t, result, 2)
+	assert.Equal(t, ":80/health", result[0].Endpoint)
+	assert.Equal(t, []string{"GET", "PURGE"}, result[0].Methods)
+	assert.Equal(t, ":80/users", result[1].Endpoint)
+	assert.Equal(t, []string{"GET", "POST"}, result[1].Methods)
+	assert.Equal(t, []string{"get", "Post"}, input[0].Methods, "input is not modified")
+}
+
+func TestAnalyzeEndpointsWithStandardMethods(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.EndpointDynamicThreshold)
+	input := []types.HTTPEndpoint{
+		{Endpoint: ":80/cache", Methods: []string{"get", "PURGE", "patch"}},
+		{Endpoint: ":80/dav", Methods: []string{"PROPFIND"}},
+	}
+
+	result := dynamicpathdetector.AnalyzeEndpointsWithStandardMethods(&input, analyzer)
+	require.Len(t, result, 2)
+	assert.Equal(t, []string{"GET", "PATCH"}, result[0].Methods)
+	assert.Empty(t, result[1].Methods, "the endpoint is kept without its non-standard methods")
+}
+
+func TestAnalyzeEndpointsWithHeaderThreshold(t *testing.T) {
+	const headerThreshold = 3
+	contentTypes := []string{"application/json", "application/xml"}
+	var input []types.HTTPEndpoint
+	for i := 0; i < 10; i++ {
+		input = append(input, types.HTTPEndpoint{
+			Endpoint: ":80/api/items",
+			Methods:  []string{"GET"},
+			Headers:  json.RawMessage(fmt.Sprintf(`{"Content-Type":[%q],"X-Request-Id":["req-%d"]}`, contentTypes[i%2], i)),
+		})
+	}
+
+	result := dynamicpathdetector.AnalyzeEndpointsWithHeaderThreshold(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil), headerThreshold)
+	require.Len(t, result, 1)
+	headers, err := result[0].GetHeaders()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"Content-Type": contentTypes,
+		"X-Request-Id": {"⋯"},
+	}, headers)
+
+	result = dynamicpathdetector.AnalyzeEndpoints(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil))
+	require.Len(t, result, 1)
+	headers, err = result[0].GetHeaders()
+	require.NoError(t, err)
+	assert.Len(t, headers["X-Request-Id"], 10, "AnalyzeEndpoints keeps every value")
+
+	// A key collapsed by an earlier save stays collapsed.
+	input = []types.HTTPEndpoint{
+		{Endpoint: ":80/api/items", Methods: []string{"GET"}, Headers: json.RawMessage(`{"X-Request-Id":["⋯"]}`)},
+		{Endpoint: ":80/api/items", Methods: []string{"GET"}, Headers: json.RawMessage(`{"X-Request-Id":["req-new"]}`)},
+	}
+	result = dynamicpathdetector.AnalyzeEndpointsWithHeaderThreshold(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil), headerThreshold)
+	require.Len(t, result, 1)
+	assert.JSONEq(t, `{"X-Request-Id":["⋯"]}`, string(result[0].Headers))
+}
+
+func TestAnalyzeEndpointsWithHosts(t *testing.T) {
+	const hostThreshold = 3
+	var input []types.HTTPEndpoint
+	for i := 1; i <= hostThreshold+1; i++ {
+		input = append(input, types.HTTPEndpoint{
+			Endpoint:  fmt.Sprintf("10.0.0.%d:443/api/%d", i, i),
+			Methods:   []string{"GET"},
+			Direction: consts.Outbound,
+		})
+	}
+	input = append(input,
+		// Another block, and the same block on another port, stay literal.
+		types.HTTPEndpoint{Endpoint: "10.0.1.7:443/health", Methods: []string{"GET"}, Direction: consts.Outbound},
+		types.HTTPEndpoint{Endpoint: "10.0.0.1:8080/health", Methods: []string{"GET"}, Direction: consts.Outbound},
+		// Names and inbound endpoints lose the host as in AnalyzeEndpoints.
+		types.HTTPEndpoint{Endpoint: "example.com:8443/health", Methods: []string{"GET"}, Direction: consts.Outbound},
+		types.HTTPEndpoint{Endpoint: "10.0.0.9:80/health", Methods: []string{"GET"}, Direction: consts.Inbound},
+	)
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(hostThreshold, nil)
+	result := dynamicpathdetector.AnalyzeEndpointsWithHosts(&input, analyzer, hostThreshold)
+	var got []string
+	for _, endpoint := range result {
+		got = append(got, endpoint.Endpoint)
+	}
+	assert.ElementsMatch(t, []string{
+		"10.0.0.0/24:443/api/⋯",
+		"10.0.1.7:443/health",
+		"10.0.0.1:8080/health",
+		":8443/health",
+		":80/health",
+	}, got)
+
+	// At the threshold the addresses stay apart.
+	input = input[1 : hostThreshold+1]
+	result = dynamicpathdetector.AnalyzeEndpointsWithHosts(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(hostThreshold, nil), hostThreshold)
+	got = got[:0]
+	for _, endpoint := range result {
+		got = append(got, endpoint.Endpoint)
+	}
+	assert.ElementsMatch(t, []string{"10.0.0.2:443/api/2", "10.0.0.3:443/api/3", "10.0.0.4:443/api/4"}, got)
+
+	result = dynamicpathdetector.AnalyzeEndpoints(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(hostThreshold, nil))
+	require.Len(t, result, 3)
+	assert.Equal(t, ":443/api/2", result[0].Endpoint, "AnalyzeEndpoints drops the host")
+}
+
+func TestAnalyzeEndpointsWithHostsMergesIntoWildcardPort(t *testing.T) {
+	input := []types.HTTPEndpoint{
+		{Endpoint: ":0/health", Methods: []string{"GET"}, Direction: consts.Outbound},
+	}
+	for i := 1; i <= 3; i++ {
+		input = append(input, types.HTTPEndpoint{
+			Endpoint:  fmt.Sprintf("10.0.0.%d:443/health", i),
+			Methods:   []string{"POST"},
+			Direction: consts.Outbound,
+		})
+	}
+	result := dynamicpathdetector.AnalyzeEndpointsWithHosts(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.EndpointDynamicThreshold, nil), 2)
+	require.Len(t, result, 1, "a block host splits into its port and path like any host")
+	assert.Equal(t, ":0/health", result[0].Endpoint)
+	assert.Equal(t, []string{"GET", "POST"}, result[0].Methods)
+}
+
+// TestAnalyzeEndpointsWithoutPort feeds endpoints recorded as bare paths.
+// They keep an empty port, distinct from the :0 wildcard, and their first
+// segment stays part of the path whether or not it has a leading slash.
+func TestAnalyzeEndpointsWithoutPort(t *testing.T) {
+	const threshold = 3
+	var input []types.HTTPEndpoint
+	for i := 0; i <= threshold; i++ {
+		input = append(input, types.HTTPEndpoint{Endpoint: fmt.Sprintf("/api/v1/users/%d", i), Methods: []string{"GET"}})
+	}
+	input = append(input,
+		types.HTTPEndpoint{Endpoint: "api/v1/health", Methods: []string{"GET"}},
+		types.HTTPEndpoint{Endpoint: "/", Methods: []string{"HEAD"}},
+		types.HTTPEndpoint{Endpoint: ":80/api/v1/health", Methods: []string{"POST"}},
+	)
+
+	result := dynamicpathdetector.AnalyzeEndpoints(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil))
+	got := make(map[string][]string)
+	for _, endpoint := range result {
+		got[endpoint.Endpoint] = endpoint.Methods
+	}
+	assert.Equal(t, map[string][]string{
+		":/":                {"HEAD"},
+		":/api/v1/health":   {"GET"},
+		":/api/v1/users/⋯":  {"GET"},
+		":80/api/v1/health": {"POST"},
+	}, got)
+
+	for _, endpoint := range []string{"/api/v1/users", "api/v1/users"} {
+		analyzed, err := dynamicpathdetector.AnalyzeURL(endpoint, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil))
+		require.NoError(t, err)
+		assert.Equal(t, ":/api/v1/users", analyzed, endpoint)
+	}
+	assert.Equal(t, 1, dynamicpathdetector.CountUniqueEndpoints([]types.HTTPEndpoint{
+		{Endpoint: "/api/v1/users"}, {Endpoint: "api/v1/users"},
+	}))
+}
+
+func TestAnalyzeEndpointsWithMergedSchemes(t *testing.T) {
+	const threshold = 3
+	input := []types.HTTPEndpoint{
+		{Endpoint: ":80/api", Methods: []string{"GET"}, Direction: consts.Inbound},
+		{Endpoint: ":443/api", Methods: []string{"POST"}, Direction: consts.Inbound},
+		{Endpoint: ":8080/api", Methods: []string{"PUT"}, Direction: consts.Inbound},
+	}
+	// Users split over both schemes only collapse together.
+	for i := 0; i <= threshold; i++ {
+		port := []string{"80", "443"}[i%2]
+		input = append(input, types.HTTPEndpoint{Endpoint: fmt.Sprintf(":%s/users/%d", port, i), Methods: []string{"GET"}, Direction: consts.Inbound})
+	}
+
+	result := dynamicpathdetector.AnalyzeEndpoints(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil))
+	assert.Len(t, result, 3+threshold+1, "AnalyzeEndpoints keeps the schemes apart")
+
+	result = dynamicpathdetector.AnalyzeEndpointsWithMergedSchemes(&input, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil))
+	got := make(map[string][]string)
+	for _, endpoint := range result {
+		got[endpoint.Endpoint] = endpoint.Methods
+	}
+	assert.Equal(t, map[string][]string{
+		":http/api":     {"GET", "POST"},
+		":http/users/⋯": {"GET"},
+		":8080/api":     {"PUT"},
+	}, got)
+
+	// Merged endpoints analyze to themselves again, with or without the
+	// option, so a stored profile is stable.
+	again := dynamicpathdetector.AnalyzeEndpoints(&result, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil))
+	assert.ElementsMatch(t, result, again)
+	analyzed, err := dynamicpathdetector.AnalyzeURL(":http/api?x=1", dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil))
+	require.NoError(t, err)
+	assert.Equal(t, ":http/api", analyzed)
+}
+
+func TestAnalyzeEndpointsBatch(t *testing.T) {
+	const threshold = 3
+	users := func(ids ...int) []types.HTTPEndpoint {
+		var endpoints []types.HTTPEndpoint
+		for _, id := range ids {
+			endpoints = append(endpoints, types.HTTPEndpoint{Endpoint: fmt.Sprintf(":80/users/%d", id), Methods: []string{"GET"}, Direction: consts.Outbound})
+		}
+		return endpoints
+	}
+	// Neither container alone has more than threshold users.
+	web := append(users(1, 2), types.HTTPEndpoint{Endpoint: ":80/health", Methods: []string{"GET"}, Direction: consts.Outbound})
+	worker := users(3, 4)
+
+	separate := dynamicpathdetector.AnalyzeEndpoints(&web, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil))
+	assert.Len(t, separate, 3, "analyzed alone, the web container keeps its users")
+
+	result := dynamicpathdetector.AnalyzeEndpointsBatch(map[string]*[]types.HTTPEndpoint{
+		"web":    &web,
+		"worker": &worker,
+		"empty":  {},
+		"none":   nil,
+	}, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil))
+
+	endpointsOf := func(endpoints []types.HTTPEndpoint) []string {
+		var out []string
+		for _, endpoint := range endpoints {
+			out = append(out, endpoint.Endpoint)
+		}
+		return out
+	}
+	require.Len(t, result, 3)
+	assert.ElementsMatch(t, []string{":80/health", ":80/users/⋯"}, endpointsOf(result["web"]))
+	assert.Equal(t, []string{":80/users/⋯"}, endpointsOf(result["worker"]))
+	assert.Nil(t, result["empty"])
+	assert.NotContains(t, result, "none")
+	assert.Equal(t, users(3, 4), worker, "input must not be modified")
+
+	assert.Nil(t, dynamicpathdetector.AnalyzeEndpointsBatch(nil, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)))
+}
+
+func TestAnalyzeEndpointsWithTemplates(t *testing.T) {
+	const threshold = 3
+	templates := []string{"/users/{id}", "/users/me", "/users/{id}/orders/{orderId}", "/files/{name}.json"}
+	input := []types.HTTPEndpoint{
+		{Endpoint: ":80/users/123", Methods: []string{"GET"}, Direction: consts.Inbound},
+		{Endpoint: ":80/users/me", Methods: []string{"GET"}, Direction: consts.Inbound},
+		{Endpoint: ":80/users/42/orders/7", Methods: []string{"POST"}, Direction: consts.Inbound},
+		{Endpoint: ":80/files/a.json", Methods: []string{"GET"}, Direction: consts.Inbound},
+		{Endpoint: ":80/files/a.txt", Methods: []string{"GET"}, Direction: consts.Inbound},
+		{Endpoint: ":80/files/.json", Methods: []string{"GET"}, Direction: consts.Inbound},
+		{Endpoint: ":8080/users/456?x=1", Methods: []string{"DELETE"}, Direction: consts.Inbound},
+		{Endpoint: ":80/health", Methods: []string{"GET"}, Direction: consts.Inbound},
+	}
+	// Paths no template covers still collapse by threshold.
+	for i := 0; i <= threshold; i++ {
+		input = append(input, types.HTTPEndpoint{Endpoint: fmt.Sprintf(":80/items/%d", i), Methods: []string{"GET"}, Direction: consts.Inbound})
+	}
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	result := dynamicpathdetector.AnalyzeEndpointsWithTemplates(&input, analyzer, templates)
+	got := make(map[string][]string)
+	for _, endpoint := range result {
+		got[endpoint.Endpoint] = endpoint.Methods
+	}
+	assert.Equal(t, map[string][]string{
+		":80/users/⋯":          {"GET"},
+		":80/users/me":         {"GET"},
+		":80/users/⋯/orders/⋯": {"POST"},
+		":80/files/⋯":          {"GET"},
+		":80/files/a.txt":      {"GET"},
+		":80/files/.json":      {"GET"},
+		":8080/users/⋯":        {"DELETE"},
+		":80/health":           {"GET"},
+		":80/items/⋯":          {"GET"},
+	}, got)
+	assert.NotContains(t, analyzer.GetStoredPaths("80"), "/users/me", "matched endpoints are not fed to the analyzer")
+
+	// A single request is enough, and without templates it stays literal.
+	one := []types.HTTPEndpoint{{Endpoint: ":80/users/123", Methods: []string{"GET"}}}
+	assert.Equal(t, []string{":80/users/⋯"}, endpointStrings(dynamicpathdetector.AnalyzeEndpointsWithTemplates(&one, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), templates)))
+	assert.Equal(t, []string{":80/users/123"}, endpointStrings(dynamicpathdetector.AnalyzeEndpointsWithTemplates(&one, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), nil)))
+
+	// Stored endpoints analyze to themselves again.
+	again := dynamicpathdetector.AnalyzeEndpointsWithTemplates(&result, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), templates)
+	assert.ElementsMatch(t, result, again)
+}
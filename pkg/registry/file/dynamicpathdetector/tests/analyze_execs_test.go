@@ -0,0 +1,367 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeExecs_NilInput(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+	result, err := dynamicpathdetector.AnalyzeExecs(nil, analyzer)
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestAnalyzeExecs_PathCollapse(t *testing.T) {
+	threshold := 5
+	tests := []struct {
+		name     string
+		input    []types.ExecCalls
+		expected []types.ExecCalls
+	}{
+		{
+			name: "below threshold keeps literal paths",
+			input: []types.ExecCalls{
+				{Path: "/usr/bin/ls", Args: []string{"-la"}},
+				{Path: "/usr/bin/cat", Args: []string{"/etc/hosts"}},
+			},
+			expected: []types.ExecCalls{
+				{Path: "/usr/bin/cat", Args: []string{"/etc/hosts"}},
+				{Path: "/usr/bin/ls", Args: []string{"-la"}},
+			},
+		},
+		{
+			name:  "transient directories collapse and dedup",
+			input: generateExecs("/tmp/%d/python", threshold+1, []string{"-c", "print(1)"}, []string{"HOME=/root"}),
+			expected: []types.ExecCalls{
+				{Path: "/tmp/⋯/python", Args: []string{"-c", "print(1)"}, Envs: []string{"HOME=/root"}},
+			},
+		},
+		{
+			name:  "collapsed entries with different args stay distinct",
+			input: append(generateExecs("/tmp/%d/sh", threshold+1, []string{"-c", "true"}, nil), types.ExecCalls{Path: "/tmp/other/sh", Args: []string{"-c", "false"}}),
+			expected: []types.ExecCalls{
+				{Path: "/tmp/⋯/sh", Args: []string{"-c", "false"}},
+				{Path: "/tmp/⋯/sh", Args: []string{"-c", "true"}},
+			},
+		},
+		{
+			name: "scripts in random temp dirs keep their basename",
+			input: append(generateExecs("/tmp/tmp.%d/script.sh", threshold+1, nil, nil),
+				types.ExecCalls{Path: "/tmp/tmp.0/run.sh"}),
+			expected: []types.ExecCalls{
+				{Path: "/tmp/⋯/run.sh"},
+				{Path: "/tmp/⋯/script.sh"},
+			},
+		},
+		{
+			name:  "scripts in threshold temp dirs stay literal",
+			input: generateExecs("/tmp/tmp.%d/script.sh", threshold, nil, nil),
+			expected: []types.ExecCalls{
+				{Path: "/tmp/tmp.0/script.sh"},
+				{Path: "/tmp/tmp.1/script.sh"},
+				{Path: "/tmp/tmp.2/script.sh"},
+				{Path: "/tmp/tmp.3/script.sh"},
+				{Path: "/tmp/tmp.4/script.sh"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzer(threshold)
+			result, err := dynamicpathdetector.AnalyzeExecs(tt.input, analyzer)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestAnalyzeExecs_IndependentOfOpens(t *testing.T) {
+	threshold := 3
+	analyzer := dynamicpathdetector.NewPathAnalyzer(threshold)
+	opens := generateOpenCallsWithFlags("/usr/bin", "data", threshold+1)
+	_, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+	require.NoError(t, err)
+
+	result, err := dynamicpathdetector.AnalyzeExecs([]types.ExecCalls{{Path: "/usr/bin/user0/data"}}, analyzer)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{{Path: "/usr/bin/user0/data"}}, result,
+		"exec paths must not inherit collapses learned from opens")
+}
+
+// generateExecs creates n ExecCalls whose Path is pathFormat formatted
+// with the index, all sharing the same Args and Envs.
+func generateExecs(pathFormat string, n int, args, envs []string) []types.ExecCalls {
+	result := make([]types.ExecCalls, 0, n)
+	for i := 0; i < n; i++ {
+		result = append(result, types.ExecCalls{
+			Path: fmt.Sprintf(pathFormat, i),
+			Args: args,
+			Envs: envs,
+		})
+	}
+	return result
+}
+
+func TestAnalyzeExecs_EnvsSortedAndDeduplicated(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+	input := []types.ExecCalls{
+		{Path: "/bin/sh", Envs: []string{"TERM=xterm", "HOME=/root", "TERM=xterm"}},
+		{Path: "/bin/sh", Envs: []string{"HOME=/root", "TERM=xterm"}},
+	}
+	result, err := dynamicpathdetector.AnalyzeExecs(input, analyzer)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{
+		{Path: "/bin/sh", Envs: []string{"HOME=/root", "TERM=xterm"}},
+	}, result)
+	assert.Equal(t, []string{"TERM=xterm", "HOME=/root", "TERM=xterm"}, input[0].Envs, "input must not be modified")
+}
+
+func TestAnalyzeExecsWithEnvThreshold(t *testing.T) {
+	threshold := 3
+	var input []types.ExecCalls
+	for i := 0; i < threshold+1; i++ {
+		input = append(input, types.ExecCalls{
+			Path: "/usr/bin/python3",
+			Args: []string{"app.py"},
+			Envs: []string{fmt.Sprintf("PATH=/opt/venv%d/bin", i), "LANG=C"},
+		})
+	}
+
+	t.Run("above threshold collapses to one entry", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+		result, err := dynamicpathdetector.AnalyzeExecsWithEnvThreshold(input, analyzer, threshold)
+		require.NoError(t, err)
+		assert.Equal(t, []types.ExecCalls{
+			{Path: "/usr/bin/python3", Args: []string{"app.py"}, Envs: []string{"LANG=C", "PATH=⋯"}},
+		}, result)
+	})
+
+	t.Run("at threshold keeps values", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+		result, err := dynamicpathdetector.AnalyzeExecsWithEnvThreshold(input[:threshold], analyzer, threshold)
+		require.NoError(t, err)
+		assert.Len(t, result, threshold)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+		result, err := dynamicpathdetector.AnalyzeExecs(input, analyzer)
+		require.NoError(t, err)
+		assert.Len(t, result, threshold+1)
+	})
+}
+
+func TestAnalyzeExecsVariableLengthArgs(t *testing.T) {
+	threshold := 3
+	var input []types.ExecCalls
+	for i := 0; i < threshold+1; i++ {
+		input = append(input, types.ExecCalls{Path: "/usr/bin/curl", Args: []string{fmt.Sprintf("https://example.com/%d", i)}})
+	}
+	input = append(input,
+		types.ExecCalls{Path: "/usr/bin/curl", Args: []string{"https://example.com/verbose", "--verbose"}},
+		types.ExecCalls{Path: "/usr/bin/curl", Args: []string{"https://example.com/quiet", "--silent", "-o", "/dev/null"}},
+	)
+
+	t.Run("AnalyzeExecs keeps every arg vector", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+		result, err := dynamicpathdetector.AnalyzeExecs(input, analyzer)
+		require.NoError(t, err)
+		assert.Len(t, result, len(input))
+	})
+
+	t.Run("arg collapse alone keeps lengths apart", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+		result, err := dynamicpathdetector.AnalyzeExecsWithTailCollapse(input, analyzer, threshold, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []types.ExecCalls{
+			{Path: "/usr/bin/curl", Args: []string{"⋯"}},
+			{Path: "/usr/bin/curl", Args: []string{"⋯", "--silent", "-o", "/dev/null"}},
+			{Path: "/usr/bin/curl", Args: []string{"⋯", "--verbose"}},
+		}, result)
+	})
+
+	t.Run("tail collapse merges optional trailing args", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+		result, err := dynamicpathdetector.AnalyzeExecsWithTailCollapse(input, analyzer, threshold, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []types.ExecCalls{
+			{Path: "/usr/bin/curl", Args: []string{"⋯", "*"}},
+		}, result)
+	})
+
+	t.Run("tail threshold not exceeded", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+		result, err := dynamicpathdetector.AnalyzeExecsWithTailCollapse(input, analyzer, threshold, 3)
+		require.NoError(t, err)
+		assert.Len(t, result, 3)
+	})
+}
+
+func TestAnalyzeExecsWithTailCollapse_OnlyExtendsSharedPrefix(t *testing.T) {
+	input := []types.ExecCalls{
+		{Path: "/bin/ls", Args: []string{"-l"}},
+		{Path: "/bin/ls", Args: []string{"-l", "/tmp"}},
+		{Path: "/bin/ls", Args: []string{"-a", "/tmp"}},
+		{Path: "/bin/cat", Args: []string{"-l", "/tmp"}},
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+	result, err := dynamicpathdetector.AnalyzeExecsWithTailCollapse(input, analyzer, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{
+		{Path: "/bin/cat", Args: []string{"-l", "/tmp"}},
+		{Path: "/bin/ls", Args: []string{"-a", "/tmp"}},
+		{Path: "/bin/ls", Args: []string{"-l", "*"}},
+	}, result)
+	assert.Equal(t, []string{"-l", "/tmp"}, input[1].Args, "input must not be modified")
+}
+
+func TestAnalyzeExecsOrdered(t *testing.T) {
+	threshold := 4
+	// A container start-up: entrypoint, a burst of transient helpers that
+	// collapse, then the main process. The helper burst repeats later.
+	input := []types.ExecCalls{
+		{Path: "/docker-entrypoint.sh"},
+		{Path: "/usr/bin/env", Args: []string{"python3"}},
+	}
+	input = append(input, generateExecs("/tmp/%d/setup", threshold+1, []string{"--init"}, nil)...)
+	input = append(input,
+		types.ExecCalls{Path: "/app/server", Args: []string{"--port", "8080"}},
+		types.ExecCalls{Path: "/usr/bin/env", Args: []string{"python3"}},
+		types.ExecCalls{Path: "/tmp/late/setup", Args: []string{"--init"}},
+	)
+
+	analyzer := dynamicpathdetector.NewPathAnalyzer(threshold)
+	result, err := dynamicpathdetector.AnalyzeExecsOrdered(input, analyzer, true)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{
+		{Path: "/docker-entrypoint.sh"},
+		{Path: "/usr/bin/env", Args: []string{"python3"}},
+		{Path: "/tmp/⋯/setup", Args: []string{"--init"}},
+		{Path: "/app/server", Args: []string{"--port", "8080"}},
+	}, result)
+
+	analyzer = dynamicpathdetector.NewPathAnalyzer(threshold)
+	sorted, err := dynamicpathdetector.AnalyzeExecsOrdered(input, analyzer, false)
+	require.NoError(t, err)
+	analyzer = dynamicpathdetector.NewPathAnalyzer(threshold)
+	want, err := dynamicpathdetector.AnalyzeExecs(input, analyzer)
+	require.NoError(t, err)
+	assert.Equal(t, want, sorted)
+	assert.ElementsMatch(t, result, sorted)
+}
+
+func TestAnalyzeExecsWithFlagArgs(t *testing.T) {
+	const threshold = 2
+	var execs []types.ExecCalls
+	for i := 0; i < threshold+2; i++ {
+		token := fmt.Sprintf("Authorization: Bearer %d", i)
+		if i%2 == 0 {
+			execs = append(execs, types.ExecCalls{Path: "/usr/bin/curl", Args: []string{"curl", "-H", token, "-X", "POST", "https://api.example.com/v1"}})
+		} else {
+			execs = append(execs, types.ExecCalls{Path: "/usr/bin/curl", Args: []string{"curl", "-X", "POST", "-H", token, "https://api.example.com/v1"}})
+		}
+	}
+	execs = append(execs,
+		types.ExecCalls{Path: "/usr/bin/tool", Args: []string{"-a", "X", "-b", "Y"}},
+		types.ExecCalls{Path: "/usr/bin/tool", Args: []string{"-b", "Y", "-a", "X"}},
+		types.ExecCalls{Path: "/usr/bin/tool", Args: []string{"--out=/tmp/a", "-v", "run", "-b", "Y", "-a", "X", "--", "-z"}},
+	)
+
+	result, err := dynamicpathdetector.AnalyzeExecsWithFlagArgs(execs, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.ExecDynamicThreshold, nil), threshold)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{
+		{Path: "/usr/bin/curl", Args: []string{"curl", "-H", "⋯", "-X", "POST", "https://api.example.com/v1"}},
+		// run follows a flag, so it is taken as the value of -v.
+		{Path: "/usr/bin/tool", Args: []string{"--out=/tmp/a", "-a", "X", "-b", "Y", "-v", "run", "--", "-z"}},
+		{Path: "/usr/bin/tool", Args: []string{"-a", "X", "-b", "Y"}},
+	}, result)
+}
+
+func TestAnalyzeExecsWithFlagArgsStableUnderShuffle(t *testing.T) {
+	orders := [][]string{
+		{"-a", "1", "-b", "Y", "-c"},
+		{"-b", "Y", "-c", "-a", "2"},
+		{"-c", "-a", "3", "-b", "Y"},
+		{"-b", "Y", "-a", "4", "-c"},
+	}
+	var execs []types.ExecCalls
+	for _, args := range orders {
+		execs = append(execs, types.ExecCalls{Path: "/usr/bin/tool", Args: args})
+	}
+	result, err := dynamicpathdetector.AnalyzeExecsWithFlagArgs(execs, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.ExecDynamicThreshold, nil), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{{Path: "/usr/bin/tool", Args: []string{"-a", "⋯", "-b", "Y", "-c"}}}, result)
+	assert.Equal(t, []string{"-a", "1", "-b", "Y", "-c"}, execs[0].Args, "input is not modified")
+
+	// Threshold 0 only sorts the flags.
+	result, err = dynamicpathdetector.AnalyzeExecsWithFlagArgs(execs[:2], dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.ExecDynamicThreshold, nil), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{
+		{Path: "/usr/bin/tool", Args: []string{"-a", "1", "-b", "Y", "-c"}},
+		{Path: "/usr/bin/tool", Args: []string{"-a", "2", "-b", "Y", "-c"}},
+	}, result)
+}
+
+func TestAnalyzeExecsWithProtectedPrefix(t *testing.T) {
+	const threshold = 2
+	var input []types.ExecCalls
+	for _, subcommand := range []string{"commit", "push", "pull", "fetch"} {
+		for i := 0; i <= threshold; i++ {
+			input = append(input, types.ExecCalls{Path: "/usr/bin/git", Args: []string{subcommand, fmt.Sprintf("%s-arg%d", subcommand, i)}})
+		}
+	}
+	// Counted per subcommand: status alone never reaches the threshold.
+	input = append(input, types.ExecCalls{Path: "/usr/bin/git", Args: []string{"status", "--short"}})
+
+	result, err := dynamicpathdetector.AnalyzeExecsWithProtectedPrefix(input, dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold), threshold, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{
+		{Path: "/usr/bin/git", Args: []string{"commit", "⋯"}},
+		{Path: "/usr/bin/git", Args: []string{"fetch", "⋯"}},
+		{Path: "/usr/bin/git", Args: []string{"pull", "⋯"}},
+		{Path: "/usr/bin/git", Args: []string{"push", "⋯"}},
+		{Path: "/usr/bin/git", Args: []string{"status", "--short"}},
+	}, result, "the subcommand stays literal, the arg after it collapses")
+
+	result, err = dynamicpathdetector.AnalyzeExecsWithProtectedPrefix(input, dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold), threshold, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{
+		{Path: "/usr/bin/git", Args: []string{"⋯", "⋯"}},
+	}, result, "without protection the subcommand collapses too")
+
+	result, err = dynamicpathdetector.AnalyzeExecsWithProtectedPrefix(input, dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold), threshold, 5)
+	require.NoError(t, err)
+	assert.Len(t, result, len(input), "protecting past the last arg keeps every vector")
+}
+
+func TestAnalyzeExecsDedupIncludesEnvs(t *testing.T) {
+	input := []types.ExecCalls{
+		{Path: "/usr/bin/env", Args: []string{"-i"}, Envs: []string{"MODE=debug"}},
+		{Path: "/usr/bin/env", Args: []string{"-i"}, Envs: []string{"MODE=release"}},
+		{Path: "/usr/bin/env", Args: []string{"-i"}, Envs: []string{"LANG=C", "MODE=debug"}},
+		{Path: "/usr/bin/env", Args: []string{"-i"}, Envs: []string{"MODE=debug", "LANG=C"}},
+		// Same String() as the first record, with the env passed as an arg.
+		{Path: "/usr/bin/env", Args: []string{"-i", "MODE=debug"}},
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold)
+	result, err := dynamicpathdetector.AnalyzeExecs(input, analyzer)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{
+		{Path: "/usr/bin/env", Args: []string{"-i"}, Envs: []string{"LANG=C", "MODE=debug"}},
+		{Path: "/usr/bin/env", Args: []string{"-i"}, Envs: []string{"MODE=debug"}},
+		{Path: "/usr/bin/env", Args: []string{"-i", "MODE=debug"}},
+		{Path: "/usr/bin/env", Args: []string{"-i"}, Envs: []string{"MODE=release"}},
+	}, result, "only records with the same envs merge")
+
+	// Once MODE collapses, the first two records are the same.
+	result, err = dynamicpathdetector.AnalyzeExecsWithEnvThreshold(input[:2], dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.ExecDynamicThreshold), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExecCalls{{Path: "/usr/bin/env", Args: []string{"-i"}, Envs: []string{"MODE=⋯"}}}, result)
+}
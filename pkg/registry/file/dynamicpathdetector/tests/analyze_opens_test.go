@@ -1,7 +1,9 @@
 package dynamicpathdetectortests
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -10,6 +12,7 @@ import (
 	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
 	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // testCollapseConfigs gives the per-prefix thresholds this file's
@@ -914,6 +917,16 @@ func TestFindConfigForPath(t *testing.T) {
 			expectedPrefix:    "/var/run",
 			expectedThreshold: 3,
 		},
+		{
+			path:              "/app/bin/server",
+			expectedPrefix:    "/app",
+			expectedThreshold: 1,
+		},
+		{
+			path:              "/application/bin/server",
+			expectedPrefix:    "/",
+			expectedThreshold: dynamicpathdetector.DefaultCollapseConfig.Threshold,
+		},
 		{
 			path:              "/var/log/app.log",
 			expectedPrefix:    "/",
@@ -969,3 +982,958 @@ func TestFindConfigForPath_DefensiveCopy(t *testing.T) {
 	assert.NotEqual(t, "/poisoned-default", secondDefault.Prefix,
 		"mutating the default config Prefix must not leak into a future call")
 }
+
+// TestAnalyzeOpensExtensionThresholds checks that ExtensionThresholds
+// overrides the prefix Threshold based on the final segment's extension:
+// eleven .so files under /usr/lib collapse at the .so threshold of 10,
+// while eleven .conf files in the same directory stay literal because
+// .conf is configured higher. Unlisted extensions use the prefix Threshold.
+func TestAnalyzeOpensExtensionThresholds(t *testing.T) {
+	configs := []dynamicpathdetector.CollapseConfig{
+		{
+			Prefix:              "/usr/lib",
+			Threshold:           50,
+			ExtensionThresholds: map[string]int{".so": 10, ".conf": 20},
+		},
+	}
+
+	t.Run("so collapses at extension threshold", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, configs)
+		result, err := dynamicpathdetector.AnalyzeOpens(filesWithExtension("/usr/lib", ".so", 11), analyzer, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/usr/lib/⋯"}, pathsFromResult(result))
+	})
+
+	t.Run("conf under same directory stays literal", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, configs)
+		result, err := dynamicpathdetector.AnalyzeOpens(filesWithExtension("/usr/lib", ".conf", 11), analyzer, nil)
+		assert.NoError(t, err)
+		assert.Len(t, result, 11)
+		for _, open := range result {
+			assert.NotContains(t, open.Path, dynamicpathdetector.DynamicIdentifier)
+		}
+	})
+
+	t.Run("unlisted extension falls back to prefix threshold", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, configs)
+		result, err := dynamicpathdetector.AnalyzeOpens(filesWithExtension("/usr/lib", ".py", 11), analyzer, nil)
+		assert.NoError(t, err)
+		assert.Len(t, result, 11)
+	})
+
+	t.Run("config maps are copied", func(t *testing.T) {
+		own := []dynamicpathdetector.CollapseConfig{{Prefix: "/usr/lib", Threshold: 50, ExtensionThresholds: map[string]int{".so": 10}}}
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, own)
+		own[0].ExtensionThresholds[".so"] = 1
+		got := analyzer.FindConfigForPath("/usr/lib")
+		assert.Equal(t, 10, got.ExtensionThresholds[".so"])
+		got.ExtensionThresholds[".so"] = 2
+		assert.Equal(t, 10, analyzer.FindConfigForPath("/usr/lib").ExtensionThresholds[".so"])
+	})
+}
+
+// filesWithExtension creates n OpenCalls for prefix/fileN<ext>, i.e. n
+// sibling files directly under prefix.
+func filesWithExtension(prefix, ext string, n int) []types.OpenCalls {
+	result := make([]types.OpenCalls, 0, n)
+	for i := 0; i < n; i++ {
+		result = append(result, types.OpenCalls{
+			Path:  fmt.Sprintf("%s/file%d%s", prefix, i, ext),
+			Flags: []string{"READ"},
+		})
+	}
+	return result
+}
+
+func TestAnalyzeOpensIncremental(t *testing.T) {
+	threshold := configThreshold("/var/run")
+	newAnalyzer := func() *dynamicpathdetector.PathAnalyzer {
+		return dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	}
+
+	t.Run("new path collapses into existing dynamic node and merges flags", func(t *testing.T) {
+		analyzer := newAnalyzer()
+		existing, err := dynamicpathdetector.AnalyzeOpens([]types.OpenCalls{
+			{Path: "/home/user0/file.txt", Flags: []string{"READ"}},
+			{Path: "/home/user1/file.txt", Flags: []string{"READ"}},
+			{Path: "/home/user2/file.txt", Flags: []string{"READ"}},
+			{Path: "/home/user3/file.txt", Flags: []string{"READ"}},
+		}, analyzer, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/⋯/file.txt"}, pathsFromResult(existing))
+
+		result, err := dynamicpathdetector.AnalyzeOpensIncremental(existing, []types.OpenCalls{
+			{Path: "/home/user99/file.txt", Flags: []string{"WRITE"}},
+			{Path: "/etc/hosts", Flags: []string{"READ"}},
+		}, analyzer, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []types.OpenCalls{
+			{Path: "/etc/hosts", Flags: []string{"READ"}},
+			{Path: "/home/⋯/file.txt", Flags: []string{"READ", "WRITE"}},
+		}, result)
+		assert.Equal(t, []string{"READ"}, existing[0].Flags, "existing must not be modified")
+	})
+
+	t.Run("matches full analysis when nothing crosses threshold", func(t *testing.T) {
+		first := []types.OpenCalls{
+			{Path: "/opt/a/x", Flags: []string{"READ"}},
+			{Path: "/opt/b/x", Flags: []string{"READ"}},
+		}
+		second := []types.OpenCalls{
+			{Path: "/opt/a/z", Flags: []string{"WRITE"}},
+			{Path: "/opt/c/y", Flags: []string{"READ"}},
+		}
+
+		analyzer := newAnalyzer()
+		existing, err := dynamicpathdetector.AnalyzeOpens(first, analyzer, nil)
+		require.NoError(t, err)
+		incremental, err := dynamicpathdetector.AnalyzeOpensIncremental(existing, second, analyzer, nil)
+		require.NoError(t, err)
+
+		full, err := dynamicpathdetector.AnalyzeOpens(append(append([]types.OpenCalls{}, first...), second...), newAnalyzer(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, full, incremental)
+	})
+
+	t.Run("sbom paths stay literal", func(t *testing.T) {
+		analyzer := newAnalyzer()
+		existing, err := dynamicpathdetector.AnalyzeOpens(generateOpenCallsWithFlags("/lib", "libc.so", threshold+1), analyzer, nil)
+		require.NoError(t, err)
+		result, err := dynamicpathdetector.AnalyzeOpensIncremental(existing, []types.OpenCalls{
+			{Path: "/lib/user42/libc.so", Flags: []string{"READ"}},
+		}, analyzer, mapset.NewSet("/lib/user42/libc.so"))
+		require.NoError(t, err)
+		assertContainsPath(t, result, "/lib/user42/libc.so")
+		assertContainsPath(t, result, "/lib/⋯/libc.so")
+	})
+
+	t.Run("nil new opens returns existing", func(t *testing.T) {
+		existing := []types.OpenCalls{{Path: "/a", Flags: []string{"READ"}}}
+		result, err := dynamicpathdetector.AnalyzeOpensIncremental(existing, nil, newAnalyzer(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, existing, result)
+	})
+}
+
+func TestAnalyzeOpensCollapseNumericSegments(t *testing.T) {
+	configs := []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/proc", Threshold: 50, CollapseNumericSegments: true},
+	}
+	analyze := func(t *testing.T, paths ...string) []string {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, configs)
+		result, err := dynamicpathdetector.AnalyzeOpens(pathsToOpens(paths), analyzer, nil)
+		require.NoError(t, err)
+		return pathsFromResult(result)
+	}
+
+	t.Run("pure numeric collapses with two samples", func(t *testing.T) {
+		assert.Equal(t, []string{"/proc/⋯/status"}, analyze(t, "/proc/1234/status", "/proc/5678/status"))
+	})
+
+	t.Run("single numeric sample collapses", func(t *testing.T) {
+		assert.Equal(t, []string{"/proc/⋯/status"}, analyze(t, "/proc/1/status"))
+	})
+
+	t.Run("mixed alphanumeric stays literal", func(t *testing.T) {
+		assert.Equal(t, []string{"/proc/1234abc/status", "/proc/abc123/status"},
+			analyze(t, "/proc/abc123/status", "/proc/1234abc/status"))
+	})
+
+	t.Run("numeric joins existing dynamic node", func(t *testing.T) {
+		assert.Equal(t, []string{"/proc/⋯/fd", "/proc/⋯/status"},
+			analyze(t, "/proc/⋯/status", "/proc/42/fd"))
+	})
+
+	t.Run("literal siblings fold into the dynamic node", func(t *testing.T) {
+		assert.Equal(t, []string{"/proc/⋯/status"}, analyze(t, "/proc/self/status", "/proc/42/status"))
+	})
+
+	t.Run("only applies under the configured prefix", func(t *testing.T) {
+		assert.Equal(t, []string{"/var/1234/log", "/var/5678/log"}, analyze(t, "/var/1234/log", "/var/5678/log"))
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs())
+		result, err := dynamicpathdetector.AnalyzeOpens(pathsToOpens([]string{"/app/1234/status", "/app/5678/status"}), analyzer, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/app/1234/status", "/app/5678/status"}, pathsFromResult(result))
+	})
+}
+
+func TestAnalyzeOpensCollapseEntropicSegments(t *testing.T) {
+	configs := []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/var/cache", Threshold: 50, CollapseEntropicSegments: true},
+	}
+	tests := []struct {
+		name    string
+		segment string
+		want    string
+	}{
+		{"uuid v4", "3f2504e0-4f89-41d3-9a0c-0305e82c3301", "/var/cache/⋯/data"},
+		{"uuid upper case", "3F2504E0-4F89-11D3-9A0C-0305E82C3301", "/var/cache/⋯/data"},
+		{"md5", "d41d8cd98f00b204e9800998ecf8427e", "/var/cache/⋯/data"},
+		{"sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "/var/cache/⋯/data"},
+		{"short hex", "deadbeef", "/var/cache/deadbeef/data"},
+		{"31 hex chars", "d41d8cd98f00b204e9800998ecf8427", "/var/cache/d41d8cd98f00b204e9800998ecf8427/data"},
+		{"uuid with non-hex", "3f2504e0-4f89-41d3-9a0c-0305e82c330z", "/var/cache/3f2504e0-4f89-41d3-9a0c-0305e82c330z/data"},
+		{"uuid with misplaced dashes", "3f2504e04-f89-41d3-9a0c-0305e82c3301", "/var/cache/3f2504e04-f89-41d3-9a0c-0305e82c3301/data"},
+		{"long non-hex", "this-is-a-long-descriptive-directory-name", "/var/cache/this-is-a-long-descriptive-directory-name/data"},
+		{"numeric needs its own flag", "1234", "/var/cache/1234/data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, configs)
+			got, err := analyzer.AnalyzePath("/var/cache/"+tt.segment+"/data", "opens")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("outside the configured prefix", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, configs)
+		got, err := analyzer.AnalyzePath("/tmp/3f2504e0-4f89-41d3-9a0c-0305e82c3301/data", "opens")
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/3f2504e0-4f89-41d3-9a0c-0305e82c3301/data", got)
+	})
+}
+
+func TestAnalyzeOpensWithFlagAllowlist(t *testing.T) {
+	threshold := configThreshold("/var/run")
+	var opens []types.OpenCalls
+	for i := 0; i < threshold+1; i++ {
+		opens = append(opens, types.OpenCalls{
+			Path:  fmt.Sprintf("/var/run/svc%d/pid", i),
+			Flags: []string{"O_RDONLY", "O_CLOEXEC"},
+		})
+	}
+	opens = append(opens,
+		types.OpenCalls{Path: "/var/run/svc0/pid", Flags: []string{"O_BOGUS", "O_WRONLY"}},
+		types.OpenCalls{Path: "/etc/hosts", Flags: []string{"O_RDONLY", "O_BOGUS", "O_RDONLY"}},
+		types.OpenCalls{Path: "/etc/junk", Flags: []string{"O_BOGUS"}},
+	)
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	result, err := dynamicpathdetector.AnalyzeOpensWithFlagAllowlist(opens, analyzer, nil, dynamicpathdetector.DefaultOpenFlags())
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+		{Path: "/etc/junk", Flags: []string{}},
+		{Path: "/var/run/⋯/pid", Flags: []string{"O_CLOEXEC", "O_RDONLY", "O_WRONLY"}},
+	}, result)
+
+	unfiltered, err := dynamicpathdetector.AnalyzeOpens(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs), nil)
+	require.NoError(t, err)
+	assertContainsPath(t, unfiltered, "/var/run/⋯/pid")
+	for _, open := range unfiltered {
+		if open.Path == "/var/run/⋯/pid" {
+			assert.Contains(t, open.Flags, "O_BOGUS", "AnalyzeOpens keeps every flag")
+		}
+	}
+}
+
+// TestAnalyzeOpensCollapsesBasenames pins that file names are collapsed like
+// directories: more than threshold distinct files directly under one
+// directory become dir/⋯ even though the directory itself never varies.
+func TestAnalyzeOpensCollapsesBasenames(t *testing.T) {
+	threshold := dynamicpathdetector.OpenDynamicThreshold
+	logFiles := func(n int) []types.OpenCalls {
+		opens := make([]types.OpenCalls, 0, n)
+		for i := 0; i < n; i++ {
+			opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/var/log/app-%03d.log", i), Flags: []string{"O_WRONLY"}})
+		}
+		return opens
+	}
+
+	t.Run("threshold+1 files collapse", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, dynamicpathdetector.DefaultCollapseConfigs())
+		result, err := dynamicpathdetector.AnalyzeOpens(logFiles(threshold+1), analyzer, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []types.OpenCalls{{Path: "/var/log/⋯", Flags: []string{"O_WRONLY"}}}, result)
+	})
+
+	t.Run("threshold files stay literal", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, dynamicpathdetector.DefaultCollapseConfigs())
+		result, err := dynamicpathdetector.AnalyzeOpens(logFiles(threshold), analyzer, nil)
+		require.NoError(t, err)
+		assert.Len(t, result, threshold)
+	})
+}
+
+func TestAnalyzeOpensExcludePrefixes(t *testing.T) {
+	opens := pathsToOpens([]string{
+		"/proc/1/stat",
+		"/proc/self/status",
+		"/proc",
+		"/sys/fs/cgroup/memory.max",
+		"/process/worker/state",
+		"/system/config",
+		"/etc/hosts",
+	})
+	opts := dynamicpathdetector.AnalyzeOpensOpts{ExcludePrefixes: []string{"/proc", "/sys/"}}
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
+	result, err := dynamicpathdetector.AnalyzeOpensWithOptions(opens, analyzer, mapset.NewSet("/proc/1/stat"), opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/etc/hosts", "/process/worker/state", "/system/config"}, pathsFromResult(result))
+	assert.Len(t, opens, 7, "input must not be modified")
+}
+
+// TestAnalyzeOpensExcludePrefixesBeforeCollapse checks that excluded paths
+// do not count toward thresholds: with secrets included /var/run would have
+// threshold+1 children and collapse; excluded, the kept files stay literal.
+func TestAnalyzeOpensExcludePrefixesBeforeCollapse(t *testing.T) {
+	threshold := configThreshold("/var/run")
+	var paths []string
+	for i := 0; i < threshold; i++ {
+		paths = append(paths, fmt.Sprintf("/var/run/keep%d", i), fmt.Sprintf("/var/run/secrets/%d", i))
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	result, err := dynamicpathdetector.AnalyzeOpensWithOptions(pathsToOpens(paths), analyzer, nil,
+		dynamicpathdetector.AnalyzeOpensOpts{ExcludePrefixes: []string{"/var/run/secrets"}})
+	require.NoError(t, err)
+	assert.Len(t, result, threshold)
+	for _, open := range result {
+		assert.NotContains(t, open.Path, dynamicpathdetector.DynamicIdentifier)
+	}
+}
+
+func TestCountUniqueOpens(t *testing.T) {
+	tests := []struct {
+		name  string
+		opens []types.OpenCalls
+		want  int
+	}{
+		{name: "nil"},
+		{
+			name: "duplicates",
+			opens: []types.OpenCalls{
+				{Path: "/etc/passwd", Flags: []string{"O_RDONLY"}},
+				{Path: "/etc/passwd", Flags: []string{"O_WRONLY"}},
+				{Path: "/etc//passwd"},
+				{Path: "/etc/./passwd"},
+			},
+			want: 1,
+		},
+		{
+			name: "distinct",
+			opens: []types.OpenCalls{
+				{Path: "/etc/passwd"},
+				{Path: "/etc/group"},
+				{Path: "/etc"},
+			},
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, dynamicpathdetector.CountUniqueOpens(tt.opens))
+		})
+	}
+}
+
+func TestCountUniqueOpensIgnoresCollapse(t *testing.T) {
+	threshold := configThreshold("/opt")
+	var opens []types.OpenCalls
+	for i := 0; i < threshold+5; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/opt/plugin%d/lib.so", i)})
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+	require.NoError(t, err)
+
+	assert.Len(t, result, 1)
+	assert.Equal(t, threshold+5, dynamicpathdetector.CountUniqueOpens(opens))
+}
+
+func TestAnalyzeOpensStream(t *testing.T) {
+	threshold := configThreshold("/opt")
+	opens := make([]types.OpenCalls, 0, threshold+1)
+	for i := 0; i < threshold+1; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/opt/plugin%d/lib.so", i), Flags: []string{"O_RDONLY"}})
+	}
+
+	in := make(chan types.OpenCalls)
+	go func() {
+		defer close(in)
+		for _, open := range opens {
+			in <- open
+		}
+	}()
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	out, errc := dynamicpathdetector.AnalyzeOpensStream(in, analyzer, nil)
+
+	var got []types.OpenCalls
+	for open := range out {
+		got = append(got, open)
+	}
+	require.NoError(t, <-errc)
+	assert.Equal(t, []types.OpenCalls{{Path: "/opt/⋯/lib.so", Flags: []string{"O_RDONLY"}}}, got)
+
+	want, err := dynamicpathdetector.AnalyzeOpens(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs), nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, got, "stream must match AnalyzeOpens")
+}
+
+func TestAnalyzeOpensStreamEmpty(t *testing.T) {
+	in := make(chan types.OpenCalls)
+	close(in)
+	out, errc := dynamicpathdetector.AnalyzeOpensStream(in, dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold), nil)
+	_, ok := <-out
+	assert.False(t, ok)
+	require.NoError(t, <-errc)
+}
+
+// TestAnalyzeOpensWildcardAbsorbsLiterals feeds a user-supplied /app/*
+// entry together with enough /app/x/y literals to collapse /app to ⋯.
+// Whichever comes first, everything must merge into /app/* rather than
+// the * being swallowed by the ⋯.
+func TestAnalyzeOpensWildcardAbsorbsLiterals(t *testing.T) {
+	const threshold = 3
+	var literals []types.OpenCalls
+	for i := 0; i < threshold+2; i++ {
+		literals = append(literals, types.OpenCalls{Path: fmt.Sprintf("/app/x%d/y", i), Flags: []string{"O_RDONLY"}})
+	}
+	pattern := types.OpenCalls{Path: "/app/*", Flags: []string{"O_WRONLY"}}
+	want := []types.OpenCalls{{Path: "/app/*", Flags: []string{"O_RDONLY", "O_WRONLY"}}}
+
+	for name, opens := range map[string][]types.OpenCalls{
+		"pattern first": append([]types.OpenCalls{pattern}, literals...),
+		"pattern last":  append(slices.Clone(literals), pattern),
+	} {
+		t.Run(name, func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+			result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+			require.NoError(t, err)
+			assert.Equal(t, want, result)
+
+			peeked, err := analyzer.PeekPath("/app/new/y", "opens")
+			require.NoError(t, err)
+			assert.Equal(t, "/app/*", peeked)
+		})
+	}
+}
+
+// TestAnalyzeOpensWildcardKeepsAllAbsorbedFlags checks that /app/* ends
+// up with its own flags plus those of every literal it absorbs in the
+// same pass, wherever it appears among them.
+func TestAnalyzeOpensWildcardKeepsAllAbsorbedFlags(t *testing.T) {
+	literals := []types.OpenCalls{
+		{Path: "/app/a", Flags: []string{"O_RDONLY"}},
+		{Path: "/app/b", Flags: []string{"O_WRONLY", "O_CREAT"}},
+		{Path: "/app/c", Flags: []string{"O_APPEND"}},
+	}
+	pattern := types.OpenCalls{Path: "/app/*", Flags: []string{"O_CLOEXEC"}}
+	want := []types.OpenCalls{{Path: "/app/*", Flags: []string{"O_APPEND", "O_CLOEXEC", "O_CREAT", "O_RDONLY", "O_WRONLY"}}}
+
+	for i := 0; i <= len(literals); i++ {
+		opens := slices.Insert(slices.Clone(literals), i, pattern)
+		t.Run(fmt.Sprintf("pattern at %d", i), func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
+			result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+			require.NoError(t, err)
+			assert.Equal(t, want, result)
+		})
+	}
+}
+
+func TestAnalyzeOpensNeverCollapse(t *testing.T) {
+	const threshold = 3
+	configs := []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/etc/sensitive", Threshold: dynamicpathdetector.NeverCollapse},
+	}
+	var opens []types.OpenCalls
+	var want []string
+	for i := 0; i < 10*threshold; i++ {
+		p := fmt.Sprintf("/etc/sensitive/key%02d", i)
+		opens = append(opens, types.OpenCalls{Path: p})
+		want = append(want, p)
+		p = fmt.Sprintf("/etc/sensitive/nested/cert%02d.pem", i)
+		opens = append(opens, types.OpenCalls{Path: p})
+		want = append(want, p)
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/tmp/scratch%02d", i)})
+	}
+	want = append(want, "/tmp/⋯")
+	sort.Strings(want)
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, configs)
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, pathsFromResult(result), "everything under the pinned prefix stays literal; /tmp still collapses")
+
+	peeked, err := analyzer.PeekPath("/etc/sensitive/brand-new", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/sensitive/brand-new", peeked)
+}
+
+func TestNewPathAnalyzerThresholdZeroNeverCollapses(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.NeverCollapse)
+	for i := 0; i < 200; i++ {
+		got, err := analyzer.AnalyzePath(fmt.Sprintf("/data/file%d", i), "opens")
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("/data/file%d", i), got)
+	}
+	assert.Len(t, analyzer.GetStoredPaths("opens"), 200)
+}
+
+func TestAnalyzeOpensOnCollapse(t *testing.T) {
+	threshold := configThreshold("/opt")
+	var opens []types.OpenCalls
+	for i := 0; i < threshold+3; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/opt/plugin%d/lib.so", i)})
+	}
+	opens = append(opens,
+		types.OpenCalls{Path: "/opt/plugin0/lib.so"}, // duplicate, collapses again
+		types.OpenCalls{Path: "/etc/hosts"},          // never collapses
+	)
+	sbomSet := mapset.NewSet("/opt/plugin1/lib.so", "/opt/plugin2/lib.so")
+
+	collapsed := make(map[string]int)
+	calls := 0
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	result, err := dynamicpathdetector.AnalyzeOpensWithOptions(opens, analyzer, sbomSet, dynamicpathdetector.AnalyzeOpensOpts{
+		OnCollapse: func(original, collapsedPath string) {
+			calls++
+			collapsed[original]++
+			assert.Equal(t, "/opt/⋯/lib.so", collapsedPath)
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, threshold+3-2+1, calls, "every non-SBOM /opt open, duplicates included")
+	assert.Equal(t, 2, collapsed["/opt/plugin0/lib.so"])
+	assert.NotContains(t, collapsed, "/opt/plugin1/lib.so", "SBOM paths are never collapsed")
+	assert.NotContains(t, collapsed, "/opt/plugin2/lib.so", "SBOM paths are never collapsed")
+	assert.NotContains(t, collapsed, "/etc/hosts")
+	assert.Equal(t, []string{"/etc/hosts", "/opt/plugin1/lib.so", "/opt/plugin2/lib.so", "/opt/⋯/lib.so"}, pathsFromResult(result))
+}
+
+func TestAnalyzeOpensOnCollapseIgnoresCleaning(t *testing.T) {
+	calls := 0
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+	_, err := dynamicpathdetector.AnalyzeOpensWithOptions([]types.OpenCalls{{Path: "/etc/../etc//hosts"}}, analyzer, nil, dynamicpathdetector.AnalyzeOpensOpts{
+		OnCollapse: func(string, string) { calls++ },
+	})
+	require.NoError(t, err)
+	assert.Zero(t, calls, "normalizing a path is not a collapse")
+}
+
+func TestAnalyzeOpensFlagsAreCanonical(t *testing.T) {
+	opens := []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY", "O_CLOEXEC"}},
+		{Path: "/etc/passwd", Flags: []string{"O_RDONLY", "O_CLOEXEC", "O_RDONLY"}},
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+	require.NoError(t, err)
+
+	require.Len(t, result, 2)
+	for _, open := range result {
+		assert.Equal(t, []string{"O_CLOEXEC", "O_RDONLY"}, open.Flags, open.Path)
+	}
+	assert.True(t, result[0].Equal(types.OpenCalls{Path: "/etc/hosts", Flags: []string{"O_CLOEXEC", "O_RDONLY"}}))
+}
+
+func TestAnalyzeOpensWithOriginals(t *testing.T) {
+	const threshold = 3
+	var opens []types.OpenCalls
+	for i := 0; i < threshold+2; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/data/job%d/log", i), Flags: []string{"O_RDONLY"}})
+	}
+	opens = append(opens,
+		types.OpenCalls{Path: "/data/job0/log", Flags: []string{"O_WRONLY"}},
+		types.OpenCalls{Path: "/data/sbom/log", Flags: []string{"O_RDONLY"}},
+		types.OpenCalls{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+	)
+	sbomSet := mapset.NewSet("/data/sbom/log")
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	result, originals, err := dynamicpathdetector.AnalyzeOpensWithOriginals(opens, analyzer, sbomSet)
+	require.NoError(t, err)
+
+	want, err := dynamicpathdetector.AnalyzeOpens(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), sbomSet)
+	require.NoError(t, err)
+	assert.Equal(t, want, result, "same result as AnalyzeOpens")
+
+	assert.Equal(t, map[string][]string{
+		"/data/⋯/log":    {"/data/job0/log", "/data/job1/log", "/data/job2/log", "/data/job3/log", "/data/job4/log"},
+		"/data/sbom/log": {"/data/sbom/log"},
+		"/etc/hosts":     {"/etc/hosts"},
+	}, originals)
+
+	// Every result path is a key, every input is listed, and each original
+	// is matched by the entry it was folded into.
+	seen := mapset.NewSet[string]()
+	for _, open := range result {
+		require.Contains(t, originals, open.Path)
+		for _, original := range originals[open.Path] {
+			assert.True(t, dynamicpathdetector.CompareDynamic(open.Path, original), "%s -> %s", original, open.Path)
+			seen.Add(original)
+		}
+	}
+	assert.Len(t, originals, len(result))
+	for _, open := range opens {
+		assert.True(t, seen.Contains(open.Path), open.Path)
+	}
+}
+
+func TestAnalyzeOpensWithOriginalsNil(t *testing.T) {
+	result, originals, err := dynamicpathdetector.AnalyzeOpensWithOriginals(nil, dynamicpathdetector.NewPathAnalyzer(3), nil)
+	require.NoError(t, err)
+	assert.Nil(t, result)
+	assert.Nil(t, originals)
+}
+
+func TestAnalyzeOpensSorted(t *testing.T) {
+	opens := []types.OpenCalls{
+		{Path: "/etc/ssl/certs/ca.pem", Flags: []string{"O_RDONLY"}},
+		{Path: "/usr/lib/x86_64/libc.so", Flags: []string{"O_RDONLY"}},
+		{Path: "/tmp", Flags: []string{"O_DIRECTORY"}},
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/log/app.log", Flags: []string{"O_WRONLY"}},
+		{Path: "/bin", Flags: []string{"O_DIRECTORY"}},
+	}
+	deepestFirst := func(a, b types.OpenCalls) int {
+		return cmp.Compare(strings.Count(b.Path, "/"), strings.Count(a.Path, "/"))
+	}
+
+	result, err := dynamicpathdetector.AnalyzeOpensSorted(opens, dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold), nil, deepestFirst)
+	require.NoError(t, err)
+	var got []string
+	for _, open := range result {
+		got = append(got, open.Path)
+	}
+	assert.Equal(t, []string{
+		"/etc/ssl/certs/ca.pem",
+		"/usr/lib/x86_64/libc.so",
+		"/var/log/app.log",
+		"/etc/hosts",
+		"/bin",
+		"/tmp",
+	}, got, "deepest first, ties in path order")
+
+	want, err := dynamicpathdetector.AnalyzeOpens(opens, dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold), nil)
+	require.NoError(t, err)
+	result, err = dynamicpathdetector.AnalyzeOpensSorted(opens, dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, result, "a nil comparator keeps the path order")
+}
+
+// TestAnalyzeOpensMidPathGlob feeds a user /var/lib/*/data pattern with
+// literals it covers: they fold into it, whatever the order, instead of
+// being collapsed by the trie, and the pattern keeps its /data suffix
+// rather than widening to /var/lib/*.
+func TestAnalyzeOpensMidPathGlob(t *testing.T) {
+	const threshold = 3
+	pattern := types.OpenCalls{Path: "/var/lib/*/data", Flags: []string{"O_RDONLY"}}
+	literals := []types.OpenCalls{{Path: "/var/lib/other", Flags: []string{"O_RDONLY"}}}
+	for i := 0; i < threshold+3; i++ {
+		literals = append(literals, types.OpenCalls{Path: fmt.Sprintf("/var/lib/app%d/data", i), Flags: []string{"O_WRONLY"}})
+	}
+	literals = append(literals,
+		types.OpenCalls{Path: "/var/lib/a/b/data", Flags: []string{"O_CREAT"}},
+		types.OpenCalls{Path: "/var/lib/app0/config", Flags: []string{"O_RDONLY"}},
+	)
+	want := []types.OpenCalls{
+		{Path: "/var/lib/*/data", Flags: []string{"O_CREAT", "O_RDONLY", "O_WRONLY"}},
+		{Path: "/var/lib/app0/config", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/lib/other", Flags: []string{"O_RDONLY"}},
+	}
+
+	for name, opens := range map[string][]types.OpenCalls{
+		"pattern first": append([]types.OpenCalls{pattern}, literals...),
+		"pattern last":  append(slices.Clone(literals), pattern),
+	} {
+		t.Run(name, func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+			var collapsed []string
+			result, err := dynamicpathdetector.AnalyzeOpensWithOptions(opens, analyzer, nil, dynamicpathdetector.AnalyzeOpensOpts{
+				OnCollapse: func(original, _ string) { collapsed = append(collapsed, original) },
+			})
+			require.NoError(t, err)
+			assert.Equal(t, want, result)
+			assert.Len(t, collapsed, threshold+4, "every absorbed literal is reported")
+			assert.NotContains(t, analyzer.GetStoredPaths("opens"), "/var/lib/*", "the pattern is not fed to the trie")
+		})
+	}
+}
+
+// TestAnalyzeOpensMidPathGlobCustomIdentifiers is the mid-path pattern
+// case for an analyzer with its own wildcard identifier, which a * in
+// the pattern's place would not be.
+func TestAnalyzeOpensMidPathGlobCustomIdentifiers(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithIdentifiers(3, "{d}", "**")
+	opens := []types.OpenCalls{{Path: "/var/lib/**/data", Flags: []string{"O_RDONLY"}}}
+	for i := 0; i < 5; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/var/lib/app%d/data", i), Flags: []string{"O_WRONLY"}})
+	}
+	opens = append(opens, types.OpenCalls{Path: "/var/lib/app0/config", Flags: []string{"O_RDONLY"}})
+
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/var/lib/**/data", Flags: []string{"O_RDONLY", "O_WRONLY"}},
+		{Path: "/var/lib/app0/config", Flags: []string{"O_RDONLY"}},
+	}, result)
+	assert.NotContains(t, analyzer.GetStoredPaths("opens"), "/var/lib/**", "the pattern is not fed to the trie")
+}
+
+func TestAnalyzeOpensIncrementalMidPathGlob(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
+	existing, err := dynamicpathdetector.AnalyzeOpens([]types.OpenCalls{
+		{Path: "/var/lib/*/data", Flags: []string{"O_RDONLY"}},
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+	}, analyzer, nil)
+	require.NoError(t, err)
+
+	result, err := dynamicpathdetector.AnalyzeOpensIncremental(existing, []types.OpenCalls{
+		{Path: "/var/lib/new/data", Flags: []string{"O_WRONLY"}},
+		{Path: "/var/lib/new/log", Flags: []string{"O_WRONLY"}},
+	}, analyzer, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/lib/*/data", Flags: []string{"O_RDONLY", "O_WRONLY"}},
+		{Path: "/var/lib/new/log", Flags: []string{"O_WRONLY"}},
+	}, result)
+}
+
+// TestAnalyzeOpensWithMaxResults caps a 20-entry result that collapse
+// leaves alone, since no directory reaches the threshold, at 10 entries.
+func TestAnalyzeOpensWithMaxResults(t *testing.T) {
+	var opens []types.OpenCalls
+	for i := 0; i < 12; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/data/a/file%d", i), Flags: []string{"O_RDONLY"}})
+	}
+	for i := 0; i < 8; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/data/b/file%d", i), Flags: []string{"O_WRONLY"}})
+	}
+	newAnalyzer := func() *dynamicpathdetector.PathAnalyzer {
+		return dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
+	}
+
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, newAnalyzer(), nil)
+	require.NoError(t, err)
+	require.Len(t, result, 20)
+
+	// Lowering the threshold to 8 folds /data/a only, which fits.
+	result, err = dynamicpathdetector.AnalyzeOpensWithMaxResults(opens, newAnalyzer(), nil, 10)
+	require.NoError(t, err)
+	want := []types.OpenCalls{{Path: "/data/a/⋯", Flags: []string{"O_RDONLY"}}}
+	for i := 0; i < 8; i++ {
+		want = append(want, types.OpenCalls{Path: fmt.Sprintf("/data/b/file%d", i), Flags: []string{"O_WRONLY"}})
+	}
+	assert.Equal(t, want, result)
+
+	// Two entries need both directories folded; one needs /data itself.
+	result, err = dynamicpathdetector.AnalyzeOpensWithMaxResults(opens, newAnalyzer(), nil, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/data/a/⋯", Flags: []string{"O_RDONLY"}},
+		{Path: "/data/b/⋯", Flags: []string{"O_WRONLY"}},
+	}, result)
+	result, err = dynamicpathdetector.AnalyzeOpensWithMaxResults(opens, newAnalyzer(), nil, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{{Path: "/data/*", Flags: []string{"O_RDONLY", "O_WRONLY"}}}, result)
+
+	// SBOM paths are never folded but count towards the cap.
+	var collapsed int
+	result, err = dynamicpathdetector.AnalyzeOpensWithOptions(opens, newAnalyzer(), mapset.NewSet("/data/b/file0"), dynamicpathdetector.AnalyzeOpensOpts{
+		MaxResults: 2,
+		OnCollapse: func(string, string) { collapsed++ },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/data/*", Flags: []string{"O_RDONLY", "O_WRONLY"}},
+		{Path: "/data/b/file0", Flags: []string{"O_WRONLY"}},
+	}, result)
+	assert.Equal(t, 19, collapsed, "folded opens are reported")
+
+	result, err = dynamicpathdetector.AnalyzeOpensWithMaxResults(opens, newAnalyzer(), nil, 0)
+	require.NoError(t, err)
+	assert.Len(t, result, 20, "a non-positive cap keeps every entry")
+}
+
+func TestAnalyzeOpensPrefixAliases(t *testing.T) {
+	opens := []types.OpenCalls{
+		{Path: "/var/run/docker.sock", Flags: []string{"O_RDWR"}},
+		{Path: "/run/docker.sock", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/run", Flags: []string{"O_DIRECTORY"}},
+		{Path: "/var/runner/x", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/run/secrets/token", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/log/app.log", Flags: []string{"O_WRONLY"}},
+	}
+	aliases := map[string]string{
+		"/var/run":         "/run",
+		"/var/run/secrets": "/secrets",
+	}
+
+	var collapsed []string
+	result, err := dynamicpathdetector.AnalyzeOpensWithOptions(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil), nil, dynamicpathdetector.AnalyzeOpensOpts{
+		PrefixAliases: aliases,
+		OnCollapse:    func(original, _ string) { collapsed = append(collapsed, original) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/run", Flags: []string{"O_DIRECTORY"}},
+		{Path: "/run/docker.sock", Flags: []string{"O_RDONLY", "O_RDWR"}},
+		{Path: "/secrets/token", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/log/app.log", Flags: []string{"O_WRONLY"}},
+		{Path: "/var/runner/x", Flags: []string{"O_RDONLY"}},
+	}, result, "longest alias wins, at path boundaries only")
+	assert.Empty(t, collapsed, "aliasing is not a collapse")
+
+	// Aliased opens count toward the canonical directory's threshold.
+	const threshold = 3
+	opens = nil
+	for i := 0; i <= threshold+1; i++ { // pid0 is SBOM-listed and not walked
+		dir := "/run"
+		if i%2 == 0 {
+			dir = "/var/run"
+		}
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("%s/pid%d", dir, i), Flags: []string{"O_RDONLY"}})
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	result, err = dynamicpathdetector.AnalyzeOpensWithOptions(opens, analyzer, mapset.NewSet("/var/run/pid0"), dynamicpathdetector.AnalyzeOpensOpts{PrefixAliases: aliases})
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/run/⋯", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/run/pid0", Flags: []string{"O_RDONLY"}},
+	}, result, "SBOM paths are kept as listed")
+}
+
+func TestAnalyzeOpensForIdentifier(t *testing.T) {
+	const threshold = 3
+	opens := func(names ...string) []types.OpenCalls {
+		var out []types.OpenCalls
+		for _, name := range names {
+			out = append(out, types.OpenCalls{Path: "/data/" + name, Flags: []string{"O_RDONLY"}})
+		}
+		return out
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+
+	// Each container alone stays within the threshold of /data; through a
+	// shared trie the second would see six children and collapse.
+	first, err := dynamicpathdetector.AnalyzeOpensForIdentifier(opens("a", "b", "c"), analyzer, nil, "app")
+	require.NoError(t, err)
+	assert.Equal(t, opens("a", "b", "c"), first)
+	second, err := dynamicpathdetector.AnalyzeOpensForIdentifier(opens("d", "e", "f"), analyzer, nil, "sidecar")
+	require.NoError(t, err)
+	assert.Equal(t, opens("d", "e", "f"), second, "identical paths in another identifier do not count")
+
+	// Collapse in one identifier leaves the other literal.
+	collapsed, err := dynamicpathdetector.AnalyzeOpensForIdentifier(opens("g", "h"), analyzer, nil, "app")
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{{Path: "/data/⋯", Flags: []string{"O_RDONLY"}}}, collapsed)
+	second, err = dynamicpathdetector.AnalyzeOpensForIdentifier(opens("d"), analyzer, nil, "sidecar")
+	require.NoError(t, err)
+	assert.Equal(t, opens("d"), second)
+
+	// The empty identifier is the shared opens trie AnalyzeOpens uses.
+	shared, err := dynamicpathdetector.AnalyzeOpensForIdentifier(opens("a", "b", "c"), analyzer, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, opens("a", "b", "c"), shared)
+	shared, err = dynamicpathdetector.AnalyzeOpens(opens("x"), analyzer, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{{Path: "/data/⋯", Flags: []string{"O_RDONLY"}}}, shared)
+}
+
+func TestAnalyzeOpensPartitionByAccess(t *testing.T) {
+	opens := []types.OpenCalls{{Path: "/log/a", Flags: []string{"O_WRONLY", "O_APPEND"}}}
+	for i := 0; i < 50; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/log/b%d", i), Flags: []string{"O_RDONLY"}})
+	}
+	const threshold = 10
+
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{{Path: "/log/⋯", Flags: []string{"O_APPEND", "O_RDONLY", "O_WRONLY"}}}, result, "without partitioning the write is folded into the reads")
+
+	result, err = dynamicpathdetector.AnalyzeOpensWithOptions(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), nil, dynamicpathdetector.AnalyzeOpensOpts{PartitionByAccess: true})
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/log/a", Flags: []string{"O_APPEND", "O_WRONLY"}},
+		{Path: "/log/⋯", Flags: []string{"O_RDONLY"}},
+	}, result)
+
+	// Writes collapse on their own count, and a path both read and
+	// written gets one entry per access, reads first.
+	opens = append(opens, types.OpenCalls{Path: "/log/b0", Flags: []string{"O_RDWR"}})
+	for i := 0; i < threshold; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/log/w%d", i), Flags: []string{"O_CREAT", "O_WRONLY"}})
+	}
+	result, err = dynamicpathdetector.AnalyzeOpensWithOptions(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), nil, dynamicpathdetector.AnalyzeOpensOpts{PartitionByAccess: true})
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/log/⋯", Flags: []string{"O_RDONLY"}},
+		{Path: "/log/⋯", Flags: []string{"O_APPEND", "O_CREAT", "O_RDWR", "O_WRONLY"}},
+	}, result)
+}
+
+func TestAnalyzeOpenWithIdentifier(t *testing.T) {
+	const threshold = 3
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	analyze := func(identifier string, names ...string) []string {
+		var out []string
+		for _, name := range names {
+			got, err := dynamicpathdetector.AnalyzeOpenWithIdentifier("/data/"+name, analyzer, identifier)
+			require.NoError(t, err)
+			out = append(out, got)
+		}
+		return out
+	}
+
+	analyze("reads", "a", "b", "c", "d")
+	analyze("writes", "a", "b")
+	assert.Equal(t, []string{"/data/⋯"}, analyze("reads", "e"), "reads exceeded the threshold")
+	assert.Equal(t, []string{"/data/a", "/data/c"}, analyze("writes", "a", "c"), "writes keep their own count")
+
+	// AnalyzeOpen and the empty identifier share the opens trie.
+	analyze("", "a", "b", "c", "d")
+	got, err := dynamicpathdetector.AnalyzeOpen("/data/e", analyzer)
+	require.NoError(t, err)
+	assert.Equal(t, "/data/⋯", got)
+}
+
+func TestAnalyzeOpensKeepCollapsedDirs(t *testing.T) {
+	const threshold = 3
+	var opens []types.OpenCalls
+	for i := 0; i <= threshold; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/usr/lib/lib%d.so", i), Flags: []string{"O_RDONLY"}})
+	}
+	opens = append(opens,
+		types.OpenCalls{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+		types.OpenCalls{Path: "/usr", Flags: []string{"O_DIRECTORY"}},
+	)
+
+	result, err := dynamicpathdetector.AnalyzeOpensWithOptions(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), nil, dynamicpathdetector.AnalyzeOpensOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+		{Path: "/usr", Flags: []string{"O_DIRECTORY"}},
+		{Path: "/usr/lib/⋯", Flags: []string{"O_RDONLY"}},
+	}, result, "the directory is dropped by default")
+
+	result, err = dynamicpathdetector.AnalyzeOpensWithOptions(opens, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), nil, dynamicpathdetector.AnalyzeOpensOpts{KeepCollapsedDirs: true})
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+		{Path: "/usr", Flags: []string{"O_DIRECTORY"}},
+		{Path: "/usr/lib"},
+		{Path: "/usr/lib/⋯", Flags: []string{"O_RDONLY"}},
+	}, result, "both the directory and its contents are kept")
+
+	// A directory that collapses at several levels is kept at the first.
+	var grid []types.OpenCalls
+	for i := 0; i <= threshold; i++ {
+		for j := 0; j <= threshold; j++ {
+			grid = append(grid, types.OpenCalls{Path: fmt.Sprintf("/srv/%d/%d/data", i, j), Flags: []string{"O_RDONLY"}})
+		}
+	}
+	result, err = dynamicpathdetector.AnalyzeOpensWithOptions(grid, dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil), nil, dynamicpathdetector.AnalyzeOpensOpts{KeepCollapsedDirs: true})
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/srv"},
+		{Path: "/srv/*/data", Flags: []string{"O_RDONLY"}},
+	}, result)
+}
@@ -50,6 +50,29 @@ func BenchmarkAnalyzePathWithDifferentLengths(b *testing.B) {
 	}
 }
 
+// BenchmarkAnalyzePathMillion inserts 1M paths per iteration, split into
+// containers of 1000 paths with the analyzer Reset in between, as a
+// reused per-container analyzer would see them. Run with -benchmem: one
+// alloc per path is the returned string, the rest are trie nodes.
+func BenchmarkAnalyzePathMillion(b *testing.B) {
+	const perContainer = 1000
+	paths := generateMixedPaths(1_000_000, 0)
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, path := range paths {
+			if j%perContainer == 0 {
+				analyzer.Reset()
+			}
+			if _, err := analyzer.AnalyzePath(path, "opens"); err != nil {
+				b.Fatalf("Error analyzing path: %v", err)
+			}
+		}
+	}
+}
+
 func BenchmarkAnalyzeOpensVsDeflateStringer(b *testing.B) {
 	paths := pathsToOpens(generateMixedPaths(10000, 0))
 	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
@@ -72,6 +95,40 @@ func BenchmarkAnalyzeOpensVsDeflateStringer(b *testing.B) {
 	})
 }
 
+// BenchmarkAnalyzeOpensPerContainer compares building a fresh analyzer for
+// every container (what the deflate path does today) against reusing one
+// analyzer and calling Reset between containers. Run with -benchmem.
+func BenchmarkAnalyzeOpensPerContainer(b *testing.B) {
+	const containers = 24
+	perContainer := make([][]types.OpenCalls, containers)
+	for c := range perContainer {
+		perContainer[c] = pathsToOpens(generateMixedPaths(200, 0))
+	}
+
+	b.Run("NewPerContainer", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, opens := range perContainer {
+				analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs())
+				_, _ = dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+			}
+		}
+	})
+
+	b.Run("ResetPerContainer", func(b *testing.B) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs())
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, opens := range perContainer {
+				analyzer.Reset()
+				_, _ = dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+			}
+		}
+	})
+}
+
 // BenchmarkCompareDynamic exercises the matcher under realistic shapes.
 // Run with -benchmem; the goal is the zero-alloc target Matthias called
 // out on upstream PR #316. Until the perf rewrite lands the numbers
@@ -111,6 +168,37 @@ func BenchmarkCompareDynamic(b *testing.B) {
 	}
 }
 
+// BenchmarkCompiledPatternVsCompareDynamic matches a batch of runtime
+// paths against one fixed pattern, re-parsing it every call
+// (CompareDynamic) versus compiling it once (CompiledPattern.Matches).
+func BenchmarkCompiledPatternVsCompareDynamic(b *testing.B) {
+	const pattern = "/api/\u22ef/users/\u22ef/*"
+	paths := make([]string, 1000)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/api/v%d/users/%d/posts/%d", i%3, i, i%7)
+	}
+
+	b.Run("CompareDynamic", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = dynamicpathdetector.CompareDynamic(pattern, paths[i%len(paths)])
+		}
+	})
+
+	b.Run("CompiledPattern", func(b *testing.B) {
+		compiled, err := dynamicpathdetector.CompilePattern(pattern)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = compiled.Matches(paths[i%len(paths)])
+		}
+	})
+}
+
 func generateMixedPaths(count int, fixedLength int) []string {
 	paths := make([]string, count)
 	staticSegments := []string{"users", "profile", "settings", "api", "v1", "posts", "organizations", "departments", "employees", "projects", "tasks", "categories", "subcategories", "items", "articles"}
@@ -177,3 +265,65 @@ func pathsToOpens(paths []string) []types.OpenCalls {
 	}
 	return opens
 }
+
+// BenchmarkAddPathsVsAnalyzePath trains an analyzer on 100k paths under
+// /usr/lib with the default configs, once through AnalyzePath per path
+// and once through AddPaths.
+func BenchmarkAddPathsVsAnalyzePath(b *testing.B) {
+	paths := make([]string, 100_000)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/usr/lib/python3/dist-packages/pkg%d/module%d.py", i/100, i%100)
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs())
+
+	b.Run("AnalyzePath", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			analyzer.Reset()
+			for _, p := range paths {
+				_, _ = analyzer.AnalyzePath(p, "opens")
+			}
+		}
+	})
+
+	b.Run("AddPaths", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			analyzer.Reset()
+			analyzer.AddPaths(paths, "opens")
+		}
+	})
+}
+
+// BenchmarkMatchAnyVsCompareDynamic matches runtime paths against 1000
+// stored patterns, calling CompareDynamic for each pattern in turn versus
+// one lookup in a compiled PatternSet.
+func BenchmarkMatchAnyVsCompareDynamic(b *testing.B) {
+	patterns := manyPatterns(1000)
+	paths := manyPatternPaths()
+
+	b.Run("CompareDynamic", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p := paths[i%len(paths)]
+			for _, pattern := range patterns {
+				if dynamicpathdetector.CompareDynamic(pattern, p) {
+					break
+				}
+			}
+		}
+	})
+
+	b.Run("PatternSet", func(b *testing.B) {
+		set, err := dynamicpathdetector.CompilePatterns(patterns)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = set.Match(paths[i%len(paths)])
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adjacentGridPaths walks /data/{i}/{j}/{k}/file wide enough for every
+// level below /data to collapse, so the trie ends up with a ⋯/⋯/⋯ chain.
+func adjacentGridPaths(width int) []string {
+	var paths []string
+	for i := 0; i < width; i++ {
+		for j := 0; j < width; j++ {
+			for k := 0; k < width; k++ {
+				paths = append(paths, fmt.Sprintf("/data/%d/%d/%d/file", i, j, k))
+			}
+		}
+	}
+	return paths
+}
+
+func countNodes(node *dynamicpathdetector.SegmentNode) int {
+	n := 1
+	for _, child := range node.Children {
+		n += countNodes(child)
+	}
+	return n
+}
+
+func TestCollapseAdjacentMatchesUnmergedOutput(t *testing.T) {
+	var siblings []string
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 5; j++ {
+			siblings = append(siblings, fmt.Sprintf("/mix/a/%d/%d/f", i, j))
+		}
+		siblings = append(siblings, fmt.Sprintf("/mix/b/%d/f", i))
+	}
+	// Collapsing /mix merges the ⋯/⋯ run under a with the single ⋯ under b.
+	siblings = append(siblings, "/mix/c", "/mix/d", "/mix/e", "/mix/a/1/2/f", "/mix/b/1/f")
+
+	paths := append(append(adjacentGridPaths(5), siblings...),
+		"/data/1/2/3/file",
+		"/data/7/8/9/other",
+		"/data/⋯/⋯/⋯/file",
+		"/data/1/2",       // ends inside the merged run
+		"/data/3/*/x",     // branches off inside the merged run
+		"/data/4/5/6/7/8", // continues past it
+	)
+
+	for _, maxDepth := range []int{0, 3} {
+		t.Run(fmt.Sprintf("MaxDepth-%d", maxDepth), func(t *testing.T) {
+			plain := dynamicpathdetector.NewPathAnalyzerWithMaxDepth(3, nil, maxDepth)
+			merged := dynamicpathdetector.NewPathAnalyzerWithMaxDepth(3, nil, maxDepth)
+			merged.CollapseAdjacent = true
+
+			for _, p := range paths {
+				want, err := plain.AnalyzePath(p, "opens")
+				require.NoError(t, err)
+				got, err := merged.AnalyzePath(p, "opens")
+				require.NoError(t, err)
+				assert.Equal(t, want, got, p)
+			}
+			assert.Equal(t, plain.GetStoredPaths("opens"), merged.GetStoredPaths("opens"))
+			for _, p := range []string{"/data/1/2/3/file", "/data/1", "/data/1/2/3/4/5", "/mix/x/y/f", "/other"} {
+				want, err := plain.PeekPath(p, "opens")
+				require.NoError(t, err)
+				got, err := merged.PeekPath(p, "opens")
+				require.NoError(t, err)
+				assert.Equal(t, want, got, p)
+			}
+		})
+	}
+}
+
+func TestCollapseAdjacentShrinksTrie(t *testing.T) {
+	plain := dynamicpathdetector.NewPathAnalyzer(3)
+	merged := dynamicpathdetector.NewPathAnalyzer(3)
+	merged.CollapseAdjacent = true
+	for _, p := range adjacentGridPaths(5) {
+		want, err := plain.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		got, err := merged.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+
+	assert.Equal(t, []string{"/data/⋯/⋯/⋯/file"}, merged.GetStoredPaths("opens"))
+	assert.Equal(t, map[string]int{"/data": 4, "/data/⋯/⋯/⋯": 1, "/data/⋯/⋯/⋯/file": 0}, merged.GetStoredPathsWithCounts("opens"))
+	assert.Less(t, len(merged.GetStoredPathsWithCounts("opens")), len(plain.GetStoredPathsWithCounts("opens")))
+	assert.Equal(t, countNodes(plain.RootNodes["opens"])-2, countNodes(merged.RootNodes["opens"]),
+		"the ⋯/⋯/⋯ chain is held by one node")
+}
+
+func TestCollapseAdjacentSurvivesJSONRoundTrip(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(3)
+	analyzer.CollapseAdjacent = true
+	for _, p := range adjacentGridPaths(5) {
+		_, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+
+	data, err := analyzer.MarshalJSON()
+	require.NoError(t, err)
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, restored.UnmarshalJSON(data))
+
+	assert.True(t, restored.CollapseAdjacent)
+	assert.Equal(t, analyzer.GetStoredPaths("opens"), restored.GetStoredPaths("opens"))
+	result, err := restored.AnalyzePath("/data/9/9/9/file", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/*/file", result)
+}
@@ -0,0 +1,95 @@
+package dynamicpathdetectortests
+
+import (
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrimCommonPrefix(t *testing.T) {
+	open := func(p string) types.OpenCalls { return types.OpenCalls{Path: p, Flags: []string{"O_RDONLY"}} }
+	opens := func(paths ...string) []types.OpenCalls {
+		var out []types.OpenCalls
+		for _, p := range paths {
+			out = append(out, open(p))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name       string
+		opens      []types.OpenCalls
+		wantPrefix string
+		wantPaths  []string
+	}{
+		{
+			name:       "shared tree",
+			opens:      opens("/opt/myapp/bin/run", "/opt/myapp/conf/app.yaml", "/opt/myapp/lib/⋯"),
+			wantPrefix: "/opt/myapp",
+			wantPaths:  []string{"/bin/run", "/conf/app.yaml", "/lib/⋯"},
+		},
+		{
+			name:       "single path keeps its name",
+			opens:      opens("/opt/myapp/bin/run"),
+			wantPrefix: "/opt/myapp/bin",
+			wantPaths:  []string{"/run"},
+		},
+		{
+			name:       "prefix ends at a path boundary",
+			opens:      opens("/opt/myapp/a", "/opt/myapplication/b"),
+			wantPrefix: "/opt",
+			wantPaths:  []string{"/myapp/a", "/myapplication/b"},
+		},
+		{
+			name:       "a path that is the common directory itself",
+			opens:      opens("/opt/myapp", "/opt/myapp/conf"),
+			wantPrefix: "/opt",
+			wantPaths:  []string{"/myapp", "/myapp/conf"},
+		},
+		{
+			name:       "directory opens with a trailing slash",
+			opens:      opens("/data/cache/", "/data/cache/x"),
+			wantPrefix: "/data/cache",
+			wantPaths:  []string{"/", "/x"},
+		},
+		{
+			name:       "no common prefix",
+			opens:      opens("/etc/passwd", "/usr/bin/ls"),
+			wantPrefix: "",
+			wantPaths:  []string{"/etc/passwd", "/usr/bin/ls"},
+		},
+		{
+			name:       "top-level files",
+			opens:      opens("/a", "/b"),
+			wantPrefix: "",
+			wantPaths:  []string{"/a", "/b"},
+		},
+		{
+			name:       "relative path",
+			opens:      opens("/opt/myapp/a", "opt/myapp/b"),
+			wantPrefix: "",
+			wantPaths:  []string{"/opt/myapp/a", "opt/myapp/b"},
+		},
+		{
+			name:       "empty",
+			wantPrefix: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := append([]types.OpenCalls(nil), tt.opens...)
+			prefix, trimmed := dynamicpathdetector.TrimCommonPrefix(tt.opens)
+			assert.Equal(t, tt.wantPrefix, prefix)
+			var paths []string
+			for _, o := range trimmed {
+				paths = append(paths, o.Path)
+				assert.Equal(t, []string{"O_RDONLY"}, o.Flags)
+			}
+			assert.Equal(t, tt.wantPaths, paths)
+			assert.Equal(t, before, tt.opens, "input must not be modified")
+			assert.Equal(t, tt.opens, dynamicpathdetector.RestoreCommonPrefix(prefix, trimmed), "restore must round-trip")
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAnalyzePathConcurrent inserts distinct paths from many goroutines,
+// with readers running alongside, and checks the trie ends up holding
+// exactly the paths a sequential run stores. Run with -race.
+func TestAnalyzePathConcurrent(t *testing.T) {
+	const (
+		writers   = 16
+		perWriter = 20 // below OpenDynamicThreshold, so nothing collapses
+	)
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+
+	var want []string
+	for w := 0; w < writers; w++ {
+		for i := 0; i < perWriter; i++ {
+			want = append(want, fmt.Sprintf("/g%02d/file%02d", w, i))
+		}
+	}
+	slices.Sort(want)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				p := fmt.Sprintf("/g%02d/file%02d", w, i)
+				got, err := analyzer.AnalyzePath(p, "opens")
+				assert.NoError(t, err)
+				assert.Equal(t, p, got)
+				// A second identifier exercises root creation under contention.
+				_, _ = analyzer.AnalyzePath(p, fmt.Sprintf("id%d", w%4))
+			}
+		}(w)
+	}
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = analyzer.GetStoredPaths("opens")
+				_, _ = analyzer.PeekPath("/g00/file00", "opens")
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	require.Equal(t, want, analyzer.GetStoredPaths("opens"))
+	for w := 0; w < writers; w++ {
+		got, err := analyzer.AnalyzePath(fmt.Sprintf("/g%02d/file00", w), "opens")
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("/g%02d/file00", w), got, "state must be stable after the concurrent phase")
+	}
+}
@@ -1,6 +1,7 @@
 package dynamicpathdetectortests
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -243,6 +244,39 @@ func TestCollapseConfig(t *testing.T) {
 	assert.Equal(t, expected, result)
 }
 
+// TestPathAnalyzerReset checks that Reset forgets the learned tries but
+// keeps per-prefix thresholds, so a reused analyzer behaves like a fresh
+// one built with the same configs.
+func TestPathAnalyzerReset(t *testing.T) {
+	threshold := configThreshold("/opt")
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	for i := 0; i < threshold+1; i++ {
+		_, err := analyzer.AnalyzePath(fmt.Sprintf("/opt/plugin%d/lib.so", i), "opens")
+		require.NoError(t, err)
+	}
+	result, err := analyzer.AnalyzePath("/opt/plugin-new/lib.so", "opens")
+	require.NoError(t, err)
+	require.Equal(t, "/opt/\u22ef/lib.so", result)
+
+	analyzer.Reset()
+	fresh := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	assert.Equal(t, fresh.RootNodes, analyzer.RootNodes)
+	assert.Equal(t, fresh.FindConfigForPath("/opt/x"), analyzer.FindConfigForPath("/opt/x"))
+	assert.Equal(t, fresh.FindConfigForPath("/elsewhere"), analyzer.FindConfigForPath("/elsewhere"))
+
+	result, err = analyzer.AnalyzePath("/opt/plugin-new/lib.so", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/plugin-new/lib.so", result, "Reset must drop previously learned siblings")
+
+	for i := 0; i < threshold+1; i++ {
+		_, err := analyzer.AnalyzePath(fmt.Sprintf("/opt/other%d/lib.so", i), "opens")
+		require.NoError(t, err)
+	}
+	result, err = analyzer.AnalyzePath("/opt/other-new/lib.so", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/\u22ef/lib.so", result, "per-prefix threshold must survive Reset")
+}
+
 // TestProcessSegments_WildcardWiringRegressions pins three correctness
 // properties of processSegments that were broken in the zero-alloc rewrite
 // of analyzer.go and caused node-agent component-test Test_27
@@ -434,7 +468,7 @@ func TestCompareDynamic_WildcardRegressions(t *testing.T) {
 //   - Unanchored: a bare `*` (no leading slash) is the only way to
 //     allowlist the root path itself.
 //
-// Trailing slashes on the regular path are normalized away so
+// Trailing slashes on either path are normalized away so
 // `/etc/passwd/` is treated as `/etc/passwd`.
 func TestCompareDynamic_AnchoringAndTrailing(t *testing.T) {
 	tests := []struct {
@@ -472,6 +506,17 @@ func TestCompareDynamic_AnchoringAndTrailing(t *testing.T) {
 		{"double_trailing_matches_two_children", "/etc/*/*", "/etc/ssh/sshd_config", true},
 		{"double_trailing_matches_deep", "/etc/*/*", "/etc/ssh/dir/file", true},
 
+		// Trailing slashes on the pattern side, and on both sides.
+		{"pattern_trailing_slash_matches_plain", "/api/users/", "/api/users", true},
+		{"pattern_repeated_trailing_slashes_match_plain", "/api/users//", "/api/users", true},
+		{"both_trailing_slash_match", "/api/users/", "/api/users/", true},
+		{"ellipsis_pattern_trailing_slash_matches_child", "/api/users/⋯/", "/api/users/1", true},
+		{"ellipsis_matches_child_with_trailing_slashes", "/api/users/⋯", "/api/users/1//", true},
+		{"ellipsis_pattern_trailing_slash_does_not_match_parent", "/api/users/⋯/", "/api/users/", false},
+		{"star_pattern_trailing_slash_matches_child", "/api/users/*/", "/api/users/1", true},
+		{"star_pattern_trailing_slash_does_not_match_parent_slash", "/api/users/*/", "/api/users/", false},
+		{"mid_star_pattern_trailing_slash_matches_zero", "/api/*/x/", "/api/x/", true},
+
 		// Empty / bare-pattern edges.
 		{"empty_dynamic_does_not_match_path", "", "/foo", false},
 		{"empty_dynamic_does_not_match_root", "", "/", false},
@@ -572,6 +617,46 @@ func TestCompareDynamic_MidPathStarZeroOrMore(t *testing.T) {
 	}
 }
 
+// TestCompareDynamic_SingleCharWildcard pins `?` as a one-character
+// wildcard inside a static segment. It never spans a `/` and never
+// matches an empty character.
+func TestCompareDynamic_SingleCharWildcard(t *testing.T) {
+	tests := []struct {
+		name    string
+		dynamic string
+		regular string
+		want    bool
+	}{
+		{"tty_digit", "/dev/tty?", "/dev/tty0", true},
+		{"tty_two_digits", "/dev/tty?", "/dev/tty10", false},
+		{"tty_usb", "/dev/tty?", "/dev/ttyUSB", false},
+		{"tty_missing_char", "/dev/tty?", "/dev/tty", false},
+		{"multibyte_rune", "/dev/tty?", "/dev/tty\u00e9", true},
+		{"does_not_cross_slash", "/dev/tty?/x", "/dev/tty/x", false},
+		{"whole_segment", "/proc/?/status", "/proc/1/status", true},
+		{"whole_segment_too_long", "/proc/?/status", "/proc/12/status", false},
+		{"middle_of_segment", "/var/log/app.?.log", "/var/log/app.1.log", true},
+		{"two_wildcards", "/dev/sd??", "/dev/sda1", true},
+		{"with_ellipsis", "/dev/⋯/tty?", "/dev/pts/tty3", true},
+		{"with_trailing_star", "/dev/tty?/*", "/dev/tty1/a/b", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dynamicpathdetector.CompareDynamic(tt.dynamic, tt.regular)
+			assert.Equal(t, tt.want, got,
+				"CompareDynamic(%q, %q) = %v, want %v", tt.dynamic, tt.regular, got, tt.want)
+		})
+	}
+
+	compiled, err := dynamicpathdetector.CompilePattern("/dev/tty?")
+	require.NoError(t, err)
+	assert.True(t, compiled.Matches("/dev/tty0"))
+	assert.False(t, compiled.Matches("/dev/tty10"))
+	assert.True(t, dynamicpathdetector.CompareDynamicWithOptions("/Dev/TTY?", "/dev/tty0",
+		dynamicpathdetector.CompareDynamicOpts{CaseInsensitive: true}))
+}
+
 // TestDefaultCollapseConfigs_DefensiveCopy pins the contract that the
 // public DefaultCollapseConfigs() accessor returns a fresh slice on
 // every call, so callers cannot accidentally mutate the package-level
@@ -611,8 +696,9 @@ func TestDefaultCollapseConfigs_DefensiveCopy(t *testing.T) {
 
 // TestCompareDynamic_PathSeparatorEdges documents how `/`-related
 // edges are normalized: trailing slashes are insignificant, the
-// regular path `""` is treated as no-path (matches nothing), and the
-// internal split-and-trim normalization is exercised on both sides.
+// regular path `""` is treated as no-path (matches nothing), `.` and
+// `..` in the regular path are resolved, and the internal split-and-trim
+// normalization is exercised on both sides.
 func TestCompareDynamic_PathSeparatorEdges(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -635,6 +721,13 @@ func TestCompareDynamic_PathSeparatorEdges(t *testing.T) {
 
 		// Empty dynamic — matches nothing.
 		{"empty_dynamic_does_not_match_anything", "", "/foo", false},
+
+		// Unnormalized regular paths resolve `.` and `..` first.
+		{"dotdot_in_regular_resolves", "/a/c", "/a/b/../c", true},
+		{"dot_in_regular_resolves", "/x", "/./x", true},
+		{"dotdot_at_root_is_clamped", "/etc/passwd", "/../etc/passwd", true},
+		{"dotdot_cannot_escape_ellipsis", "/home/⋯/file", "/home/a/../file", false},
+		{"double_slash_in_regular", "/etc/passwd", "//etc//passwd", true},
 	}
 
 	for _, tt := range tests {
@@ -645,3 +738,238 @@ func TestCompareDynamic_PathSeparatorEdges(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"/a/b/../c", "/a/c"},
+		{"/./x", "/x"},
+		{"/../etc/passwd", "/etc/passwd"},
+		{"/", "/"},
+		{"", ""},
+		{"a/../b", "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.want, dynamicpathdetector.NormalizePath(tt.in))
+		})
+	}
+}
+
+// TestAnalyzePathNormalizesDotSegments checks that unnormalized events are
+// stored under their canonical path rather than literally.
+func TestAnalyzePathNormalizesDotSegments(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+	for in, want := range map[string]string{
+		"/a/b/../c":      "/a/c",
+		"/./x":           "/x",
+		"/../etc/passwd": "/etc/passwd",
+	} {
+		got, err := analyzer.AnalyzePath(in, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, in)
+	}
+	assert.ElementsMatch(t, []string{"/a/c", "/x", "/etc/passwd"}, analyzer.GetStoredPaths("opens"))
+
+	compiled, err := dynamicpathdetector.CompilePattern("/a/c")
+	require.NoError(t, err)
+	assert.True(t, compiled.Matches("/a/b/../c"))
+}
+
+// TestCompareDynamicWithOptions_CaseInsensitive covers mixed-case paths
+// from case-insensitive filesystems. Only static segments fold case;
+// ⋯ and * behave exactly as in CompareDynamic, and the zero-value opts
+// stay case-sensitive.
+func TestCompareDynamicWithOptions_CaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name    string
+		dynamic string
+		regular string
+		opts    dynamicpathdetector.CompareDynamicOpts
+		want    bool
+	}{
+		{"default_is_case_sensitive", "/Users/Foo", "/users/foo", dynamicpathdetector.CompareDynamicOpts{}, false},
+		{"literal_mixed_case", "/Users/Foo", "/users/foo", dynamicpathdetector.CompareDynamicOpts{CaseInsensitive: true}, true},
+		{"literal_upper_regular", "/users/foo/desktop.ini", "/USERS/FOO/DESKTOP.INI", dynamicpathdetector.CompareDynamicOpts{CaseInsensitive: true}, true},
+		{"literal_different_name", "/Users/Foo", "/users/bar", dynamicpathdetector.CompareDynamicOpts{CaseInsensitive: true}, false},
+		{"ellipsis_mixed_case", "/Users/⋯/AppData", "/users/Bob/appdata", dynamicpathdetector.CompareDynamicOpts{CaseInsensitive: true}, true},
+		{"ellipsis_still_single_segment", "/Users/⋯/AppData", "/users/bob/x/appdata", dynamicpathdetector.CompareDynamicOpts{CaseInsensitive: true}, false},
+		{"mid_star_mixed_case", "/Windows/*/Drivers", "/windows/system32/drivers", dynamicpathdetector.CompareDynamicOpts{CaseInsensitive: true}, true},
+		{"trailing_star_not_parent", "/Windows/*", "/windows", dynamicpathdetector.CompareDynamicOpts{CaseInsensitive: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dynamicpathdetector.CompareDynamicWithOptions(tt.dynamic, tt.regular, tt.opts)
+			assert.Equal(t, tt.want, got,
+				"CompareDynamicWithOptions(%q, %q, %+v) = %v, want %v", tt.dynamic, tt.regular, tt.opts, got, tt.want)
+		})
+	}
+	assert.False(t, dynamicpathdetector.CompareDynamic("/Users/Foo", "/users/foo"),
+		"CompareDynamic must remain case-sensitive")
+}
+
+// TestNewPathAnalyzerWithIdentifiers checks that an analyzer built with
+// ASCII identifiers uses them for collapse, adjacent-dynamic squashing,
+// input recognition and matching, while NewPathAnalyzer keeps ⋯ and *.
+func TestNewPathAnalyzerWithIdentifiers(t *testing.T) {
+	threshold := 3
+
+	t.Run("defaults unchanged", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(threshold)
+		assert.Equal(t, dynamicpathdetector.DynamicIdentifier, analyzer.DynamicIdentifier())
+		assert.Equal(t, dynamicpathdetector.WildcardIdentifier, analyzer.WildcardIdentifier())
+		empty := dynamicpathdetector.NewPathAnalyzerWithIdentifiers(threshold, "", "")
+		assert.Equal(t, dynamicpathdetector.DynamicIdentifier, empty.DynamicIdentifier())
+		assert.Equal(t, dynamicpathdetector.WildcardIdentifier, empty.WildcardIdentifier())
+	})
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithIdentifiers(threshold, "{dyn}", "{any}")
+
+	for i := 0; i < threshold+1; i++ {
+		_, err := analyzer.AnalyzePath(fmt.Sprintf("/api/users/%d", i), "api")
+		require.NoError(t, err)
+	}
+	result, err := analyzer.AnalyzePath("/api/users/new", "api")
+	require.NoError(t, err)
+	assert.Equal(t, "/api/users/{dyn}", result)
+
+	result, err = analyzer.AnalyzePath("/api/users/{dyn}", "api")
+	require.NoError(t, err)
+	assert.Equal(t, "/api/users/{dyn}", result, "configured identifier in input is recognised")
+
+	result, err = analyzer.AnalyzePath("/grid/{dyn}/{dyn}/leaf", "grid")
+	require.NoError(t, err)
+	assert.Equal(t, "/grid/{any}/leaf", result, "adjacent dynamic identifiers squash into the configured wildcard")
+
+	result, err = analyzer.AnalyzePath("/api/users/⋯", "plain")
+	require.NoError(t, err)
+	assert.Equal(t, "/api/users/⋯", result, "the default identifier is an ordinary segment here")
+
+	assert.True(t, analyzer.CompareDynamic("/api/users/{dyn}", "/api/users/42"))
+	assert.True(t, analyzer.CompareDynamic("/grid/{any}/leaf", "/grid/a/b/leaf"))
+	assert.False(t, analyzer.CompareDynamic("/api/users/⋯", "/api/users/42"))
+	assert.False(t, dynamicpathdetector.CompareDynamic("/api/users/{dyn}", "/api/users/42"),
+		"package-level CompareDynamic keeps the default identifiers")
+
+	t.Run("long wildcard", func(t *testing.T) {
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithIdentifiers(threshold, "D", "ANYTHING")
+		result, err := analyzer.AnalyzePath("/a/D/D/D/b/D", "x")
+		require.NoError(t, err)
+		assert.Equal(t, "/a/ANYTHING/b/D", result)
+	})
+}
+
+// TestCompilePattern checks that a compiled pattern agrees with
+// CompareDynamic on the same inputs the CompareDynamic tests use.
+func TestCompilePattern(t *testing.T) {
+	_, err := dynamicpathdetector.CompilePattern("")
+	assert.ErrorIs(t, err, dynamicpathdetector.ErrEmptyPattern)
+
+	patterns := []string{
+		"/api/users/⋯", "/api/⋯/⋯", "/etc/*", "/a/*/b", "*", "/*", "/var/log/syslog", "/etc/",
+	}
+	paths := []string{
+		"/api/users/123", "/api/users/123/posts", "/api/users", "/etc", "/etc/passwd",
+		"/etc/ssh/sshd_config", "/a/b", "/a/x/y/b", "/", "", "/var/log/syslog", "/var/log/messages",
+	}
+	for _, pattern := range patterns {
+		compiled, err := dynamicpathdetector.CompilePattern(pattern)
+		require.NoError(t, err)
+		assert.Equal(t, pattern, compiled.String())
+		for _, p := range paths {
+			assert.Equal(t, dynamicpathdetector.CompareDynamic(pattern, p), compiled.Matches(p),
+				"CompilePattern(%q).Matches(%q)", pattern, p)
+		}
+	}
+}
+
+func TestCompilePatternWithOptions(t *testing.T) {
+	opts := dynamicpathdetector.CompareDynamicOpts{DynamicIdentifier: "{d}", WildcardIdentifier: "**", CaseInsensitive: true}
+	for _, pattern := range []string{"/api/users/{d}", "/etc/**", "/a/**/b", "/api/users/⋯", "/etc/*"} {
+		compiled, err := dynamicpathdetector.CompilePatternWithOptions(pattern, opts)
+		require.NoError(t, err)
+		for _, p := range []string{"/api/users/123", "/API/Users/1", "/api/users/⋯", "/etc/passwd", "/etc/*", "/a/x/y/b", "/a/b"} {
+			assert.Equal(t, dynamicpathdetector.CompareDynamicWithOptions(pattern, p, opts), compiled.Matches(p),
+				"CompilePatternWithOptions(%q).Matches(%q)", pattern, p)
+		}
+	}
+}
+
+func TestNewPathAnalyzerWithIdentifierConfigs(t *testing.T) {
+	const defaultThreshold = 3
+	identifierConfigs := map[string][]dynamicpathdetector.CollapseConfig{
+		"443":  {{Prefix: "/", Threshold: 5}},
+		"8080": {{Prefix: "/", Threshold: 2}},
+	}
+	newAnalyzer := func() *dynamicpathdetector.PathAnalyzer {
+		return dynamicpathdetector.NewPathAnalyzerWithIdentifierConfigs(defaultThreshold, identifierConfigs)
+	}
+	// analyze walks cardinality distinct /users/<n> paths, then returns
+	// what one more path becomes.
+	analyze := func(analyzer *dynamicpathdetector.PathAnalyzer, identifier string, cardinality int) string {
+		for i := 0; i < cardinality; i++ {
+			_, err := analyzer.AnalyzePath(fmt.Sprintf("/users/%d", i), identifier)
+			require.NoError(t, err)
+		}
+		result, err := analyzer.AnalyzePath("/users/new", identifier)
+		require.NoError(t, err)
+		return result
+	}
+
+	analyzer := newAnalyzer()
+	assert.Equal(t, "/users/⋯", analyze(analyzer, "8080", 3), "8080 collapses past 2 children")
+	assert.Equal(t, "/users/new", analyze(analyzer, "443", 5), "443 keeps 5 children")
+	assert.Equal(t, "/users/⋯", analyze(analyzer, "9090", 4), "unlisted identifiers use the default")
+
+	copied := newAnalyzer()
+	identifierConfigs["443"][0].Threshold = 2
+	assert.Equal(t, "/users/new", analyze(copied, "443", 5), "the analyzer holds its own copy of the configs")
+	identifierConfigs["443"][0].Threshold = 5
+
+	original := newAnalyzer()
+	analyze(original, "443", 3)
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, json.Unmarshal(data, restored))
+	result, err := restored.AnalyzePath("/users/other", "443")
+	require.NoError(t, err)
+	assert.Equal(t, "/users/other", result, "per-identifier configs survive a JSON round-trip")
+}
+
+// TestCompareDynamicWithOptions_NonEmptyWildcard distinguishes the default
+// zero-or-more mid-path * from NonEmptyWildcard, where every * consumes
+// at least one segment. Trailing * is one-or-more in both modes.
+func TestCompareDynamicWithOptions_NonEmptyWildcard(t *testing.T) {
+	nonEmpty := dynamicpathdetector.CompareDynamicOpts{NonEmptyWildcard: true}
+	tests := []struct {
+		name    string
+		dynamic string
+		regular string
+		opts    dynamicpathdetector.CompareDynamicOpts
+		want    bool
+	}{
+		{"default_mid_star_matches_zero", "/a/*/c", "/a/c", dynamicpathdetector.CompareDynamicOpts{}, true},
+		{"default_mid_star_matches_one", "/a/*/c", "/a/b/c", dynamicpathdetector.CompareDynamicOpts{}, true},
+		{"non_empty_mid_star_rejects_zero", "/a/*/c", "/a/c", nonEmpty, false},
+		{"non_empty_mid_star_matches_one", "/a/*/c", "/a/b/c", nonEmpty, true},
+		{"non_empty_mid_star_matches_many", "/a/*/c", "/a/b/d/c", nonEmpty, true},
+		{"non_empty_leading_star_rejects_zero", "/*/foo", "/foo", nonEmpty, false},
+		{"non_empty_consecutive_stars_need_two", "/*/*", "/foo", nonEmpty, false},
+		{"non_empty_consecutive_stars_match_two", "/*/*", "/foo/bar", nonEmpty, true},
+		{"non_empty_trailing_star_not_parent", "/etc/*", "/etc", nonEmpty, false},
+		{"non_empty_trailing_star_matches_child", "/etc/*", "/etc/passwd", nonEmpty, true},
+		{"non_empty_unanchored_star_matches_root", "*", "/", nonEmpty, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dynamicpathdetector.CompareDynamicWithOptions(tt.dynamic, tt.regular, tt.opts)
+			assert.Equal(t, tt.want, got,
+				"CompareDynamicWithOptions(%q, %q, %+v) = %v, want %v", tt.dynamic, tt.regular, tt.opts, got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package dynamicpathdetectortests
+
+import (
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffOpens(t *testing.T) {
+	old := []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+		{Path: "/etc/passwd", Flags: []string{"O_RDONLY", "O_CLOEXEC"}},
+		{Path: "/tmp/scratch", Flags: []string{"O_RDWR"}},
+		{Path: "/app/plugins/a.so", Flags: []string{"O_RDONLY"}},
+		{Path: "/app/plugins/b.so", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/cache/x", Flags: []string{"O_RDONLY"}},
+	}
+	updated := []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY", "O_WRONLY"}},
+		{Path: "/etc/passwd", Flags: []string{"O_CLOEXEC", "O_RDONLY"}},
+		{Path: "/app/plugins/⋯", Flags: []string{"O_RDONLY"}},
+		{Path: "/var/*", Flags: []string{"O_RDONLY"}},
+		{Path: "/usr/lib/libc.so.6", Flags: []string{"O_RDONLY"}},
+	}
+
+	t.Run("detailed", func(t *testing.T) {
+		diff := dynamicpathdetector.DiffOpensDetailed(old, updated)
+		assert.Equal(t, []types.OpenCalls{
+			{Path: "/app/plugins/⋯", Flags: []string{"O_RDONLY"}},
+			{Path: "/usr/lib/libc.so.6", Flags: []string{"O_RDONLY"}},
+			{Path: "/var/*", Flags: []string{"O_RDONLY"}},
+		}, diff.Added)
+		assert.Equal(t, []types.OpenCalls{
+			{Path: "/tmp/scratch", Flags: []string{"O_RDWR"}},
+		}, diff.Removed)
+		assert.Equal(t, []types.OpenCalls{
+			{Path: "/app/plugins/a.so", Flags: []string{"O_RDONLY"}},
+			{Path: "/app/plugins/b.so", Flags: []string{"O_RDONLY"}},
+			{Path: "/var/cache/x", Flags: []string{"O_RDONLY"}},
+		}, diff.Covered)
+		assert.Equal(t, []types.OpenCalls{
+			{Path: "/etc/hosts", Flags: []string{"O_RDONLY", "O_WRONLY"}},
+		}, diff.FlagChanged, "flag order alone is not a change")
+	})
+
+	t.Run("flat", func(t *testing.T) {
+		added, removed, flagChanged := dynamicpathdetector.DiffOpens(old, updated)
+		assert.Equal(t, []string{"/app/plugins/⋯", "/usr/lib/libc.so.6", "/var/*"}, pathsFromResult(added))
+		assert.Equal(t, []string{"/app/plugins/a.so", "/app/plugins/b.so", "/tmp/scratch", "/var/cache/x"}, pathsFromResult(removed),
+			"covered paths are reported as removed")
+		assert.Equal(t, []string{"/etc/hosts"}, pathsFromResult(flagChanged))
+	})
+}
+
+func TestDiffOpensMergesDuplicates(t *testing.T) {
+	old := []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+		{Path: "/etc/hosts", Flags: []string{"O_CLOEXEC"}},
+	}
+	updated := []types.OpenCalls{
+		{Path: "/etc/hosts", Flags: []string{"O_CLOEXEC", "O_RDONLY"}},
+	}
+	added, removed, flagChanged := dynamicpathdetector.DiffOpens(old, updated)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+	assert.Empty(t, flagChanged)
+}
+
+func TestDiffOpensEmpty(t *testing.T) {
+	opens := []types.OpenCalls{{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}}}
+
+	added, removed, flagChanged := dynamicpathdetector.DiffOpens(nil, opens)
+	assert.Equal(t, opens, added)
+	assert.Empty(t, removed)
+	assert.Empty(t, flagChanged)
+
+	added, removed, flagChanged = dynamicpathdetector.DiffOpens(opens, nil)
+	assert.Empty(t, added)
+	assert.Equal(t, opens, removed)
+	assert.Empty(t, flagChanged)
+}
@@ -0,0 +1,64 @@
+package dynamicpathdetectortests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToDOT(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(3, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/etc", Threshold: 10},
+	})
+	for _, p := range []string{"/etc/hosts", "/etc/passwd"} {
+		_, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+	for i := 0; i < 5; i++ {
+		_, err := analyzer.AnalyzePath(fmt.Sprintf("/tmp/%d", i), "opens")
+		require.NoError(t, err)
+	}
+	_, err := analyzer.AnalyzePath("/tmp/x", "opens")
+	require.NoError(t, err)
+	_, err = analyzer.AnalyzePath("/api/*", "80")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, analyzer.ToDOT(&buf))
+	dot := buf.String()
+
+	assert.True(t, strings.HasPrefix(dot, "digraph PathAnalyzer {\n"))
+	assert.True(t, strings.HasSuffix(dot, "}\n"))
+	for _, want := range []string{
+		`label="default threshold=3";`,
+		`subgraph "cluster_80" {`,
+		`subgraph "cluster_opens" {`,
+		`[label="opens"];`,
+		`[label="etc\ncount=2 hits=2\nthreshold=10", color=red];`,
+		`[label="hosts\ncount=0 hits=1", peripheries=2];`,
+		`[label="⋯\ncount=0 hits=6", style=filled, fillcolor=lightblue, peripheries=2];`,
+		`[label="*\ncount=0 hits=1", style=filled, fillcolor=orange, peripheries=2];`,
+	} {
+		assert.Contains(t, dot, want)
+	}
+	assert.Less(t, strings.Index(dot, "cluster_80"), strings.Index(dot, "cluster_opens"), "identifiers are sorted")
+
+	var again bytes.Buffer
+	require.NoError(t, analyzer.ToDOT(&again))
+	assert.Equal(t, dot, again.String(), "output is stable")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestToDOTWriteError(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(3)
+	assert.EqualError(t, analyzer.ToDOT(failingWriter{}), "disk full")
+}
@@ -0,0 +1,92 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveThreshold(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/etc", Threshold: 100},
+		{Prefix: "/etc/apache2", Threshold: 50},
+		{Prefix: "/var/log", Threshold: 10, ExtensionThresholds: map[string]int{".log": 3}},
+		{Prefix: "/pinned", Threshold: dynamicpathdetector.NeverCollapse},
+	})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/etc/passwd", 100},
+		{"/etc/apache2/sites-enabled", 50},
+		{"/etc/apache2/sites-enabled/default.conf", 50},
+		{"/etc/apache2/../hosts", 100},
+		// The parent decides: /etc itself is a child of the root.
+		{"/etc", dynamicpathdetector.OpenDynamicThreshold},
+		{"/etcd/member", dynamicpathdetector.OpenDynamicThreshold},
+		{"/usr/lib/libc.so", dynamicpathdetector.OpenDynamicThreshold},
+		{"/var/log/app.log", 3},
+		{"/var/log/app.txt", 10},
+		{"/var/log/app/", 10},
+		{"/pinned/anything", dynamicpathdetector.NeverCollapse},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, analyzer.EffectiveThreshold(tt.path), tt.path)
+	}
+}
+
+func TestEffectiveThresholdMatchesCollapse(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(10, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/data", Threshold: 3},
+	})
+	threshold := analyzer.EffectiveThreshold("/data/file0")
+	require.Equal(t, 3, threshold)
+
+	// threshold+1 children only collapse on the next walk.
+	for i := range threshold + 1 {
+		_, err := analyzer.AnalyzePath(fmt.Sprintf("/data/file%d", i), "opens")
+		require.NoError(t, err)
+	}
+	got, err := analyzer.AnalyzePath("/data/file0", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/⋯", got)
+
+	for i := range threshold + 1 {
+		_, err := analyzer.AnalyzePath(fmt.Sprintf("/other/file%d", i), "opens")
+		require.NoError(t, err)
+	}
+	got, err = analyzer.AnalyzePath("/other/file0", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/other/file0", got, "default threshold %d", analyzer.EffectiveThreshold("/other/file0"))
+}
+
+func TestEffectiveThresholdForIdentifier(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithIdentifierConfigs(50, map[string][]dynamicpathdetector.CollapseConfig{
+		"opens":    {{Prefix: "/etc", Threshold: 5}},
+		"endpoint": {{Prefix: "/api", ThresholdPercent: 20}},
+	})
+	assert.Equal(t, 5, analyzer.EffectiveThreshold("/etc/x"))
+	assert.Equal(t, 5, analyzer.EffectiveThresholdFor("/etc/x", "opens"))
+	assert.Equal(t, 50, analyzer.EffectiveThresholdFor("/etc/x", "execs"), "an unlisted identifier gets no configs")
+
+	// AnalyzeOpens collapses at the threshold reported for opens.
+	var opens []types.OpenCalls
+	for i := range 6 {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/etc/f%d", i)})
+	}
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "/etc/⋯", result[0].Path)
+
+	// A ThresholdPercent resolves against the identifier's own total.
+	assert.Equal(t, dynamicpathdetector.NeverCollapse, analyzer.EffectiveThresholdFor("/api/users", "endpoint"))
+	analyzer.AddPathTotal("endpoint", 100)
+	assert.Equal(t, 20, analyzer.EffectiveThresholdFor("/api/users", "endpoint"))
+	assert.Equal(t, 50, analyzer.EffectiveThresholdFor("/api/users", "opens"))
+}
@@ -0,0 +1,60 @@
+package dynamicpathdetectortests
+
+import (
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAnalyzePathEmbeddedDynamicIdentifier pins how ⋯ in input paths is
+// read: as a whole segment it is the dynamic identifier, and inside a
+// longer segment it makes the whole segment dynamic rather than being
+// stored as a literal nothing could match.
+func TestAnalyzePathEmbeddedDynamicIdentifier(t *testing.T) {
+	tests := []struct {
+		name, path, want string
+	}{
+		{"exact", "/a/⋯/c", "/a/⋯/c"},
+		{"embedded", "/a/foo⋯bar/c", "/a/⋯/c"},
+		{"prefix", "/a/⋯bar/c", "/a/⋯/c"},
+		{"suffix", "/lib/libssl.so.⋯", "/lib/⋯"},
+		{"last segment", "/a/b/x⋯", "/a/b/⋯"},
+		{"horizontal ellipsis is literal", "/a/foo…bar/c", "/a/foo…bar/c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
+			peeked, err := analyzer.PeekPath(tt.path, "opens")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, peeked)
+			got, err := analyzer.AnalyzePath(tt.path, "opens")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, []string{tt.want}, analyzer.GetStoredPaths("opens"))
+		})
+	}
+}
+
+func TestAnalyzeOpensEmbeddedDynamicIdentifier(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
+	result, err := dynamicpathdetector.AnalyzeOpens([]types.OpenCalls{
+		{Path: "/app/cache-⋯/data", Flags: []string{"O_RDONLY"}},
+		{Path: "/app/cache-1/data", Flags: []string{"O_WRONLY"}},
+	}, analyzer, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/app/⋯/data", Flags: []string{"O_RDONLY", "O_WRONLY"}},
+	}, result, "the re-ingested entry absorbs its siblings")
+	assert.True(t, dynamicpathdetector.CompareDynamic(result[0].Path, "/app/cache-2/data"))
+
+	custom := dynamicpathdetector.NewPathAnalyzerWithIdentifiers(dynamicpathdetector.OpenDynamicThreshold, "DYN", "ALL")
+	got, err := custom.AnalyzePath("/a/fooDYNbar/c", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/a/DYN/c", got, "the analyzer's own identifier is recognised")
+	got, err = custom.AnalyzePath("/b/foo⋯bar/c", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/b/foo⋯bar/c", got, "other identifiers are plain characters")
+}
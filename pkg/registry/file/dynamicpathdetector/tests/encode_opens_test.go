@@ -0,0 +1,85 @@
+package dynamicpathdetectortests
+
+import (
+	"encoding/json"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collapsedProfile returns the AnalyzeOpens output for n realistic open
+// paths, with flags cycling through a few common combinations.
+func collapsedProfile(t *testing.T, n int) []types.OpenCalls {
+	t.Helper()
+	flags := [][]string{{"O_RDONLY"}, {"O_RDONLY", "O_CLOEXEC"}, {"O_WRONLY", "O_CREAT", "O_TRUNC"}}
+	paths := realisticOpenPaths(n)
+	opens := make([]types.OpenCalls, len(paths))
+	for i, p := range paths {
+		opens[i] = types.OpenCalls{Path: p, Flags: flags[i%len(flags)]}
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs())
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+	require.NoError(t, err)
+	return result
+}
+
+func TestOpensBinaryRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		opens []types.OpenCalls
+	}{
+		{name: "nil", opens: nil},
+		{name: "collapsed profile", opens: collapsedProfile(t, 2000)},
+		{name: "odd paths and flags", opens: []types.OpenCalls{
+			{Path: "/", Flags: []string{}},
+			{Path: "", Flags: nil},
+			{Path: "relative/path/", Flags: []string{"O_RDONLY", "O_RDONLY"}},
+			{Path: "//double//slash", Flags: []string{""}},
+			{Path: "/tmp/⋯/x/*", Flags: []string{"O_RDONLY"}},
+			{Path: "/\x00/\xff\xfe/\n", Flags: []string{"\x00"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := dynamicpathdetector.DecodeOpensBinary(dynamicpathdetector.EncodeOpensBinary(tt.opens))
+			require.NoError(t, err)
+			assert.Equal(t, tt.opens, decoded)
+		})
+	}
+}
+
+func TestOpensBinarySmallerThanJSON(t *testing.T) {
+	for _, n := range []int{500, 5000, 50_000} {
+		opens := collapsedProfile(t, n)
+		jsonData, err := json.Marshal(opens)
+		require.NoError(t, err)
+		binaryData := dynamicpathdetector.EncodeOpensBinary(opens)
+		t.Logf("%d paths: %d opens, JSON %d bytes, binary %d bytes", n, len(opens), len(jsonData), len(binaryData))
+		assert.Less(t, len(binaryData), len(jsonData)/2, "binary encoding of %d paths", n)
+	}
+}
+
+func TestDecodeOpensBinaryRejectsCorruptInput(t *testing.T) {
+	data := dynamicpathdetector.EncodeOpensBinary([]types.OpenCalls{
+		{Path: "/etc/passwd", Flags: []string{"O_RDONLY"}},
+		{Path: "/etc/hosts", Flags: []string{"O_RDONLY"}},
+	})
+
+	for i := range data {
+		_, err := dynamicpathdetector.DecodeOpensBinary(data[:i])
+		assert.Error(t, err, "truncated to %d bytes", i)
+	}
+	_, err := dynamicpathdetector.DecodeOpensBinary(append(data, 0))
+	assert.ErrorContains(t, err, "1 trailing bytes")
+	_, err = dynamicpathdetector.DecodeOpensBinary(append([]byte{2}, data[1:]...))
+	assert.ErrorContains(t, err, "unsupported version 2")
+	// One string, then one open whose only segment refers to string 5.
+	_, err = dynamicpathdetector.DecodeOpensBinary([]byte{1, 1, 1, 'a', 1, 1, 5, 0})
+	assert.ErrorContains(t, err, "string index 5 out of range")
+	// A table claiming far more strings than the input holds.
+	_, err = dynamicpathdetector.DecodeOpensBinary([]byte{1, 0xff, 0xff, 0xff, 0xff, 0x0f})
+	assert.ErrorContains(t, err, "exceeds")
+}
@@ -0,0 +1,74 @@
+package dynamicpathdetectortests
+
+import (
+	"path"
+	"slices"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+)
+
+func FuzzAnalyzePath(f *testing.F) {
+	for _, seed := range []string{
+		"/etc/passwd",
+		"/tmp/⋯",
+		"/tmp/a⋯b/c",
+		"/tmp/*/x",
+		"/a/⋯/⋯/b",
+		"/\xe2\x8b/\xaf",
+		"/\x00/\n/\xff",
+		"relative/path",
+		"",
+		"//",
+		"/a/./../b/",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, p string) {
+		analyzer := dynamicpathdetector.NewPathAnalyzer(2)
+		for _, sibling := range []string{"/tmp/x", "/tmp/y", "/tmp/z", p} {
+			if _, err := analyzer.AnalyzePath(sibling, "opens"); err != nil {
+				t.Fatalf("AnalyzePath(%q): %v", sibling, err)
+			}
+		}
+		peeked, err := analyzer.PeekPath(p, "opens")
+		if err != nil {
+			t.Fatalf("PeekPath(%q): %v", p, err)
+		}
+		result, err := analyzer.AnalyzePath(p, "opens")
+		if err != nil {
+			t.Fatalf("AnalyzePath(%q): %v", p, err)
+		}
+		if peeked != result {
+			t.Errorf("PeekPath(%q) = %q, but AnalyzePath gives %q", p, peeked, result)
+		}
+		if !analyzer.CompareDynamic(result, path.Clean(p)) {
+			t.Errorf("AnalyzePath(%q) = %q, which does not match it", p, result)
+		}
+
+		// Re-analyzing a stored profile may fold away entries another one
+		// already covers, but loses no path and settles after one round.
+		opens := []types.OpenCalls{{Path: "/tmp/x"}, {Path: "/tmp/y"}, {Path: "/tmp/z"}, {Path: p}}
+		stored := analyzeOpens(t, opens)
+		again := analyzeOpens(t, stored)
+		for _, open := range stored {
+			if !slices.ContainsFunc(again, func(o types.OpenCalls) bool { return o.Path == open.Path || analyzer.CompareDynamic(o.Path, open.Path) }) {
+				t.Errorf("re-analyzing %v gives %v, which drops %q", stored, again, open.Path)
+			}
+		}
+		if settled := analyzeOpens(t, again); !slices.EqualFunc(again, settled, func(a, b types.OpenCalls) bool { return a.String() == b.String() }) {
+			t.Errorf("AnalyzeOpens of %q does not settle: %v gives %v", p, again, settled)
+		}
+		_ = analyzer.GetStoredPaths("opens")
+	})
+}
+
+func analyzeOpens(t *testing.T, opens []types.OpenCalls) []types.OpenCalls {
+	t.Helper()
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, dynamicpathdetector.NewPathAnalyzer(2), nil)
+	if err != nil {
+		t.Fatalf("AnalyzeOpens: %v", err)
+	}
+	return result
+}
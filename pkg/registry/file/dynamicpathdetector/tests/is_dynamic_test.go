@@ -0,0 +1,56 @@
+package dynamicpathdetectortests
+
+import (
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDynamic(t *testing.T) {
+	for p, want := range map[string]bool{
+		"/a/*":              true,
+		"*":                 true,
+		"/a/⋯":              true,
+		"/a/⋯/b":            true,
+		"/proc/⋯/task/*":    true,
+		":443/users/⋯":      true,
+		"/a/b*c":            false,
+		"/a/*b":             false,
+		"/usr/lib/lib.so.⋯": false,
+		"/dev/tty?":         false,
+		"/etc/passwd":       false,
+		"/":                 false,
+		"":                  false,
+	} {
+		assert.Equal(t, want, dynamicpathdetector.IsDynamic(p), p)
+	}
+}
+
+func TestIsDynamicWithOptions(t *testing.T) {
+	opts := dynamicpathdetector.CompareDynamicOpts{DynamicIdentifier: "{d}", WildcardIdentifier: "**"}
+	for p, want := range map[string]bool{
+		"/a/**":      true,
+		"/a/{d}/b":   true,
+		"/a/*":       false,
+		"/a/⋯":       false,
+		"/a/x{d}":    false,
+		"/etc/hosts": false,
+	} {
+		assert.Equal(t, want, dynamicpathdetector.IsDynamicWithOptions(p, opts), p)
+	}
+}
+
+func TestIsDynamicMatchesAnalyzerOutput(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(3, nil)
+	for _, p := range []string{"/data/a/log", "/data/b/log", "/data/c/log", "/data/d/log", "/data/e/log", "/data/f/log", "/etc/hosts"} {
+		_, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+	stored := analyzer.GetStoredPaths("opens")
+	require.Equal(t, []string{"/data/⋯/log", "/etc/hosts"}, stored)
+	for _, p := range stored {
+		assert.Equal(t, p != "/etc/hosts", dynamicpathdetector.IsDynamic(p), p)
+	}
+}
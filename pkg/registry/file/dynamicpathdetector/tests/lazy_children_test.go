@@ -0,0 +1,113 @@
+package dynamicpathdetectortests
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nilLeafChildren sets every empty Children map below node to nil, the
+// state a leaf is in before its first child insert.
+func nilLeafChildren(node *dynamicpathdetector.SegmentNode) {
+	if len(node.Children) == 0 {
+		node.Children = nil
+		return
+	}
+	for _, child := range node.Children {
+		nilLeafChildren(child)
+	}
+}
+
+// TestNilChildrenTolerated runs the same inserts through an analyzer whose
+// leaves have nil Children and one whose leaves have empty maps, covering
+// growing a leaf into a directory, collapse, stored paths, Prune and the
+// JSON round-trip.
+func TestNilChildrenTolerated(t *testing.T) {
+	threshold := configThreshold("/opt")
+	lazy := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	eager := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+
+	first := []string{"/etc/passwd", "/usr/lib/libc.so", "/opt/plugin0/lib.so"}
+	analyzeAll(t, lazy, first...)
+	analyzeAll(t, eager, first...)
+	nilLeafChildren(lazy.RootNodes["opens"])
+
+	var more []string
+	more = append(more, "/etc/passwd/child", "/usr/lib/libc.so")
+	for i := 1; i < threshold+2; i++ {
+		more = append(more, fmt.Sprintf("/opt/plugin%d/lib.so", i))
+	}
+	for _, p := range more {
+		want, err := eager.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		got, err := lazy.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+	}
+	assert.Equal(t, eager.GetStoredPaths("opens"), lazy.GetStoredPaths("opens"))
+	assert.Equal(t, eager.GetStoredPathsWithCounts("opens"), lazy.GetStoredPathsWithCounts("opens"))
+
+	data, err := json.Marshal(lazy)
+	require.NoError(t, err)
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, lazy.GetStoredPaths("opens"), restored.GetStoredPaths("opens"))
+
+	eager.Prune(2)
+	lazy.Prune(2)
+	assert.Equal(t, eager.GetStoredPaths("opens"), lazy.GetStoredPaths("opens"))
+}
+
+// realisticOpenPaths returns n distinct open paths shaped like a container
+// profile: mostly leaves spread over a handful of deep directories.
+func realisticOpenPaths(n int) []string {
+	paths := make([]string, n)
+	for i := range paths {
+		switch i % 5 {
+		case 0:
+			paths[i] = fmt.Sprintf("/usr/lib/python3/dist-packages/pkg%d/module%d.py", i%40, i)
+		case 1:
+			paths[i] = fmt.Sprintf("/app/node_modules/dep%d/lib/file%d.js", i%45, i)
+		case 2:
+			paths[i] = fmt.Sprintf("/proc/%d/status", i)
+		case 3:
+			paths[i] = fmt.Sprintf("/etc/conf.d/section%d/entry%d.conf", i%30, i)
+		case 4:
+			paths[i] = fmt.Sprintf("/var/cache/app/%02x/%d.bin", i%48, i)
+		}
+	}
+	return paths
+}
+
+// BenchmarkTrieMemoryRealisticProfile reports the heap a 50k-path profile's
+// trie retains (trie-B) alongside the usual -benchmem allocation figures.
+func BenchmarkTrieMemoryRealisticProfile(b *testing.B) {
+	paths := realisticOpenPaths(50_000)
+	var retained uint64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs())
+		for _, p := range paths {
+			if _, err := analyzer.AnalyzePath(p, "opens"); err != nil {
+				b.Fatalf("Error analyzing path: %v", err)
+			}
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained += after.HeapAlloc - min(before.HeapAlloc, after.HeapAlloc)
+		runtime.KeepAlive(analyzer)
+	}
+	b.ReportMetric(float64(retained)/float64(b.N), "trie-B")
+}
@@ -0,0 +1,132 @@
+package dynamicpathdetectortests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deepPath(segment string, n int) string {
+	return "/" + strings.Repeat(segment+"/", n-1) + segment
+}
+
+func trieDepth(node *dynamicpathdetector.SegmentNode) int {
+	deepest := 0
+	for _, child := range node.Children {
+		deepest = max(deepest, trieDepth(child))
+	}
+	return deepest + 1
+}
+
+func TestMaxDepth_BoundsAdversarialPath(t *testing.T) {
+	const maxDepth = 20
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithMaxDepth(dynamicpathdetector.OpenDynamicThreshold, nil, maxDepth)
+
+	got, err := analyzer.AnalyzePath(deepPath("x", 10000), "opens")
+	require.NoError(t, err)
+	assert.Equal(t, deepPath("x", maxDepth)+"/*", got)
+
+	got, err = analyzer.AnalyzePath(deepPath("x", maxDepth)+"/other/tail", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, deepPath("x", maxDepth)+"/*", got, "a different deep tail folds into the same *")
+
+	// opens root, the "" anchor, maxDepth components and the * leaf.
+	assert.Equal(t, maxDepth+3, trieDepth(analyzer.RootNodes["opens"]))
+	assert.Equal(t, []string{deepPath("x", maxDepth) + "/*"}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestMaxDepth_ShallowPathsUnchanged(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithMaxDepth(dynamicpathdetector.OpenDynamicThreshold, nil, 3)
+	for _, p := range []string{"/", "/a", "/a/b", "/a/b/c"} {
+		got, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, p, got)
+	}
+	got, err := analyzer.AnalyzePath("/a/b/c/d", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/a/b/c/⋯", got)
+
+	peeked, err := analyzer.PeekPath("/a/b/c/e/f", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/a/b/c/*", peeked)
+}
+
+func TestMaxDepth_DefaultUnlimitedAndSerialized(t *testing.T) {
+	unlimited := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
+	assert.Zero(t, unlimited.MaxDepth)
+	got, err := unlimited.AnalyzePath(deepPath("x", 100), "opens")
+	require.NoError(t, err)
+	assert.Equal(t, deepPath("x", 100), got)
+
+	data, err := json.Marshal(dynamicpathdetector.NewPathAnalyzerWithMaxDepth(dynamicpathdetector.OpenDynamicThreshold, nil, 5))
+	require.NoError(t, err)
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, 5, restored.MaxDepth)
+}
+
+func TestCollapseConfigMaxDepth(t *testing.T) {
+	configs := []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/cache", Threshold: 50, MaxDepth: 2},
+		{Prefix: "/var/lib/cache", Threshold: 50, MaxDepth: 1},
+	}
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, configs)
+
+	for p, want := range map[string]string{
+		"/cache/a/b/c/d":         "/cache/a/*",
+		"/cache/a":               "/cache/a",
+		"/cache/x/y":             "/cache/x/⋯",
+		"/var/lib/cache/x/y/z":   "/var/lib/cache/*",
+		"/other/a/b/c/d":         "/other/a/b/c/d",
+		"/cachedir/a/b/c/d":      "/cachedir/a/b/c/d",
+		"/var/lib/other/x/y/z/w": "/var/lib/other/x/y/z/w",
+	} {
+		got, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+	}
+
+	peeked, err := analyzer.PeekPath("/cache/q/r/s", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/cache/q/*", peeked)
+
+	data, err := json.Marshal(analyzer)
+	require.NoError(t, err)
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, json.Unmarshal(data, restored))
+	got, err := restored.AnalyzePath("/cache/m/n/o", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/cache/m/*", got)
+}
+
+func TestCollapseConfigMaxDepthAtRootMatchesAnalyzerMaxDepth(t *testing.T) {
+	perPrefix := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold,
+		[]dynamicpathdetector.CollapseConfig{{Prefix: "/", Threshold: dynamicpathdetector.OpenDynamicThreshold, MaxDepth: 3}})
+	global := dynamicpathdetector.NewPathAnalyzerWithMaxDepth(dynamicpathdetector.OpenDynamicThreshold, nil, 3)
+	for _, p := range []string{"/", "/a", "/a/b/c", "/a/b/c/d", "/a/b/c/d/e/f"} {
+		want, err := global.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		got, err := perPrefix.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+	}
+}
+
+// TestMaxDepth_StoredPathMatchesOriginal checks that what a path is folded
+// into by MaxDepth, global or per prefix, still matches the path itself.
+func TestMaxDepth_StoredPathMatchesOriginal(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithMaxDepth(dynamicpathdetector.OpenDynamicThreshold,
+		[]dynamicpathdetector.CollapseConfig{{Prefix: "/cache", Threshold: 50, MaxDepth: 1}}, 3)
+	for _, p := range []string{"/a/b/c/d", "/a/b/c/d/e/f", "/cache/x/y", "/cache/x/y/z", deepPath("x", 100)} {
+		got, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.True(t, dynamicpathdetector.CompareDynamic(got, p), "%s stored as %s", p, got)
+	}
+	for _, stored := range analyzer.GetStoredPaths("opens") {
+		assert.NotContains(t, stored, "⋯", "the multi-segment folds absorb the single ones")
+	}
+}
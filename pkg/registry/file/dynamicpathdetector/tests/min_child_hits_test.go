@@ -0,0 +1,68 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinChildHitsIgnoresOneOffChildren(t *testing.T) {
+	const threshold = 3
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	analyzer.MinChildHits = 3
+
+	for range 10 {
+		analyzeAll(t, analyzer, "/data/main")
+	}
+	for i := range 2 * threshold {
+		analyzeAll(t, analyzer, fmt.Sprintf("/data/noise%d", i))
+	}
+
+	peeked, err := analyzer.PeekPath("/data/noise0", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/noise0", peeked)
+	got, err := analyzer.AnalyzePath("/data/main", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/main", got, "one busy child among one-offs stays literal")
+	got, err = analyzer.AnalyzePath("/data/noise0", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/noise0", got)
+	assert.Len(t, analyzer.GetStoredPaths("opens"), 2*threshold+1)
+
+	// Without MinChildHits the same traffic collapses.
+	plain := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	analyzeAll(t, plain, "/data/main")
+	for i := range 2 * threshold {
+		analyzeAll(t, plain, fmt.Sprintf("/data/noise%d", i))
+	}
+	got, err = plain.AnalyzePath("/data/main", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/⋯", got)
+}
+
+func TestMinChildHitsCollapsesFrequentChildren(t *testing.T) {
+	const threshold = 3
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	analyzer.MinChildHits = 2
+
+	for i := range threshold + 1 {
+		analyzeAll(t, analyzer, fmt.Sprintf("/data/busy%d", i))
+	}
+	// threshold+1 children, but none seen twice yet.
+	got, err := analyzer.AnalyzePath("/data/busy0", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/busy0", got)
+
+	for i := 1; i <= threshold; i++ {
+		analyzeAll(t, analyzer, fmt.Sprintf("/data/busy%d", i))
+	}
+	peeked, err := analyzer.PeekPath("/data/other", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/⋯", peeked)
+	got, err = analyzer.AnalyzePath("/data/other", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/⋯", got, "more than threshold children seen twice collapse")
+}
@@ -0,0 +1,73 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinSegmentsBeforeCollapse(t *testing.T) {
+	threshold := 3
+	configs := []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/", Threshold: threshold, MinSegmentsBeforeCollapse: 1, CollapseNumericSegments: true},
+	}
+	var opens []types.OpenCalls
+	for i := 0; i <= threshold; i++ {
+		opens = append(opens,
+			types.OpenCalls{Path: fmt.Sprintf("/tmp%d", i), Flags: []string{"O_RDONLY"}},
+			types.OpenCalls{Path: fmt.Sprintf("/data/f%d", i), Flags: []string{"O_RDONLY"}},
+			types.OpenCalls{Path: fmt.Sprintf("/proc/%d", i), Flags: []string{"O_RDONLY"}},
+		)
+	}
+	opens = append(opens, types.OpenCalls{Path: "/4242", Flags: []string{"O_RDONLY"}})
+
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, configs)
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, analyzer, nil)
+	require.NoError(t, err)
+
+	var paths []string
+	for _, open := range result {
+		paths = append(paths, open.Path)
+	}
+	assert.ElementsMatch(t, []string{
+		"/4242",
+		"/data/⋯",
+		"/proc/⋯",
+		"/tmp0", "/tmp1", "/tmp2", "/tmp3",
+	}, paths, "single-segment siblings stay literal while deeper ones collapse")
+
+	assert.Equal(t, dynamicpathdetector.NeverCollapse, analyzer.EffectiveThreshold("/tmp0"))
+	assert.Equal(t, threshold, analyzer.EffectiveThreshold("/data/f0"))
+	peeked, err := analyzer.PeekPath("/tmp9", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp9", peeked)
+}
+
+func TestMinSegmentsBeforeCollapseCountsFromRoot(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(1000, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/srv", Threshold: 2, MinSegmentsBeforeCollapse: 2},
+	})
+	var paths []string
+	for i := 0; i < 3; i++ {
+		paths = append(paths, fmt.Sprintf("/srv/app%d/f%d", i, i), fmt.Sprintf("/srv/app/f%d", i))
+	}
+	analyzeAll(t, analyzer, paths...)
+	analyzeAll(t, analyzer, paths...)
+
+	// /srv is one component deep, /srv/app two.
+	assert.ElementsMatch(t, []string{
+		"/srv/app/⋯",
+		"/srv/app0/f0", "/srv/app1/f1", "/srv/app2/f2",
+	}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestMinSegmentsBeforeCollapseValidation(t *testing.T) {
+	err := dynamicpathdetector.ValidateConfigs([]dynamicpathdetector.CollapseConfig{
+		{Prefix: "/", Threshold: 10, MinSegmentsBeforeCollapse: -1},
+	})
+	assert.ErrorContains(t, err, "negative min segments before collapse -1")
+}
@@ -0,0 +1,55 @@
+package dynamicpathdetectortests
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoreSpecific(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"/app/config/x", "/app/config/⋯", -1},
+		{"/app/config/⋯", "/app/*", -1},
+		{"/app/config/x", "/app/*", -1},
+		{"/app/*", "/app/config/⋯", 1},
+		{"/app/config/⋯", "/app/config/⋯", 0},
+		{"/app/config/", "/app/config", 0},
+		// Same literals: * loses to ⋯, ⋯ loses to a ? glob.
+		{"/app/⋯/x", "/app/*/x", -1},
+		{"/dev/tty?", "/dev/⋯", -1},
+		{"/dev/tty0", "/dev/tty?", -1},
+		// Literal segments outweigh wildcard count.
+		{"/a/*/b/*/c", "/a/⋯", -1},
+	}
+	for _, tt := range tests {
+		got := dynamicpathdetector.MoreSpecific(tt.a, tt.b)
+		assert.Equal(t, tt.want, max(-1, min(got, 1)), "MoreSpecific(%q, %q)", tt.a, tt.b)
+		assert.Equal(t, -tt.want, max(-1, min(dynamicpathdetector.MoreSpecific(tt.b, tt.a), 1)), "MoreSpecific(%q, %q)", tt.b, tt.a)
+	}
+}
+
+func TestMoreSpecificWithOptions(t *testing.T) {
+	opts := dynamicpathdetector.CompareDynamicOpts{DynamicIdentifier: "{d}", WildcardIdentifier: "**"}
+	patterns := []string{"/app/**", "/app/config/x", "/**", "/app/config/{d}"}
+	slices.SortFunc(patterns, func(a, b string) int { return dynamicpathdetector.MoreSpecificWithOptions(a, b, opts) })
+	assert.Equal(t, []string{"/app/config/x", "/app/config/{d}", "/app/**", "/**"}, patterns)
+}
+
+func TestMoreSpecificSortsMatchingPatterns(t *testing.T) {
+	patterns := []string{"/app/*", "/app/config/x", "/*", "/app/config/⋯"}
+	slices.SortFunc(patterns, dynamicpathdetector.MoreSpecific)
+	assert.Equal(t, []string{"/app/config/x", "/app/config/⋯", "/app/*", "/*"}, patterns)
+
+	var matching []string
+	for _, p := range patterns {
+		if dynamicpathdetector.CompareDynamic(p, "/app/config/x") {
+			matching = append(matching, p)
+		}
+	}
+	assert.Equal(t, "/app/config/x", matching[0])
+}
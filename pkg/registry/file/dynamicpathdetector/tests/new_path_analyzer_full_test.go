@@ -0,0 +1,49 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPathAnalyzerFull(t *testing.T) {
+	defaultCfg := dynamicpathdetector.CollapseConfig{
+		Threshold:               4, // the root's four children stay literal
+		ExtensionThresholds:     map[string]int{".log": 5},
+		CollapseNumericSegments: true,
+	}
+	configs := []dynamicpathdetector.CollapseConfig{{Prefix: "/data", Threshold: 10}}
+	analyzer := dynamicpathdetector.NewPathAnalyzerFull(defaultCfg, configs)
+
+	for i := range 5 {
+		analyzeAll(t, analyzer,
+			fmt.Sprintf("/tmp/f%d", i),
+			fmt.Sprintf("/data/f%d", i),
+			fmt.Sprintf("/var/log/app%d.log", i),
+		)
+	}
+	for _, tt := range []struct{ path, want string }{
+		{"/tmp/f0", "/tmp/⋯"},                      // default threshold
+		{"/data/f0", "/data/f0"},                   // override
+		{"/var/log/app0.log", "/var/log/app0.log"}, // default extension threshold
+		{"/proc/1234/status", "/proc/⋯/status"},    // default numeric collapse
+		{"/data/5678/status", "/data/5678/status"}, // override has none
+	} {
+		got, err := analyzer.AnalyzePath(tt.path, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.path)
+	}
+
+	assert.Equal(t, 4, analyzer.FindConfigForPath("/tmp/f0").Threshold)
+	assert.Equal(t, "/", analyzer.FindConfigForPath("/tmp/f0").Prefix)
+	assert.Equal(t, 10, analyzer.FindConfigForPath("/data/f0").Threshold)
+	assert.Equal(t, 5, analyzer.EffectiveThreshold("/var/log/app0.log"))
+	assert.Len(t, configs, 1, "the caller's slice is not appended to")
+
+	// A "/" entry of configs wins over the default.
+	analyzer = dynamicpathdetector.NewPathAnalyzerFull(defaultCfg, []dynamicpathdetector.CollapseConfig{{Prefix: "/", Threshold: 7}})
+	assert.Equal(t, 7, analyzer.EffectiveThreshold("/tmp/f0"))
+}
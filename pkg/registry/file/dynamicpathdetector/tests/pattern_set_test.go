@@ -0,0 +1,156 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// manyPatterns returns n patterns mixing literals, ⋯, ?, mid-path and
+// trailing *, with several patterns able to match the same path.
+func manyPatterns(n int) []string {
+	patterns := make([]string, 0, n)
+	for i := 0; len(patterns) < n; i++ {
+		switch i % 8 {
+		case 0:
+			patterns = append(patterns, fmt.Sprintf("/app/svc%d/config.yaml", i%97))
+		case 1:
+			patterns = append(patterns, fmt.Sprintf("/app/svc%d/⋯", i%89))
+		case 2:
+			patterns = append(patterns, fmt.Sprintf("/proc/⋯/task/%d/*", i%53))
+		case 3:
+			patterns = append(patterns, fmt.Sprintf("/var/log/*/svc%d.log", i%71))
+		case 4:
+			patterns = append(patterns, fmt.Sprintf("/dev/tty%d?", i%11))
+		case 5:
+			patterns = append(patterns, fmt.Sprintf("/usr/lib/lib%d.so.⋯", i%101))
+		case 6:
+			patterns = append(patterns, fmt.Sprintf("/etc/svc%d/*", i%61))
+		case 7:
+			patterns = append(patterns, fmt.Sprintf("/home/u%d/", i%43))
+		}
+	}
+	return patterns
+}
+
+func manyPatternPaths() []string {
+	var paths []string
+	for i := 0; i < 120; i++ {
+		paths = append(paths,
+			fmt.Sprintf("/app/svc%d/config.yaml", i),
+			fmt.Sprintf("/app/svc%d/data/x", i),
+			fmt.Sprintf("/proc/%d/task/%d/status", i, i%60),
+			fmt.Sprintf("/proc/%d/task/%d", i, i%60),
+			fmt.Sprintf("/var/log/svc%d.log", i),
+			fmt.Sprintf("/var/log/a/b/svc%d.log", i),
+			fmt.Sprintf("/dev/tty%d%d", i%12, i%10),
+			fmt.Sprintf("/usr/lib/lib%d.so.6", i),
+			fmt.Sprintf("/etc/svc%d", i),
+			fmt.Sprintf("/etc/svc%d/a/../b", i),
+			fmt.Sprintf("/home/u%d", i),
+		)
+	}
+	return append(paths, "/", "", "/unrelated/path")
+}
+
+func TestMatchAnyAgreesWithCompareDynamic(t *testing.T) {
+	patterns := append(manyPatterns(1000), "*")
+	require.Len(t, patterns, 1001)
+	set, err := dynamicpathdetector.CompilePatterns(patterns)
+	require.NoError(t, err)
+
+	for _, p := range manyPatternPaths() {
+		wantOK, want := false, ""
+		for _, pattern := range patterns {
+			if dynamicpathdetector.CompareDynamic(pattern, p) {
+				wantOK, want = true, pattern
+				break
+			}
+		}
+		got, ok := set.Match(p)
+		assert.Equal(t, wantOK, ok, p)
+		assert.Equal(t, want, got, p)
+
+		ok, got, err = dynamicpathdetector.MatchAny(patterns, p)
+		require.NoError(t, err)
+		assert.Equal(t, wantOK, ok, p)
+		assert.Equal(t, want, got, p)
+	}
+}
+
+func TestCompilePatternsWithOptionsAgreesWithCompareDynamic(t *testing.T) {
+	for name, opts := range map[string]dynamicpathdetector.CompareDynamicOpts{
+		"identifiers":        {DynamicIdentifier: "{d}", WildcardIdentifier: "**"},
+		"case insensitive":   {CaseInsensitive: true},
+		"non-empty wildcard": {NonEmptyWildcard: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			patterns := append(manyPatterns(200), "/A/*/B", "/a/*/c")
+			if opts.DynamicIdentifier != "" {
+				replacer := strings.NewReplacer("⋯", opts.DynamicIdentifier, "*", opts.WildcardIdentifier)
+				for i := range patterns {
+					patterns[i] = replacer.Replace(patterns[i])
+				}
+			}
+			set, err := dynamicpathdetector.CompilePatternsWithOptions(patterns, opts)
+			require.NoError(t, err)
+
+			for _, p := range append(manyPatternPaths(), "/App/SVC1/config.yaml", "/a/b", "/a/x/b", "/a/c") {
+				wantOK, want := false, ""
+				for _, pattern := range patterns {
+					if dynamicpathdetector.CompareDynamicWithOptions(pattern, p, opts) {
+						wantOK, want = true, pattern
+						break
+					}
+				}
+				got, ok := set.Match(p)
+				assert.Equal(t, wantOK, ok, p)
+				assert.Equal(t, want, got, p)
+			}
+		})
+	}
+}
+
+func TestMatchAnyFirstMatch(t *testing.T) {
+	patterns := []string{"/etc/⋯/sshd_config", "/etc/*", "/etc/ssh/sshd_config", "/etc/ssh/*"}
+
+	ok, match, err := dynamicpathdetector.MatchAny(patterns, "/etc/ssh/sshd_config")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "/etc/⋯/sshd_config", match, "earlier pattern wins over a more specific one")
+
+	ok, match, err = dynamicpathdetector.MatchAny(patterns, "/etc/ssh/ssh_config")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "/etc/*", match)
+
+	ok, match, err = dynamicpathdetector.MatchAny(patterns, "/etc")
+	require.NoError(t, err)
+	assert.False(t, ok, "/etc/* does not match /etc itself")
+	assert.Empty(t, match)
+}
+
+func TestMatchAnyEdgeCases(t *testing.T) {
+	ok, match, err := dynamicpathdetector.MatchAny(nil, "/etc/passwd")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Empty(t, match)
+
+	_, _, err = dynamicpathdetector.MatchAny([]string{"/etc/*", ""}, "/etc/passwd")
+	assert.ErrorIs(t, err, dynamicpathdetector.ErrEmptyPattern)
+
+	patterns := []string{"/a/*/b", "/a/*/*/c"}
+	set, err := dynamicpathdetector.CompilePatterns(patterns)
+	require.NoError(t, err)
+	patterns[0] = "/changed"
+	assert.Equal(t, []string{"/a/*/b", "/a/*/*/c"}, set.Patterns(), "the set keeps its own copy")
+	for p, want := range map[string]string{"/a/b": "/a/*/b", "/a/x/y/b": "/a/*/b", "/a/x/c": "/a/*/*/c", "/a/c": "/a/*/*/c"} {
+		got, ok := set.Match(p)
+		assert.True(t, ok, p)
+		assert.Equal(t, want, got, p)
+	}
+}
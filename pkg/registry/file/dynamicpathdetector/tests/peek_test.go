@@ -0,0 +1,132 @@
+package dynamicpathdetectortests
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeekPath_DoesNotMutate(t *testing.T) {
+	threshold := configThreshold("/var/run")
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, nil)
+	for i := 0; i < threshold; i++ {
+		_, err := analyzer.AnalyzePath(fmt.Sprintf("/home/user%d/file.txt", i), "opens")
+		require.NoError(t, err)
+	}
+	before, err := json.Marshal(analyzer)
+	require.NoError(t, err)
+
+	// Each candidate would be a new sibling; peeking any number of them
+	// must not push /home over its threshold.
+	for i := 0; i < 10*threshold; i++ {
+		got, err := analyzer.PeekPath(fmt.Sprintf("/home/candidate%d/file.txt", i), "opens")
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("/home/candidate%d/file.txt", i), got)
+	}
+	got, err := analyzer.PeekPath("/unknown/root", "never-seen")
+	require.NoError(t, err)
+	assert.Equal(t, "/unknown/root", got)
+
+	after, err := json.Marshal(analyzer)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(before), string(after), "PeekPath must not change the trie")
+	assert.NotContains(t, analyzer.RootNodes, "never-seen")
+}
+
+// TestPeekPath_DoesNotMutateThroughCollapse peeks paths whose walk
+// collapses a directory with overlapping subtrees and splits a dynamic
+// run: the dry run changes copies of those nodes, never the trie.
+func TestPeekPath_DoesNotMutateThroughCollapse(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(3)
+	analyzer.CollapseAdjacent = true
+	for i := 0; i < 4; i++ {
+		analyzeAll(t, analyzer, fmt.Sprintf("/a/d%d/sub/f0", i), fmt.Sprintf("/a/d%d/sub/f1", i))
+	}
+	analyzeAll(t, analyzer, "/run/⋯/⋯/⋯/x")
+	before, err := json.Marshal(analyzer)
+	require.NoError(t, err)
+
+	for _, probe := range []string{"/a/d0/sub/f1", "/a/⋯/sub/new", "/a/*", "/run/⋯/⋯", "/run/⋯/.hidden/x"} {
+		_, err := analyzer.PeekPath(probe, "opens")
+		require.NoError(t, err)
+	}
+
+	after, err := json.Marshal(analyzer)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(before), string(after), "PeekPath must not change the trie")
+	assertNoSharedNodes(t, analyzer.RootNodes["opens"])
+}
+
+// TestPeekPath_MatchesAnalyzePath trains an analyzer, then for each probe
+// compares PeekPath on the trained analyzer with AnalyzePath on a fresh
+// copy of it. The probes cover literal hits, already-collapsed ⋯ nodes, a
+// directory that is over threshold but not yet collapsed, threshold-1 *
+// prefixes and paths that leave the trie.
+func TestPeekPath_MatchesAnalyzePath(t *testing.T) {
+	threshold := configThreshold("/var/run")
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, testCollapseConfigs)
+	var training []string
+	for i := 0; i < threshold+2; i++ {
+		training = append(training, fmt.Sprintf("/home/user%d/file.txt", i))
+	}
+	for i := 0; i < threshold+1; i++ {
+		training = append(training, fmt.Sprintf("/var/run/svc%d/pid", i))
+		training = append(training, fmt.Sprintf("/grid/%d/%d/leaf", i, i))
+	}
+	training = append(training, "/app/one/two", "/etc/passwd", "/etc/ssl/certs/ca.pem")
+	for _, p := range training {
+		_, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+	snapshot, err := json.Marshal(analyzer)
+	require.NoError(t, err)
+
+	probes := []string{
+		"/home/user0/file.txt",
+		"/home/someone-else/file.txt",
+		"/home/user0/other.txt",
+		"/var/run/svc0/pid",
+		"/var/run/brand-new/pid",
+		"/grid/0/0/leaf",
+		"/grid/x/y/leaf",
+		"/app/anything/at/all",
+		"/etc/passwd",
+		"/etc/ssl/certs/new.pem",
+		"/etc/⋯/certs",
+		"/new/tree/entirely",
+		"/",
+	}
+	for _, probe := range probes {
+		t.Run(probe, func(t *testing.T) {
+			peeked, err := analyzer.PeekPath(probe, "opens")
+			require.NoError(t, err)
+
+			clone := &dynamicpathdetector.PathAnalyzer{}
+			require.NoError(t, json.Unmarshal(snapshot, clone))
+			analyzed, err := clone.AnalyzePath(probe, "opens")
+			require.NoError(t, err)
+
+			assert.Equal(t, analyzed, peeked)
+		})
+	}
+}
+
+// TestPeekPath_MatchesAnalyzePathThroughLiteralDynamic walks a literal ⋯
+// into a directory whose absorbed children already exceed the threshold,
+// so the ⋯ node created on the way must carry their count.
+func TestPeekPath_MatchesAnalyzePathThroughLiteralDynamic(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(2)
+	analyzeAll(t, analyzer, "/22/a/x", "/22/22/22", "/x/e")
+	const probe = "/⋯/usr/d/usr"
+
+	peeked, err := analyzer.PeekPath(probe, "opens")
+	require.NoError(t, err)
+	analyzed, err := analyzer.AnalyzePath(probe, "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/*/d/usr", analyzed)
+	assert.Equal(t, analyzed, peeked)
+}
@@ -0,0 +1,141 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func homePaths() []string {
+	paths := []string{"/home/user/.bashrc", "/home/user/.profile", "/home/user/.ssh/authorized_keys"}
+	for i := 0; i < 6; i++ {
+		paths = append(paths, fmt.Sprintf("/home/user/file%d.txt", i))
+	}
+	return paths
+}
+
+func TestPreserveHiddenKeepsDotfiles(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/home", Threshold: 3, PreserveHidden: true},
+	})
+	analyzeAll(t, analyzer, homePaths()...)
+
+	for p, want := range map[string]string{
+		"/home/user/.bashrc":              "/home/user/.bashrc",
+		"/home/user/.profile":             "/home/user/.profile",
+		"/home/user/.ssh/authorized_keys": "/home/user/.ssh/authorized_keys",
+		"/home/user/file0.txt":            "/home/user/⋯",
+		"/home/user/other.txt":            "/home/user/⋯",
+		"/home/user/.newrc":               "/home/user/.newrc",
+	} {
+		peeked, err := analyzer.PeekPath(p, "opens")
+		require.NoError(t, err)
+		got, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+		assert.Equal(t, want, peeked, "PeekPath(%s)", p)
+	}
+	assert.Equal(t, []string{
+		"/home/user/.bashrc",
+		"/home/user/.newrc",
+		"/home/user/.profile",
+		"/home/user/.ssh/authorized_keys",
+		"/home/user/⋯",
+	}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestPreserveHiddenDotfilesDoNotCount(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/home", Threshold: 3, PreserveHidden: true},
+	})
+	var paths []string
+	for i := 0; i < 10; i++ {
+		paths = append(paths, fmt.Sprintf("/home/user/.rc%d", i))
+	}
+	analyzeAll(t, analyzer, append(paths, "/home/user/a", "/home/user/b", "/home/user/c")...)
+
+	got, err := analyzer.AnalyzePath("/home/user/a", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/user/a", got, "three regular files stay within the threshold")
+	assert.Equal(t, 3, analyzer.GetStoredPathsWithCounts("opens")["/home/user"])
+}
+
+func TestPreserveHiddenOffFoldsDotfiles(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/home", Threshold: 3},
+	})
+	analyzeAll(t, analyzer, homePaths()...)
+
+	got, err := analyzer.AnalyzePath("/home/user/.bashrc", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/user/⋯", got)
+}
+
+func TestPreserveHiddenSurvivesWildcard(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/instant", Threshold: 1, PreserveHidden: true},
+	})
+	analyzeAll(t, analyzer, "/instant/.env", "/instant/a", "/instant/b/c")
+
+	for p, want := range map[string]string{
+		"/instant/.env": "/instant/.env",
+		"/instant/x":    "/instant/*",
+	} {
+		got, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+	}
+}
+
+func TestPreserveHiddenWithCollapseAdjacent(t *testing.T) {
+	configs := []dynamicpathdetector.CollapseConfig{{Prefix: "/data", Threshold: 3, PreserveHidden: true}}
+	paths := append(adjacentGridPaths(5),
+		"/data/1/.git/config",
+		"/data/1/2/.hidden/file",
+		"/data/⋯/⋯/.cache",
+		"/data/.env",
+		"/data/9/9/9/file",
+	)
+	plain := dynamicpathdetector.NewPathAnalyzerWithConfigs(3, configs)
+	merged := dynamicpathdetector.NewPathAnalyzerWithConfigs(3, configs)
+	merged.CollapseAdjacent = true
+	for _, p := range paths {
+		want, err := plain.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		got, err := merged.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+	}
+	assert.Equal(t, plain.GetStoredPaths("opens"), merged.GetStoredPaths("opens"))
+	assert.Contains(t, plain.GetStoredPaths("opens"), "/data/.env")
+}
+
+// TestPreserveHiddenAtRoot covers the root directory, whose children the
+// walk reaches before any "/": a "/" config, such as the default of
+// NewPathAnalyzerFull, keeps root-level dotfiles out of its collapse too.
+func TestPreserveHiddenAtRoot(t *testing.T) {
+	for name, analyzer := range map[string]*dynamicpathdetector.PathAnalyzer{
+		"config": dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, []dynamicpathdetector.CollapseConfig{
+			{Prefix: "/", Threshold: 3, PreserveHidden: true},
+		}),
+		"default": dynamicpathdetector.NewPathAnalyzerFull(dynamicpathdetector.CollapseConfig{Threshold: 3, PreserveHidden: true}, nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// The root collapses on the walk of the last path, which
+			// does not pass through the dotfile.
+			analyzeAll(t, analyzer, "/.a/x", "/b/y", "/c/z", "/d/w", "/e/v", "/b/y")
+			assert.Equal(t, map[string]int{
+				"/.a":   1,
+				"/.a/x": 0,
+				"/⋯":    4,
+				"/⋯/⋯":  0,
+			}, analyzer.GetStoredPathsWithCounts("opens"))
+			peeked, err := analyzer.PeekPath("/.a/x", "opens")
+			require.NoError(t, err)
+			assert.Equal(t, "/.a/x", peeked)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func analyzeAll(t *testing.T, analyzer *dynamicpathdetector.PathAnalyzer, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		_, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+	analyzeAll(t, analyzer,
+		"/usr/bin/frequent", "/usr/bin/frequent", "/usr/bin/frequent",
+		"/usr/bin/oneoff",
+		"/tmp/oneoff/file",
+	)
+
+	analyzer.Prune(2)
+	assert.Equal(t, []string{"/usr/bin/frequent"}, analyzer.GetStoredPaths("opens"))
+	counts := analyzer.GetStoredPathsWithCounts("opens")
+	assert.Equal(t, 1, counts["/usr/bin"], "Count re-rolled after dropping /usr/bin/oneoff")
+	assert.NotContains(t, counts, "/tmp")
+}
+
+// TestPrune_AfterAnalyzeFunctions checks that the two passes of
+// AnalyzeOpens, AnalyzeEndpoints and AnalyzeExecs count every entry of
+// their input once, so Prune tells a repeated entry from a one-off.
+func TestPrune_AfterAnalyzeFunctions(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+	_, err := dynamicpathdetector.AnalyzeOpens([]types.OpenCalls{
+		{Path: "/etc/once"}, {Path: "/etc/freq"}, {Path: "/etc/freq"}, {Path: "/etc/freq"},
+	}, analyzer, nil)
+	require.NoError(t, err)
+	_, err = dynamicpathdetector.AnalyzeExecs([]types.ExecCalls{
+		{Path: "/bin/once"}, {Path: "/bin/freq"}, {Path: "/bin/freq"},
+	}, analyzer)
+	require.NoError(t, err)
+	dynamicpathdetector.AnalyzeEndpoints(&[]types.HTTPEndpoint{
+		{Endpoint: ":80/once"}, {Endpoint: ":80/freq"}, {Endpoint: ":80/freq"},
+	}, analyzer)
+
+	analyzer.Prune(2)
+	assert.Equal(t, []string{"/etc/freq"}, analyzer.GetStoredPaths("opens"))
+	assert.Equal(t, []string{"/bin/freq"}, analyzer.GetStoredPaths("execs"))
+	assert.Equal(t, []string{"/freq"}, analyzer.GetStoredPaths("80"))
+}
+
+func TestPrune_KeepsAggregateNodes(t *testing.T) {
+	threshold := configThreshold("/opt")
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	// The (threshold+2)-th walk through /opt performs the collapse.
+	for i := 0; i < threshold+2; i++ {
+		analyzeAll(t, analyzer, fmt.Sprintf("/opt/plugin%d/lib.so", i))
+	}
+	analyzeAll(t, analyzer, "/app/once", "/data/⋯/once")
+
+	analyzer.Prune(1000)
+	assert.Equal(t, []string{"/app/*", "/data/⋯", "/opt/⋯"}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestPrune_DirectoryLeftEmptyIsRemoved(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+	analyzeAll(t, analyzer, "/var/a", "/var/b", "/etc/hosts", "/etc/hosts", "/etc", "/etc")
+
+	analyzer.Prune(2)
+	assert.Equal(t, []string{"/etc", "/etc/hosts"}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestPrune_NonPositiveIsNoop(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+	analyzeAll(t, analyzer, "/a", "/b/c")
+	analyzer.Prune(0)
+	assert.Equal(t, []string{"/a", "/b/c"}, analyzer.GetStoredPaths("opens"))
+}
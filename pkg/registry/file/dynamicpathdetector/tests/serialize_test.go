@@ -0,0 +1,106 @@
+package dynamicpathdetectortests
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPathAnalyzerJSONRoundTrip warms an analyzer past the collapse
+// threshold, ships it through JSON, and checks that the restored copy
+// collapses a never-seen path exactly like the original does.
+func TestPathAnalyzerJSONRoundTrip(t *testing.T) {
+	threshold := configThreshold("/opt")
+	original := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	for i := 0; i < threshold+1; i++ {
+		_, err := original.AnalyzePath(fmt.Sprintf("/opt/plugin%d/lib.so", i), "opens")
+		require.NoError(t, err)
+	}
+	_, err := original.AnalyzePath("/app/anything/goes", "opens")
+	require.NoError(t, err)
+
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, json.Unmarshal(data, restored))
+
+	assert.Equal(t, original.RootNodes, restored.RootNodes, "trie (including Count and ⋯/* nodes) must survive the round-trip")
+	assert.Equal(t, original.FindConfigForPath("/opt/x"), restored.FindConfigForPath("/opt/x"))
+	assert.Equal(t, original.FindConfigForPath("/unconfigured"), restored.FindConfigForPath("/unconfigured"))
+
+	for _, p := range []string{
+		"/opt/brand-new-plugin/lib.so",
+		"/app/other/thing",
+		"/home/user/file.txt",
+	} {
+		want, err := original.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		got, err := restored.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "collapse decision for %s", p)
+	}
+	got, err := restored.AnalyzePath("/opt/yet-another/lib.so", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/⋯/lib.so", got)
+}
+
+func TestPathAnalyzerUnmarshalJSON_NullChildren(t *testing.T) {
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, json.Unmarshal([]byte(`{"rootNodes":{"opens":{"SegmentName":"opens","Count":0,"Children":null}},"threshold":3}`), restored))
+
+	assert.Equal(t, dynamicpathdetector.CollapseConfig{Prefix: "/", Threshold: 3}, restored.FindConfigForPath("/anything"))
+	got, err := restored.AnalyzePath("/a/b", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/a/b", got)
+}
+
+func TestPathAnalyzerUnmarshalJSON_Invalid(t *testing.T) {
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	assert.Error(t, json.Unmarshal([]byte(`{"rootNodes":[]}`), restored))
+	assert.Error(t, json.Unmarshal([]byte(`{"rootNodes":{"opens":null}}`), restored))
+}
+
+func TestPathAnalyzerJSONRoundTrip_CustomIdentifiers(t *testing.T) {
+	original := dynamicpathdetector.NewPathAnalyzerWithIdentifiers(2, "{dyn}", "{any}")
+	for i := 0; i < 3; i++ {
+		_, err := original.AnalyzePath(fmt.Sprintf("/srv/%d/data", i), "opens")
+		require.NoError(t, err)
+	}
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, "{dyn}", restored.DynamicIdentifier())
+	assert.Equal(t, "{any}", restored.WildcardIdentifier())
+	got, err := restored.AnalyzePath("/srv/new/data", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/srv/{dyn}/data", got)
+}
+
+// TestPathAnalyzerJSONRoundTrip_MinChildHits checks that a restored
+// analyzer keeps weighting collapse by traffic: /d has three children but
+// only two busy ones, so a fourth one-off child still stays literal.
+func TestPathAnalyzerJSONRoundTrip_MinChildHits(t *testing.T) {
+	original := dynamicpathdetector.NewPathAnalyzer(2)
+	original.MinChildHits = 2
+	analyzeAll(t, original, "/d/a", "/d/a", "/d/b", "/d/b", "/d/c")
+
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, json.Unmarshal(data, restored))
+	assert.Equal(t, 2, restored.MinChildHits)
+
+	want, err := original.AnalyzePath("/d/e", "opens")
+	require.NoError(t, err)
+	got, err := restored.AnalyzePath("/d/e", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/d/e", want)
+	assert.Equal(t, want, got)
+}
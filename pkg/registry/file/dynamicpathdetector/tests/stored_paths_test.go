@@ -0,0 +1,94 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStoredPaths(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	assert.Nil(t, analyzer.GetStoredPaths("opens"))
+
+	for _, p := range []string{"/etc/passwd", "/etc/hosts", "/usr/bin/ls", "/app/a/b"} {
+		_, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+	_, err := analyzer.AnalyzePath("/other", "execs")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/app/*", "/etc/hosts", "/etc/passwd", "/usr/bin/ls"}, analyzer.GetStoredPaths("opens"))
+	assert.Equal(t, []string{"/other"}, analyzer.GetStoredPaths("execs"))
+}
+
+func TestGetStoredPathsWithCounts(t *testing.T) {
+	threshold := configThreshold("/opt")
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	assert.Nil(t, analyzer.GetStoredPathsWithCounts("opens"))
+
+	for i := 0; i < threshold+1; i++ {
+		_, err := analyzer.AnalyzePath(fmt.Sprintf("/opt/plugin%d/lib.so", i), "opens")
+		require.NoError(t, err)
+	}
+	_, err := analyzer.AnalyzePath("/opt/plugin-new/lib.so", "opens")
+	require.NoError(t, err)
+	for _, p := range []string{"/app/one", "/app/two"} {
+		_, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+
+	counts := analyzer.GetStoredPathsWithCounts("opens")
+	assert.Equal(t, threshold+1, counts["/opt"], "collapsed directory keeps the number of children it absorbed")
+	assert.Equal(t, 1, counts["/opt/⋯"], "dynamic node reports the distinct grandchildren merged into it")
+	assert.Equal(t, 0, counts["/opt/⋯/lib.so"])
+	assert.Contains(t, counts, "/app/*")
+	assert.NotContains(t, counts, "/opt/plugin0")
+	assert.NotContains(t, counts, "")
+	assert.Equal(t, []string{"/app/*", "/opt/⋯/lib.so"}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestGetStoredPaths_TerminalInternalNodes(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold)
+	for _, p := range []string{"/a/b", "/a/b/c", "/x/y/z"} {
+		_, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"/a/b", "/a/b/c", "/x/y/z"}, analyzer.GetStoredPaths("opens"))
+
+	t.Run("terminal survives collapse", func(t *testing.T) {
+		threshold := 2
+		analyzer := dynamicpathdetector.NewPathAnalyzer(threshold)
+		for _, p := range []string{"/home/u0", "/home/u1/notes", "/home/u2/notes"} {
+			_, err := analyzer.AnalyzePath(p, "opens")
+			require.NoError(t, err)
+		}
+		_, err := analyzer.AnalyzePath("/home/u3/notes", "opens")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/home/⋯", "/home/⋯/notes"}, analyzer.GetStoredPaths("opens"))
+	})
+}
+
+func TestDynamicPaths(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/home", Threshold: 2},
+		{Prefix: "/tmp", Threshold: 2},
+	})
+	assert.Nil(t, analyzer.DynamicPaths("opens"))
+
+	analyzeAll(t, analyzer, "/etc/passwd", "/etc/hosts", "/usr/bin/ls")
+	assert.Nil(t, analyzer.DynamicPaths("opens"), "nothing has collapsed yet")
+
+	for i := 0; i < 4; i++ {
+		analyzeAll(t, analyzer, fmt.Sprintf("/home/u%d/notes", i), fmt.Sprintf("/tmp/run%d.sock", i))
+	}
+	analyzeAll(t, analyzer, "/tmp/run9.sock")
+	_, err := analyzer.AnalyzePath("/srv/*", "opens")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/etc/hosts", "/etc/passwd", "/home/⋯/notes", "/srv/*", "/tmp/⋯", "/usr/bin/ls"}, analyzer.GetStoredPaths("opens"))
+	assert.Equal(t, []string{"/home/⋯/notes", "/srv/*", "/tmp/⋯"}, analyzer.DynamicPaths("opens"))
+	assert.Nil(t, analyzer.DynamicPaths("execs"))
+}
@@ -0,0 +1,70 @@
+package dynamicpathdetectortests
+
+import (
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDynamicPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		style dynamicpathdetector.DynamicStyle
+		want  string
+	}{
+		{"default", "/a/⋯/c", "", "/a/⋯/c"},
+		{"ellipsis", "/a/⋯/c", dynamicpathdetector.DynamicStyleEllipsis, "/a/⋯/c"},
+		{"single star", "/a/⋯/c", dynamicpathdetector.DynamicStyleSingleStar, "/a/*/c"},
+		{"double star", "/a/⋯/c", dynamicpathdetector.DynamicStyleDoubleStar, "/a/*/c"},
+		{"single star keeps wildcard", "/a/⋯/b/*", dynamicpathdetector.DynamicStyleSingleStar, "/a/*/b/*"},
+		{"double star wildcard", "/a/⋯/b/*", dynamicpathdetector.DynamicStyleDoubleStar, "/a/*/b/**"},
+		{"endpoint", ":80/users/⋯/posts", dynamicpathdetector.DynamicStyleDoubleStar, ":80/users/*/posts"},
+		{"mid-segment left alone", "/a/x⋯y/c*", dynamicpathdetector.DynamicStyleDoubleStar, "/a/x⋯y/c*"},
+		{"literal path", "/etc/passwd", dynamicpathdetector.DynamicStyleDoubleStar, "/etc/passwd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, dynamicpathdetector.FormatDynamicPath(tt.path, tt.style))
+		})
+	}
+}
+
+func TestParseDynamicStyle(t *testing.T) {
+	for in, want := range map[string]dynamicpathdetector.DynamicStyle{
+		"":                     dynamicpathdetector.DynamicStyleEllipsis,
+		"ellipsis":             dynamicpathdetector.DynamicStyleEllipsis,
+		"singleStarPerSegment": dynamicpathdetector.DynamicStyleSingleStar,
+		"doubleStar":           dynamicpathdetector.DynamicStyleDoubleStar,
+	} {
+		got, err := dynamicpathdetector.ParseDynamicStyle(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := dynamicpathdetector.ParseDynamicStyle("glob")
+	assert.Error(t, err)
+}
+
+func TestParseDynamicPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		style dynamicpathdetector.DynamicStyle
+		want  string
+	}{
+		{"ellipsis", "/a/⋯/b/*", dynamicpathdetector.DynamicStyleEllipsis, "/a/⋯/b/*"},
+		{"double star", "/a/*/b/**", dynamicpathdetector.DynamicStyleDoubleStar, "/a/⋯/b/*"},
+		{"single star", "/a/*/b/*", dynamicpathdetector.DynamicStyleSingleStar, "/a/⋯/b/⋯"},
+		{"single star leaves **", "/a/**", dynamicpathdetector.DynamicStyleSingleStar, "/a/**"},
+		{"mid-segment left alone", "/a/x*y/c*", dynamicpathdetector.DynamicStyleDoubleStar, "/a/x*y/c*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dynamicpathdetector.ParseDynamicPath(tt.path, tt.style)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.path, dynamicpathdetector.FormatDynamicPath(got, tt.style), "formatting again restores the stored path")
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTemporaryConfig(t *testing.T) {
+	base := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, dynamicpathdetector.DefaultCollapseConfigs())
+	var opens []types.OpenCalls
+	for i := 0; i < 10; i++ {
+		opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("/var/run/pod%d.sock", i), Flags: []string{"O_RDWR"}})
+	}
+
+	strict := base.WithTemporaryConfig(dynamicpathdetector.CollapseConfig{Prefix: "/var/run", Threshold: 5})
+	assert.Equal(t, 5, strict.EffectiveThreshold("/var/run/pod0.sock"))
+	assert.Equal(t, 100, strict.EffectiveThreshold("/etc/hosts"), "other prefixes keep the base config")
+
+	result, err := dynamicpathdetector.AnalyzeOpens(opens, strict, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{{Path: "/var/run/⋯", Flags: []string{"O_RDWR"}}}, result)
+
+	// The base analyzer keeps its threshold and learned nothing.
+	assert.Equal(t, 50, base.EffectiveThreshold("/var/run/pod0.sock"))
+	assert.Equal(t, dynamicpathdetector.DefaultCollapseConfigs()[3], base.FindConfigForPath("/var/run/pod0.sock"))
+	assert.Nil(t, base.GetStoredPaths("opens"))
+	result, err = dynamicpathdetector.AnalyzeOpens(opens, base, nil)
+	require.NoError(t, err)
+	assert.Len(t, result, len(opens))
+
+	// A prefix without a config is added in the view only.
+	added := base.WithTemporaryConfig(dynamicpathdetector.CollapseConfig{Prefix: "/tmp", Threshold: 2})
+	assert.Equal(t, 2, added.EffectiveThreshold("/tmp/x"))
+	assert.Equal(t, dynamicpathdetector.OpenDynamicThreshold, base.EffectiveThreshold("/tmp/x"))
+}
@@ -0,0 +1,120 @@
+package dynamicpathdetectortests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// percentOpens returns opens of small files below /data/small, big ones
+// below /data/big and other ones below /other.
+func percentOpens(small, big, other int) []types.OpenCalls {
+	var opens []types.OpenCalls
+	for dir, n := range map[string]int{"/data/small": small, "/data/big": big, "/other": other} {
+		for i := 0; i < n; i++ {
+			opens = append(opens, types.OpenCalls{Path: fmt.Sprintf("%s/f%d", dir, i), Flags: []string{"O_RDONLY"}})
+		}
+	}
+	return opens
+}
+
+func TestThresholdPercentAnalyzeOpens(t *testing.T) {
+	configs := []dynamicpathdetector.CollapseConfig{{Prefix: "/data", ThresholdPercent: 20}}
+
+	// The same shape at two sizes collapses the same way: 20% of 100
+	// opens is 20 children, 20% of 200 is 40.
+	for _, scale := range []int{1, 2} {
+		t.Run(fmt.Sprintf("x%d", scale), func(t *testing.T) {
+			analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(1000, configs)
+			result, err := dynamicpathdetector.AnalyzeOpens(percentOpens(15*scale, 25*scale, 60*scale), analyzer, nil)
+			require.NoError(t, err)
+
+			var small, big, other int
+			for _, open := range result {
+				switch {
+				case strings.HasPrefix(open.Path, "/data/small/"):
+					small++
+				case strings.HasPrefix(open.Path, "/data/big/"):
+					big++
+					assert.Equal(t, "/data/big/⋯", open.Path)
+				case strings.HasPrefix(open.Path, "/other/"):
+					other++
+				}
+			}
+			assert.Equal(t, 15*scale, small, "below 20% of the opens stays literal")
+			assert.Equal(t, 1, big, "above 20% of the opens collapses")
+			assert.Equal(t, 60*scale, other, "the absolute default applies outside the prefix")
+			assert.Equal(t, 20*scale, analyzer.EffectiveThreshold("/data/big/f0"))
+		})
+	}
+}
+
+func TestThresholdPercentAnalyzePath(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(1000, []dynamicpathdetector.CollapseConfig{{Prefix: "/data", ThresholdPercent: 50}})
+	var paths []string
+	for i := 0; i < 10; i++ {
+		paths = append(paths, fmt.Sprintf("/data/f%d", i))
+	}
+
+	// Without a declared total the prefix does not collapse.
+	for range 2 {
+		analyzeAll(t, analyzer, paths...)
+		for _, p := range paths {
+			_, err := analyzer.AnalyzePath(p, "other")
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, dynamicpathdetector.NeverCollapse, analyzer.EffectiveThreshold("/data/f0"))
+	got, err := analyzer.AnalyzePath("/data/f0", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/f0", got)
+
+	// 50% of 10 is 5: the 10 children exceed it on the next walk.
+	analyzer.AddPathTotal("opens", len(paths))
+	got, err = analyzer.AnalyzePath("/data/f0", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/⋯", got)
+
+	// Other identifiers keep their own total.
+	got, err = analyzer.AnalyzePath("/data/f0", "other")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/f0", got)
+
+	// Small totals never come to Threshold 1's immediate wildcard.
+	small := dynamicpathdetector.NewPathAnalyzerWithConfigs(1000, []dynamicpathdetector.CollapseConfig{{Prefix: "/data", ThresholdPercent: 1}})
+	small.AddPathTotal("opens", 3)
+	assert.Equal(t, 2, small.EffectiveThreshold("/data/f0"))
+
+	analyzer.Reset()
+	assert.Equal(t, dynamicpathdetector.NeverCollapse, analyzer.EffectiveThreshold("/data/f0"), "Reset drops the totals")
+}
+
+func TestThresholdPercentValidation(t *testing.T) {
+	assert.NoError(t, dynamicpathdetector.ValidateConfigs([]dynamicpathdetector.CollapseConfig{{Prefix: "/data", ThresholdPercent: 12.5}}))
+
+	err := dynamicpathdetector.ValidateConfigs([]dynamicpathdetector.CollapseConfig{
+		{Prefix: "/both", Threshold: 10, ThresholdPercent: 20},
+		{Prefix: "/over", ThresholdPercent: 150},
+		{Prefix: "/negative", ThresholdPercent: -1},
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "both threshold 10 and threshold percent 20 set")
+	assert.ErrorContains(t, err, "threshold percent 150 out of range")
+	assert.ErrorContains(t, err, "threshold percent -1 out of range")
+}
+
+func TestThresholdPercentSurvivesJSON(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(1000, []dynamicpathdetector.CollapseConfig{{Prefix: "/data", ThresholdPercent: 50}})
+	analyzer.AddPathTotal("opens", 10)
+
+	data, err := analyzer.MarshalJSON()
+	require.NoError(t, err)
+	restored := &dynamicpathdetector.PathAnalyzer{}
+	require.NoError(t, restored.UnmarshalJSON(data))
+	assert.Equal(t, 5, restored.EffectiveThreshold("/data/f0"))
+}
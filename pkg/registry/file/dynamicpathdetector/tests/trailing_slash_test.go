@@ -0,0 +1,119 @@
+package dynamicpathdetectortests
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	types "github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTrailingSlashAnalyzer(threshold int, configs []dynamicpathdetector.CollapseConfig) *dynamicpathdetector.PathAnalyzer {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(threshold, configs)
+	analyzer.PreserveTrailingSlash = true
+	return analyzer
+}
+
+func TestPreserveTrailingSlashKeepsBothForms(t *testing.T) {
+	analyzer := newTrailingSlashAnalyzer(dynamicpathdetector.OpenDynamicThreshold, nil)
+	for _, p := range []string{"/data/cache/", "/data/cache", "/data/cache/x", "/data/tmp//", "/"} {
+		peeked, err := analyzer.PeekPath(p, "opens")
+		require.NoError(t, err)
+		got, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, got, peeked, p)
+	}
+	for p, want := range map[string]string{
+		"/data/cache/": "/data/cache/",
+		"/data/cache":  "/data/cache",
+		"/data/tmp//":  "/data/tmp/",
+		"/data/tmp":    "/data/tmp",
+		"/":            "/",
+	} {
+		got, err := analyzer.PeekPath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+	}
+	assert.Equal(t, []string{"/", "/data/cache", "/data/cache/", "/data/cache/x", "/data/tmp/"}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestPreserveTrailingSlashOff(t *testing.T) {
+	analyzer := dynamicpathdetector.NewPathAnalyzerWithConfigs(dynamicpathdetector.OpenDynamicThreshold, nil)
+	got, err := analyzer.AnalyzePath("/data/cache/", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/data/cache", got)
+	assert.Equal(t, []string{"/data/cache"}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestPreserveTrailingSlashCollapse(t *testing.T) {
+	const threshold = 3
+	analyzer := newTrailingSlashAnalyzer(threshold, []dynamicpathdetector.CollapseConfig{
+		{Prefix: "/instant", Threshold: 1},
+	})
+	var paths []string
+	for i := 0; i < threshold+2; i++ {
+		paths = append(paths, fmt.Sprintf("/data/dir%d/", i))
+	}
+	analyzeAll(t, analyzer, append(paths, "/data/dir0", "/instant/a/")...)
+
+	for p, want := range map[string]string{
+		"/data/new/":  "/data/⋯/",
+		"/data/new":   "/data/⋯",
+		"/instant/b/": "/instant/*",
+	} {
+		peeked, err := analyzer.PeekPath(p, "opens")
+		require.NoError(t, err)
+		got, err := analyzer.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+		assert.Equal(t, want, peeked, "PeekPath(%s)", p)
+	}
+	assert.Equal(t, []string{"/data/⋯", "/data/⋯/", "/instant/*"}, analyzer.GetStoredPaths("opens"))
+}
+
+func TestPreserveTrailingSlashAnalyzeOpens(t *testing.T) {
+	analyzer := newTrailingSlashAnalyzer(dynamicpathdetector.OpenDynamicThreshold, nil)
+	result, err := dynamicpathdetector.AnalyzeOpens([]types.OpenCalls{
+		{Path: "/data/cache/", Flags: []string{"O_DIRECTORY"}},
+		{Path: "/data/cache", Flags: []string{"O_RDONLY"}},
+	}, analyzer, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpenCalls{
+		{Path: "/data/cache", Flags: []string{"O_RDONLY"}},
+		{Path: "/data/cache/", Flags: []string{"O_DIRECTORY"}},
+	}, result)
+}
+
+func TestPreserveTrailingSlashSurvivesJSON(t *testing.T) {
+	analyzer := newTrailingSlashAnalyzer(dynamicpathdetector.OpenDynamicThreshold, nil)
+	analyzeAll(t, analyzer, "/data/cache/", "/etc/hosts")
+
+	data, err := json.Marshal(analyzer)
+	require.NoError(t, err)
+	var restored dynamicpathdetector.PathAnalyzer
+	require.NoError(t, json.Unmarshal(data, &restored))
+	assert.True(t, restored.PreserveTrailingSlash)
+	assert.Equal(t, analyzer.GetStoredPaths("opens"), restored.GetStoredPaths("opens"))
+	got, err := restored.AnalyzePath("/etc/ssl/", "opens")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/ssl/", got)
+}
+
+func TestPreserveTrailingSlashWithCollapseAdjacent(t *testing.T) {
+	paths := append(adjacentGridPaths(5), "/data/1/2/", "/data/3/4/5/", "/data/9/9/")
+	plain := newTrailingSlashAnalyzer(3, nil)
+	merged := newTrailingSlashAnalyzer(3, nil)
+	merged.CollapseAdjacent = true
+	for _, p := range paths {
+		want, err := plain.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		got, err := merged.AnalyzePath(p, "opens")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, p)
+	}
+	assert.Equal(t, plain.GetStoredPaths("opens"), merged.GetStoredPaths("opens"))
+	assert.Contains(t, plain.GetStoredPaths("opens"), "/data/⋯/⋯/")
+}
@@ -0,0 +1,167 @@
+package dynamicpathdetectortests
+
+import (
+	"testing"
+
+	"github.com/kubescape/storage/pkg/registry/file/dynamicpathdetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []dynamicpathdetector.CollapseConfig
+		wantErr string
+	}{
+		{name: "nil"},
+		{name: "defaults", configs: dynamicpathdetector.DefaultCollapseConfigs()},
+		{name: "test configs", configs: testCollapseConfigs},
+		{
+			name: "nested prefixes are valid",
+			configs: []dynamicpathdetector.CollapseConfig{
+				{Prefix: "/var", Threshold: 10},
+				{Prefix: "/var/run", Threshold: 3},
+			},
+		},
+		{
+			name:    "empty prefix",
+			configs: []dynamicpathdetector.CollapseConfig{{Prefix: "", Threshold: 10}},
+			wantErr: "collapse config 0: empty prefix",
+		},
+		{
+			name:    "relative prefix",
+			configs: []dynamicpathdetector.CollapseConfig{{Prefix: "var/run", Threshold: 10}},
+			wantErr: `collapse config 0: prefix "var/run" is not an absolute clean path`,
+		},
+		{
+			name:    "trailing slash",
+			configs: []dynamicpathdetector.CollapseConfig{{Prefix: "/var/run/", Threshold: 10}},
+			wantErr: `collapse config 0: prefix "/var/run/" is not an absolute clean path`,
+		},
+		{
+			name: "never collapse is valid",
+			configs: []dynamicpathdetector.CollapseConfig{
+				{Prefix: "/etc/sensitive", Threshold: dynamicpathdetector.NeverCollapse},
+				{Prefix: "/usr/lib", Threshold: 10, ExtensionThresholds: map[string]int{".so": dynamicpathdetector.NeverCollapse}},
+			},
+		},
+		{
+			name:    "negative threshold",
+			configs: []dynamicpathdetector.CollapseConfig{{Prefix: "/etc", Threshold: -1}},
+			wantErr: "collapse config 0 (/etc): negative threshold -1",
+		},
+		{
+			name: "negative extension threshold",
+			configs: []dynamicpathdetector.CollapseConfig{
+				{Prefix: "/usr/lib", Threshold: 10, ExtensionThresholds: map[string]int{".so": -5}},
+			},
+			wantErr: `collapse config 0 (/usr/lib): negative threshold -5 for extension ".so"`,
+		},
+		{
+			name:    "negative max depth",
+			configs: []dynamicpathdetector.CollapseConfig{{Prefix: "/cache", Threshold: 10, MaxDepth: -2}},
+			wantErr: "collapse config 0 (/cache): negative max depth -2",
+		},
+		{
+			name: "duplicate prefix",
+			configs: []dynamicpathdetector.CollapseConfig{
+				{Prefix: "/opt", Threshold: 5},
+				{Prefix: "/etc", Threshold: 5},
+				{Prefix: "/opt", Threshold: 50},
+			},
+			wantErr: `collapse config 2: prefix "/opt" duplicates config 0`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dynamicpathdetector.ValidateConfigs(tt.configs)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateConfigs_ReportsEveryProblem(t *testing.T) {
+	err := dynamicpathdetector.ValidateConfigs([]dynamicpathdetector.CollapseConfig{
+		{Prefix: "", Threshold: -1},
+		{Prefix: "/a", Threshold: 1},
+		{Prefix: "/a", Threshold: 1},
+	})
+	require.Error(t, err)
+	assert.Equal(t, "collapse config 0: empty prefix\n"+
+		"collapse config 0 (): negative threshold -1\n"+
+		`collapse config 2: prefix "/a" duplicates config 1`, err.Error())
+}
+
+func TestNewValidatedPathAnalyzer(t *testing.T) {
+	analyzer, err := dynamicpathdetector.NewValidatedPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold, testCollapseConfigs)
+	require.NoError(t, err)
+	assert.Equal(t, configThreshold("/var/run"), analyzer.FindConfigForPath("/var/run/x").Threshold)
+
+	_, err = dynamicpathdetector.NewValidatedPathAnalyzer(dynamicpathdetector.OpenDynamicThreshold, []dynamicpathdetector.CollapseConfig{{Prefix: "/etc", Threshold: -1}})
+	assert.ErrorContains(t, err, "invalid collapse configuration")
+
+	_, err = dynamicpathdetector.NewValidatedPathAnalyzer(-1, nil)
+	assert.EqualError(t, err, "invalid collapse configuration: negative default threshold -1")
+
+	_, err = dynamicpathdetector.NewValidatedPathAnalyzer(dynamicpathdetector.NeverCollapse, nil)
+	assert.NoError(t, err)
+}
+
+func TestValidatePattern(t *testing.T) {
+	for _, pattern := range []string{
+		"/",
+		"*",
+		"/etc/passwd",
+		"/etc/",
+		"/etc/*",
+		"/proc/⋯/task/⋯/status",
+		"/data/⋯/⋯",
+		"/var/*/log",
+		"/dev/tty?",
+		":80/api/⋯",
+		"relative/path",
+	} {
+		assert.NoError(t, dynamicpathdetector.ValidatePattern(pattern), pattern)
+	}
+
+	assert.ErrorIs(t, dynamicpathdetector.ValidatePattern(""), dynamicpathdetector.ErrEmptyPattern)
+	for pattern, wantErr := range map[string]string{
+		"/a//*":     `invalid dynamic path pattern "/a//*": empty segment`,
+		"//":        `invalid dynamic path pattern "//": empty segment`,
+		"/a/../b":   `invalid dynamic path pattern "/a/../b": ".." segment never matches a cleaned path`,
+		"/a/**":     `invalid dynamic path pattern "/a/**": ** is not supported; * matches any number of segments`,
+		"/a/…/b":    `invalid dynamic path pattern "/a/…/b": "…" contains … (U+2026); the dynamic identifier is ⋯ (U+22EF)`,
+		"/a/b*c":    `invalid dynamic path pattern "/a/b*c": "b*c" mixes a wildcard with other characters and only matches literally`,
+		"/lib.so.⋯": `invalid dynamic path pattern "/lib.so.⋯": "lib.so.⋯" mixes a wildcard with other characters and only matches literally`,
+		"/a/*/*":    `invalid dynamic path pattern "/a/*/*": */* is redundant; use a single *`,
+		"/a/⋯/*":    `invalid dynamic path pattern "/a/⋯/*": ⋯ next to * is ambiguous; use * alone or only ⋯ segments`,
+		"/a/*/⋯/b":  `invalid dynamic path pattern "/a/*/⋯/b": * next to ⋯ is ambiguous; use * alone or only ⋯ segments`,
+	} {
+		err := dynamicpathdetector.ValidatePattern(pattern)
+		require.Error(t, err, pattern)
+		assert.ErrorIs(t, err, dynamicpathdetector.ErrInvalidPattern, pattern)
+		assert.EqualError(t, err, wantErr, pattern)
+	}
+}
+
+func TestValidatePatternWithOptions(t *testing.T) {
+	opts := dynamicpathdetector.CompareDynamicOpts{DynamicIdentifier: "{d}", WildcardIdentifier: "**"}
+	for _, pattern := range []string{"/etc/**", "/proc/{d}/task/{d}", "/var/**/log", "/a/b*c", "/a/…"} {
+		assert.NoError(t, dynamicpathdetector.ValidatePatternWithOptions(pattern, opts), pattern)
+	}
+	for pattern, wantErr := range map[string]string{
+		"/a/x**":      `invalid dynamic path pattern "/a/x**": "x**" mixes a wildcard with other characters and only matches literally`,
+		"/a/**/**":    `invalid dynamic path pattern "/a/**/**": **/** is redundant; use a single **`,
+		"/a/{d}/**":   `invalid dynamic path pattern "/a/{d}/**": {d} next to ** is ambiguous; use ** alone or only {d} segments`,
+		"/lib.so.{d}": `invalid dynamic path pattern "/lib.so.{d}": "lib.so.{d}" mixes a wildcard with other characters and only matches literally`,
+	} {
+		err := dynamicpathdetector.ValidatePatternWithOptions(pattern, opts)
+		assert.ErrorIs(t, err, dynamicpathdetector.ErrInvalidPattern, pattern)
+		assert.EqualError(t, err, wantErr, pattern)
+	}
+}
@@ -1,5 +1,7 @@
 package dynamicpathdetector
 
+import "sync"
+
 // --- Identifier constants ---
 // DynamicIdentifier matches exactly one path segment (single-segment wildcard).
 // WildcardIdentifier matches zero-or-more path segments (glob-style **).
@@ -12,18 +14,79 @@ const (
 // OpenDynamicThreshold is the fallback threshold used by AnalyzeOpens when
 // no more-specific CollapseConfig matches the walked path prefix.
 // EndpointDynamicThreshold is the counterpart for AnalyzeEndpoints.
+// ExecDynamicThreshold is the counterpart for AnalyzeExecs.
 const (
 	OpenDynamicThreshold     = 50
 	EndpointDynamicThreshold = 100
+	ExecDynamicThreshold     = 50
 )
 
+// NeverCollapse is the threshold that pins a prefix: its directories keep
+// every child literal regardless of cardinality. It is the only threshold
+// below 1 that is valid; ValidateConfigs rejects negative ones.
+const NeverCollapse = 0
+
 // --- Collapse configuration ---
 // CollapseConfig controls the threshold at which children of a trie node
 // (under the given path Prefix) are collapsed into a dynamic node (⋯).
-// Longest-prefix wins at analysis time.
+// Longest-prefix wins at analysis time. A Threshold of NeverCollapse (0)
+// pins the prefix, e.g. a small security-critical /etc/sensitive whose
+// files must stay individually visible; negative thresholds are invalid.
+// The pin covers directories at and below Prefix; if the parent directory
+// collapses, Prefix is folded into the parent's ⋯ like any other child,
+// so pin the parent too when that matters.
+//
+// ExtensionThresholds optionally overrides Threshold for a directory whose
+// walked path ends in a file with the given extension (keyed with the
+// leading dot, as returned by path.Ext — e.g. ".so"). This lets shared
+// libraries collapse faster than config files under the same prefix.
+// Extensions not listed fall back to Threshold.
+//
+// CollapseNumericSegments makes a purely numeric child segment (PIDs,
+// timestamps, inode numbers) collapse to ⋯ on first insert, without
+// waiting for Threshold. As with any ⋯ child, the node's other children
+// are folded into it. CollapseEntropicSegments does the same for UUIDs
+// (8-4-4-4-12 hex) and hex strings of 32 or more characters, such as
+// content hashes.
+//
+// MaxDepth, when positive, bounds how deep paths under Prefix go, whatever
+// their sibling counts: like PathAnalyzer.MaxDepth, components past the
+// MaxDepth-th fold into a single trailing ⋯ (or * for more than one),
+// but depth is counted from Prefix's last component. With Prefix /cache
+// and MaxDepth 2, /cache/a/b becomes /cache/a/⋯ and /cache/a/b/c/d
+// becomes /cache/a/*. It is independent of Threshold.
+//
+// PreserveHidden keeps dotfiles visible: a child segment starting with "."
+// (.bashrc, .ssh) under Prefix is never folded into ⋯ or *, and does not
+// count towards Threshold, so /home/user/.bashrc stays literal while the
+// regular files next to it collapse.
+//
+// ThresholdPercent, when positive, replaces Threshold (set one or the
+// other) with a share of the paths fed to the trie: with 20, a directory
+// collapses once its unique children exceed 20% of the total, whatever
+// the size of the image. Totals are only known once the paths are in, so
+// this takes two phases: the AnalyzeOpens functions declare the number of
+// opens they are given before walking them, and callers of AnalyzePath
+// declare theirs with PathAnalyzer.AddPathTotal. Until a total is
+// declared the prefix does not collapse; once it is, a percentage that
+// comes to less than 2 children counts as 2, so it never triggers
+// Threshold 1's immediate wildcard. ExtensionThresholds stay absolute.
+//
+// MinSegmentsBeforeCollapse, when positive, keeps the children of
+// directories with fewer components than that literal, whatever their
+// count and shape: with Prefix / and 1, /tmp0 and /tmp1 never become /⋯
+// or /*, which would match everything, while the children of /tmp still
+// collapse. Depth is counted from the root, not from Prefix.
 type CollapseConfig struct {
-	Prefix    string
-	Threshold int
+	Prefix                    string
+	Threshold                 int
+	ThresholdPercent          float64 `json:",omitempty"`
+	ExtensionThresholds       map[string]int
+	CollapseNumericSegments   bool
+	CollapseEntropicSegments  bool
+	MaxDepth                  int
+	PreserveHidden            bool
+	MinSegmentsBeforeCollapse int `json:",omitempty"`
 }
 
 // defaultCollapseConfigs carries the per-prefix thresholds we've found
@@ -55,20 +118,106 @@ var DefaultCollapseConfig = CollapseConfig{
 
 // --- Trie types ---
 
+// SegmentNode is one path segment in the trie. Terminal marks a node at
+// which some analyzed path ended, so a path like /a/b stays visible to
+// GetStoredPaths even after /a/b/c gives the node children. Hits counts
+// the analyzed paths that walked through the node; unlike Count (distinct
+// children) it measures traffic, and is what Prune compares against.
+// AnalyzeOpens, AnalyzeEndpoints and AnalyzeExecs walk their input twice
+// but count each entry once, so an open listed three times has 3 Hits.
+// Dir is Terminal for paths analyzed with a trailing slash when the
+// analyzer has PreserveTrailingSlash set; a node can be both.
+//
+// Children is nil until the node gets its first child: most nodes in an
+// open profile are leaves, and an empty map per leaf would be most of the
+// trie's memory. Code reading the trie must tolerate a nil Children.
 type SegmentNode struct {
 	SegmentName string
 	Count       int
 	Children    map[string]*SegmentNode
+	Terminal    bool
+	Dir         bool `json:",omitempty"`
+	Hits        int
 }
 
+// PathAnalyzer learns path tries per identifier and collapses
+// high-cardinality segments. MaxDepth, when positive, bounds how deep a
+// trie can grow: components past the MaxDepth-th are folded into a single
+// trailing ⋯, or * when there is more than one, so adversarial paths with
+// thousands of segments cannot exhaust memory. Zero means unlimited.
+//
+// CollapseAdjacent merges consecutive ⋯ nodes in the trie into a single
+// node as paths are analyzed, instead of only folding "⋯/⋯" into * in the
+// returned string. AnalyzePath and GetStoredPaths return the same paths
+// either way; the trie just holds one node per run of ⋯ levels, which
+// saves memory and traversal on deeply collapsed profiles. Set it before
+// the first AnalyzePath call.
+//
+// PreserveTrailingSlash keeps directory opens like /data/cache/ apart
+// from file opens of /data/cache: AnalyzePath and PeekPath return them
+// with the trailing slash, and GetStoredPaths reports each form that was
+// analyzed. A path ending in * keeps no slash, since * already covers
+// it. Both forms count as the same child for collapse thresholds, and
+// CompareDynamic still treats them as equal. Set it before the first
+// AnalyzePath call.
+//
+// MinChildHits, when above 1, weights collapse by traffic: a directory
+// only collapses once more than threshold of its children have each been
+// walked at least MinChildHits times (see SegmentNode.Hits), so a few
+// busy children next to many one-off ones stay literal, one-offs
+// included. It suits analyzers fed one AnalyzePath call per event, or
+// AnalyzeOpens and AnalyzeEndpoints input that keeps repeated entries;
+// over deduplicated input every path has the same Hits.
+//
+// The methods of a PathAnalyzer are safe for concurrent use, so opens and
+// endpoints can be analyzed in parallel with one analyzer. Reading or
+// writing RootNodes directly bypasses that locking and must not race
+// with method calls.
 type PathAnalyzer struct {
-	RootNodes  map[string]*SegmentNode
-	threshold  int              // fallback threshold when no config matches
-	configs    []CollapseConfig // per-prefix overrides; longest prefix wins
-	defaultCfg CollapseConfig   // explicit fallback; equivalent to {Prefix:"/", Threshold: threshold}
+	mu                    sync.RWMutex
+	RootNodes             map[string]*SegmentNode
+	MaxDepth              int
+	CollapseAdjacent      bool
+	PreserveTrailingSlash bool
+	MinChildHits          int
+	threshold             int                         // fallback threshold when no config matches
+	configs               []CollapseConfig            // per-prefix overrides; longest prefix wins
+	identifierConfigs     map[string][]CollapseConfig // replaces configs for the listed identifiers
+	totals                map[string]int              // paths fed per identifier, for ThresholdPercent
+	defaultCfg            CollapseConfig              // explicit fallback; equivalent to {Prefix:"/", Threshold: threshold}
+	dynamicIdentifier     string                      // emitted for collapsed segments; DynamicIdentifier by default
+	wildcardIdentifier    string                      // emitted for collapsed runs; WildcardIdentifier by default
 }
 
 func (sn *SegmentNode) IsNextDynamic() bool {
-	_, exists := sn.Children[DynamicIdentifier]
+	_, exists := sn.child(DynamicIdentifier)
 	return exists
 }
+
+// child returns the child named name, if any. Safe on a nil Children.
+func (sn *SegmentNode) child(name string) (*SegmentNode, bool) {
+	c, ok := sn.Children[name]
+	return c, ok
+}
+
+// newSegmentNode returns an empty node named name. Its Children map is
+// allocated lazily by setChild, since most nodes in an open profile are
+// leaves that never get a child.
+func newSegmentNode(name string) *SegmentNode {
+	return &SegmentNode{SegmentName: name}
+}
+
+// setChild stores child under name, allocating sn.Children on first use.
+func (sn *SegmentNode) setChild(name string, child *SegmentNode) {
+	if sn.Children == nil {
+		sn.Children = make(map[string]*SegmentNode)
+	}
+	sn.Children[name] = child
+}
+
+// setOnlyChild replaces all of sn's children with child, as a collapse
+// does. A fresh map is allocated rather than clearing the old one, which
+// would keep the buckets of every collapsed sibling alive.
+func (sn *SegmentNode) setOnlyChild(name string, child *SegmentNode) {
+	sn.Children = map[string]*SegmentNode{name: child}
+}
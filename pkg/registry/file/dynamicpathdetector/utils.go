@@ -14,3 +14,64 @@ func MergeStrings(existing, new []string) []string {
 
 	return existing
 }
+
+// isHiddenSegment reports whether segment names a dotfile such as .bashrc.
+func isHiddenSegment(segment string) bool {
+	return len(segment) > 1 && segment[0] == '.'
+}
+
+// isAllDigits reports whether s is a non-empty run of ASCII digits.
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// minHashLen is the shortest hex string isLongHex treats as a hash; 32 is
+// an MD5 digest, the shortest common one.
+const minHashLen = 32
+
+// isUUID reports whether s has the 8-4-4-4-12 hex layout of a UUID. Any
+// version and either case are accepted.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isLongHex reports whether s is a hex string of at least minHashLen
+// characters.
+func isLongHex(s string) bool {
+	if len(s) < minHashLen {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isHexDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,148 @@
+package dynamicpathdetector
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrInvalidPattern is wrapped by the errors ValidatePattern returns for a
+// malformed pattern.
+var ErrInvalidPattern = errors.New("invalid dynamic path pattern")
+
+// ValidateConfigs reports every problem in configs that would make
+// NewPathAnalyzerWithConfigs behave surprisingly instead of failing:
+//
+//   - an empty Prefix, which matches nothing;
+//   - a Prefix that is not absolute and clean ("var/run", "/var/run/"),
+//     which never matches at a path boundary;
+//   - a negative Threshold or ExtensionThresholds value (use
+//     NeverCollapse, 0, to pin a prefix);
+//   - a ThresholdPercent outside 0 to 100, or one set together with a
+//     positive Threshold, which it would silently replace;
+//   - a negative MaxDepth (0 means unbounded) or
+//     MinSegmentsBeforeCollapse;
+//   - the same Prefix configured twice, where only the first entry is
+//     ever used.
+//
+// Nested prefixes such as /var and /var/run are valid: the longest match
+// wins. The returned error joins one error per problem; nil means configs
+// is valid.
+func ValidateConfigs(configs []CollapseConfig) error {
+	var errs []error
+	seen := make(map[string]int, len(configs))
+	for i, cfg := range configs {
+		switch {
+		case cfg.Prefix == "":
+			errs = append(errs, fmt.Errorf("collapse config %d: empty prefix", i))
+		case cfg.Prefix[0] != '/' || path.Clean(cfg.Prefix) != cfg.Prefix:
+			errs = append(errs, fmt.Errorf("collapse config %d: prefix %q is not an absolute clean path", i, cfg.Prefix))
+		}
+		if cfg.Threshold < 0 {
+			errs = append(errs, fmt.Errorf("collapse config %d (%s): negative threshold %d", i, cfg.Prefix, cfg.Threshold))
+		}
+		if cfg.ThresholdPercent < 0 || cfg.ThresholdPercent > 100 {
+			errs = append(errs, fmt.Errorf("collapse config %d (%s): threshold percent %g out of range", i, cfg.Prefix, cfg.ThresholdPercent))
+		} else if cfg.ThresholdPercent > 0 && cfg.Threshold > 0 {
+			errs = append(errs, fmt.Errorf("collapse config %d (%s): both threshold %d and threshold percent %g set", i, cfg.Prefix, cfg.Threshold, cfg.ThresholdPercent))
+		}
+		if cfg.MaxDepth < 0 {
+			errs = append(errs, fmt.Errorf("collapse config %d (%s): negative max depth %d", i, cfg.Prefix, cfg.MaxDepth))
+		}
+		if cfg.MinSegmentsBeforeCollapse < 0 {
+			errs = append(errs, fmt.Errorf("collapse config %d (%s): negative min segments before collapse %d", i, cfg.Prefix, cfg.MinSegmentsBeforeCollapse))
+		}
+		for ext, threshold := range cfg.ExtensionThresholds {
+			if threshold < 0 {
+				errs = append(errs, fmt.Errorf("collapse config %d (%s): negative threshold %d for extension %q", i, cfg.Prefix, threshold, ext))
+			}
+		}
+		if cfg.Prefix == "" {
+			continue
+		}
+		if first, ok := seen[cfg.Prefix]; ok {
+			errs = append(errs, fmt.Errorf("collapse config %d: prefix %q duplicates config %d", i, cfg.Prefix, first))
+			continue
+		}
+		seen[cfg.Prefix] = i
+	}
+	return errors.Join(errs...)
+}
+
+// NewValidatedPathAnalyzer is NewPathAnalyzerWithConfigs that rejects
+// configs ValidateConfigs finds invalid, as well as a negative
+// defaultThreshold; NeverCollapse is the only one below 1 it accepts.
+func NewValidatedPathAnalyzer(defaultThreshold int, configs []CollapseConfig) (*PathAnalyzer, error) {
+	err := ValidateConfigs(configs)
+	if defaultThreshold < 0 {
+		err = errors.Join(fmt.Errorf("negative default threshold %d", defaultThreshold), err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid collapse configuration: %w", err)
+	}
+	return NewPathAnalyzerWithConfigs(defaultThreshold, configs), nil
+}
+
+// ValidatePattern reports the first problem in a user-supplied profile
+// pattern that CompareDynamic would silently match in a surprising way:
+//
+//   - an empty segment ("/a//b"); a single trailing slash is fine;
+//   - a "." or ".." segment, which never matches since runtime paths are
+//     compared cleaned;
+//   - "**", which is not a glob here; * already spans any number of
+//     segments;
+//   - "…" (U+2026) where the dynamic identifier ⋯ (U+22EF) was meant;
+//   - * or ⋯ inside a longer segment ("b*c", "lib.so.⋯"), which only
+//     matches literally;
+//   - * next to another * or a ⋯, which is redundant or ambiguous.
+//
+// Runs of ⋯ ("/a/⋯/⋯") and ? inside a segment are valid. An empty pattern
+// yields ErrEmptyPattern; every other error wraps ErrInvalidPattern.
+func ValidatePattern(pattern string) error {
+	return ValidatePatternWithOptions(pattern, CompareDynamicOpts{})
+}
+
+// ValidatePatternWithOptions is ValidatePattern for patterns matched with
+// the identifiers of opts, which take the place of ⋯ and * in every check.
+// The … check only applies to ⋯, and ** is fine when it is the wildcard
+// identifier.
+func ValidatePatternWithOptions(pattern string, opts CompareDynamicOpts) error {
+	opts = opts.withDefaultIdentifiers()
+	dynamic, wildcard := opts.DynamicIdentifier, opts.WildcardIdentifier
+	if pattern == "" {
+		return ErrEmptyPattern
+	}
+	if pattern == "/" {
+		return nil
+	}
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		var problem string
+		switch {
+		case segment == "":
+			if i == 0 || i == len(segments)-1 {
+				continue // leading anchor or trailing slash
+			}
+			problem = "empty segment"
+		case segment == "." || segment == "..":
+			problem = fmt.Sprintf("%q segment never matches a cleaned path", segment)
+		case segment == "**" && wildcard != "**":
+			problem = fmt.Sprintf("** is not supported; %s matches any number of segments", wildcard)
+		case dynamic == DynamicIdentifier && strings.Contains(segment, "…"):
+			problem = fmt.Sprintf("%q contains … (U+2026); the dynamic identifier is %s (U+22EF)", segment, DynamicIdentifier)
+		case segment != wildcard && strings.Contains(segment, wildcard),
+			segment != dynamic && strings.Contains(segment, dynamic):
+			problem = fmt.Sprintf("%q mixes a wildcard with other characters and only matches literally", segment)
+		case i > 0 && segment == wildcard && segments[i-1] == wildcard:
+			problem = fmt.Sprintf("%s/%s is redundant; use a single %s", wildcard, wildcard, wildcard)
+		case i > 0 && (segment == wildcard && segments[i-1] == dynamic ||
+			segment == dynamic && segments[i-1] == wildcard):
+			problem = fmt.Sprintf("%s next to %s is ambiguous; use %s alone or only %s segments", segments[i-1], segment, wildcard, dynamic)
+		default:
+			continue
+		}
+		return fmt.Errorf("%w %q: %s", ErrInvalidPattern, pattern, problem)
+	}
+	return nil
+}
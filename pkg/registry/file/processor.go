@@ -5,6 +5,7 @@ import (
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/kubescape/storage/pkg/apis/softwarecomposition"
+	"github.com/kubescape/storage/pkg/utils"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -65,15 +66,42 @@ func DeflateRulePolicies(in map[string]softwarecomposition.RulePolicy) map[strin
 		return nil
 	}
 	for key, item := range in {
-		item.AllowedProcesses = DeflateSortString(item.AllowedProcesses)
+		item.AllowedProcesses = utils.DeflateStringSlice(item.AllowedProcesses)
 		in[key] = item
 	}
 	return in
 }
 
-func DeflateSortString(in []string) []string {
-	if in == nil {
+// MergeRulePolicies combines two rule policy maps, e.g. from different
+// replicas. For a rule ID present in both, AllowedProcesses is the sorted
+// union and AllowedContainer is true if either side allows it. The inputs
+// are not modified; the result is nil only when both inputs are nil.
+func MergeRulePolicies(a, b map[string]softwarecomposition.RulePolicy) map[string]softwarecomposition.RulePolicy {
+	if a == nil && b == nil {
 		return nil
 	}
-	return mapset.Sorted(mapset.NewThreadUnsafeSet(in...))
+	out := make(map[string]softwarecomposition.RulePolicy, len(a)+len(b))
+	for _, in := range []map[string]softwarecomposition.RulePolicy{a, b} {
+		for key, item := range in {
+			merged, exists := out[key]
+			if !exists {
+				out[key] = softwarecomposition.RulePolicy{
+					AllowedProcesses: utils.DeflateStringSlice(item.AllowedProcesses),
+					AllowedContainer: item.AllowedContainer,
+				}
+				continue
+			}
+			if merged.AllowedProcesses != nil || item.AllowedProcesses != nil {
+				merged.AllowedProcesses = utils.DeflateStringSlice(append(append([]string{}, merged.AllowedProcesses...), item.AllowedProcesses...))
+			}
+			merged.AllowedContainer = merged.AllowedContainer || item.AllowedContainer
+			out[key] = merged
+		}
+	}
+	return out
+}
+
+// DeflateSortString is utils.DeflateStringSlice, kept for existing callers.
+func DeflateSortString(in []string) []string {
+	return utils.DeflateStringSlice(in)
 }
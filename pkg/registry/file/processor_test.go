@@ -3,6 +3,7 @@ package file
 import (
 	"testing"
 
+	"github.com/kubescape/storage/pkg/apis/softwarecomposition"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,3 +48,67 @@ func TestDeflateSortString(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeRulePolicies(t *testing.T) {
+	tests := []struct {
+		name string
+		a    map[string]softwarecomposition.RulePolicy
+		b    map[string]softwarecomposition.RulePolicy
+		want map[string]softwarecomposition.RulePolicy
+	}{
+		{
+			name: "both nil",
+		},
+		{
+			name: "one nil",
+			a: map[string]softwarecomposition.RulePolicy{
+				"R0001": {AllowedProcesses: []string{"sh", "bash", "sh"}},
+			},
+			want: map[string]softwarecomposition.RulePolicy{
+				"R0001": {AllowedProcesses: []string{"bash", "sh"}},
+			},
+		},
+		{
+			name: "disjoint keys",
+			a: map[string]softwarecomposition.RulePolicy{
+				"R0001": {AllowedProcesses: []string{"sh"}},
+			},
+			b: map[string]softwarecomposition.RulePolicy{
+				"R0002": {AllowedContainer: true},
+			},
+			want: map[string]softwarecomposition.RulePolicy{
+				"R0001": {AllowedProcesses: []string{"sh"}},
+				"R0002": {AllowedContainer: true},
+			},
+		},
+		{
+			name: "overlapping keys with conflicting flags",
+			a: map[string]softwarecomposition.RulePolicy{
+				"R0001": {AllowedProcesses: []string{"sh", "curl"}, AllowedContainer: false},
+				"R0002": {AllowedContainer: true},
+			},
+			b: map[string]softwarecomposition.RulePolicy{
+				"R0001": {AllowedProcesses: []string{"bash", "sh"}, AllowedContainer: true},
+				"R0002": {AllowedContainer: false},
+			},
+			want: map[string]softwarecomposition.RulePolicy{
+				"R0001": {AllowedProcesses: []string{"bash", "curl", "sh"}, AllowedContainer: true},
+				"R0002": {AllowedContainer: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MergeRulePolicies(tt.a, tt.b))
+			assert.Equal(t, tt.want, MergeRulePolicies(tt.b, tt.a), "merge must be symmetric")
+		})
+	}
+}
+
+func TestMergeRulePolicies_DoesNotModifyInputs(t *testing.T) {
+	a := map[string]softwarecomposition.RulePolicy{"R0001": {AllowedProcesses: []string{"sh"}}}
+	b := map[string]softwarecomposition.RulePolicy{"R0001": {AllowedProcesses: []string{"bash"}, AllowedContainer: true}}
+	_ = MergeRulePolicies(a, b)
+	assert.Equal(t, map[string]softwarecomposition.RulePolicy{"R0001": {AllowedProcesses: []string{"sh"}}}, a)
+	assert.Equal(t, map[string]softwarecomposition.RulePolicy{"R0001": {AllowedProcesses: []string{"bash"}, AllowedContainer: true}}, b)
+}
@@ -52,6 +52,25 @@ var (
 	ObjectTooLargeError  = errors.New("object is too large")
 )
 
+// ProfileTooLargeError is returned by PreSave when a profile is still over
+// its size limit after deflation. It wraps ObjectTooLargeError, so
+// errors.Is(err, ObjectTooLargeError) keeps working, and adds what a
+// caller needs to act on it: the actual size, the limit and the container
+// contributing the most to the size.
+type ProfileTooLargeError struct {
+	Size             int
+	Limit            int
+	LargestContainer string
+}
+
+func (e *ProfileTooLargeError) Error() string {
+	return fmt.Sprintf("application profile size %d exceeds the limit of %d (largest container %q): %v", e.Size, e.Limit, e.LargestContainer, ObjectTooLargeError)
+}
+
+func (e *ProfileTooLargeError) Unwrap() error {
+	return ObjectTooLargeError
+}
+
 type objState struct {
 	obj  runtime.Object
 	meta *storage.ResponseMeta
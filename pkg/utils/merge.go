@@ -2,6 +2,20 @@ package utils
 
 import "slices"
 
+// DeflateStringSlice returns the distinct strings of in, sorted. It is the
+// one normalization for string-set fields of profiles, such as
+// Architectures, RulePolicy.AllowedProcesses and endpoint Methods, so they
+// cannot drift apart. in is not modified. A nil in returns nil and an
+// empty one an empty slice, so callers can tell "unset" from "none".
+func DeflateStringSlice(in []string) []string {
+	if in == nil {
+		return nil
+	}
+	out := slices.Clone(in)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
 // MergeMaps merges m2 key/values into m1 without overriding existing keys
 func MergeMaps[Map ~map[K]V, K comparable, V any](m1, m2 Map, skips ...K) Map {
 	if m1 == nil {
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeflateStringSlice(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "empty",
+			in:   []string{},
+			want: []string{},
+		},
+		{
+			name: "already deflated",
+			in:   []string{"amd64", "arm64"},
+			want: []string{"amd64", "arm64"},
+		},
+		{
+			name: "architectures",
+			in:   []string{"amd64", "arm64", "amd64"},
+			want: []string{"amd64", "arm64"},
+		},
+		{
+			name: "duplicate heavy",
+			in:   []string{"ls", "bash", "ls", "cat", "bash", "ls", "ls", "cat", "bash", "ls"},
+			want: []string{"bash", "cat", "ls"},
+		},
+		{
+			name: "all the same",
+			in:   []string{"GET", "GET", "GET", "GET"},
+			want: []string{"GET"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in []string
+			if tt.in != nil {
+				in = append([]string{}, tt.in...)
+			}
+			assert.Equal(t, tt.want, DeflateStringSlice(in))
+			assert.Equal(t, tt.in, in, "input is not modified")
+		})
+	}
+}